@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
+	"github.com/spf13/cobra"
+)
+
+var agentsCmd = &cobra.Command{
+	Use:   "agents",
+	Short: "List the agents in agent-compose.yaml (targets for --agent)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cf, err := compose.Load(agentDir)
+		if err != nil {
+			return err
+		}
+		agents := compose.ListAgents(cf)
+		if jsonOut {
+			return printJSON(agents)
+		}
+		if len(agents) == 0 {
+			fmt.Println("  No agents in agent-compose.yaml")
+			return nil
+		}
+		for _, a := range agents {
+			label := a.ID
+			if a.Name != "" {
+				label += fmt.Sprintf(" (%s)", a.Name)
+			}
+			port := "-"
+			if a.Port != 0 {
+				port = fmt.Sprintf(":%d", a.Port)
+			}
+			fmt.Printf("  %-28s %-6s %d/%d plugins enabled\n", label, port, a.Enabled, a.Plugins)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(agentsCmd)
+}
@@ -2,18 +2,27 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/detect"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/policy"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/profile"
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/textdiff"
 	"github.com/spf13/cobra"
 )
 
 var (
 	enablePlugins  []string
 	disablePlugins []string
+	enableTags     []string
+	disableTags    []string
+	setFlags       []string
 	autoMode       bool
+	noProfiles     bool
+	rulesFile      string
 	dryRun         bool
 )
 
@@ -21,70 +30,246 @@ var applyCmd = &cobra.Command{
 	Use:   "apply",
 	Short: "Update agent-compose.yaml with perception plugin changes",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		var settings []compose.Setting
+		for _, s := range setFlags {
+			setting, err := compose.ParseSetting(s)
+			if err != nil {
+				return err
+			}
+			settings = append(settings, setting)
+		}
+
 		if autoMode {
-			return runAutoApply()
+			return runAutoApply(settings)
 		}
-		if len(enablePlugins) == 0 && len(disablePlugins) == 0 {
-			return fmt.Errorf("specify --enable, --disable, or --auto")
+		enablePlugins = append(enablePlugins, taggedNames(enableTags)...)
+		disablePlugins = append(disablePlugins, taggedNames(disableTags)...)
+		if len(enablePlugins) == 0 && len(disablePlugins) == 0 && len(settings) == 0 {
+			if len(enableTags) > 0 || len(disableTags) > 0 {
+				return fmt.Errorf("no capabilities tagged %s", strings.Join(append(enableTags, disableTags...), ", "))
+			}
+			return fmt.Errorf("specify --enable, --disable, --enable-tag, --disable-tag, --set, or --auto")
 		}
-		return runManualApply()
+		return runManualApply(settings)
 	},
 }
 
 func init() {
 	applyCmd.Flags().StringSliceVar(&enablePlugins, "enable", nil, "Enable plugins (comma-separated)")
 	applyCmd.Flags().StringSliceVar(&disablePlugins, "disable", nil, "Disable plugins (comma-separated)")
+	applyCmd.Flags().StringSliceVar(&enableTags, "enable-tag", nil, "Enable every capability with one of these tags (comma-separated)")
+	applyCmd.Flags().StringSliceVar(&disableTags, "disable-tag", nil, "Disable every capability with one of these tags (comma-separated)")
+	applyCmd.Flags().StringArrayVar(&setFlags, "set", nil, "Set a plugin field: plugin.interval=60m, plugin.timeout=30s, plugin.output_cap=8000 (repeatable)")
 	applyCmd.Flags().BoolVar(&autoMode, "auto", false, "Auto-configure based on detection results")
+	applyCmd.Flags().BoolVar(&noProfiles, "no-profiles", false, "With --auto, ignore profiles whose 'when' conditions match")
+	applyCmd.Flags().StringVar(&rulesFile, "rules", "", "With --auto, rules file (default <agent-dir>/.kuro-sense/rules.yaml)")
 	applyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show changes without writing")
 	rootCmd.AddCommand(applyCmd)
 }
 
-func runAutoApply() error {
+// autoReport is the --json output of apply --auto.
+type autoReport struct {
+	Profile   string            `json:"profile,omitempty"`
+	Decisions []policy.Decision `json:"decisions"`
+	Set       []string          `json:"set,omitempty"`
+	DryRun    bool              `json:"dryRun"`
+	Diff      string            `json:"diff,omitempty"`
+}
+
+func runAutoApply(settings []compose.Setting) error {
+	path := rulesFile
+	if path == "" {
+		path = policy.RulesPath(agentDir)
+	}
+	rules, err := policy.LoadRules(path)
+	if err != nil {
+		return err
+	}
+
+	// Tag flags extend the rules: --enable-tag like enable_tags,
+	// --disable-tag pins the tagged capabilities off.
+	rules.EnableTags = append(rules.EnableTags, enableTags...)
+	rules.PinOff = append(rules.PinOff, taggedNames(disableTags)...)
+
 	caps := registry.All()
 	results := detect.RunAll(caps)
 
-	var enable, disable []string
-	for _, r := range results.Capabilities {
-		if r.Available && r.Capability.DefaultOn {
-			enable = append(enable, r.Capability.Name)
-		} else if !r.Available {
-			disable = append(disable, r.Capability.Name)
+	var chosen *profile.Profile
+	if !noProfiles {
+		profiles, errs := profile.List(agentDir)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", err)
 		}
+		chosen = profile.Select(profiles, results)
 	}
 
-	if dryRun {
-		fmt.Println("Auto-configure (dry run):")
-		if len(enable) > 0 {
-			fmt.Printf("  Enable:  %s\n", strings.Join(enable, ", "))
+	var decisions []policy.Decision
+	if chosen != nil {
+		pch, err := profileChangesFor(chosen)
+		if err != nil {
+			return err
 		}
-		if len(disable) > 0 {
-			fmt.Printf("  Disable: %s\n", strings.Join(disable, ", "))
+		for _, name := range pch.Enable {
+			decisions = append(decisions, policy.Decision{Name: name, Action: policy.Enable, Reason: "in profile " + chosen.Name})
 		}
-		return nil
+		for _, name := range pch.Disable {
+			decisions = append(decisions, policy.Decision{Name: name, Action: policy.Disable, Reason: "not in profile " + chosen.Name})
+		}
+		decisions = policy.Pin(rules, decisions)
+		settings = append(pch.Set, settings...)
+	} else {
+		decisions = policy.Decide(rules, results.Capabilities)
+	}
+
+	decisions = policy.Link(caps, decisions)
+
+	enable, disable := policy.Split(decisions)
+	ch := compose.Changes{Agent: agentName, Enable: enable, Disable: disable, Set: settings}
+	report := autoReport{Decisions: decisions, DryRun: dryRun}
+	if chosen != nil {
+		report.Profile = chosen.Name
+	}
+	for _, s := range settings {
+		report.Set = append(report.Set, s.String())
 	}
 
-	if err := compose.ApplyChanges(agentDir, enable, disable); err != nil {
+	if dryRun {
+		before, after, err := compose.Preview(agentDir, ch)
+		if err != nil {
+			return err
+		}
+		report.Diff = textdiff.Unified("a/agent-compose.yaml", "b/agent-compose.yaml", before, after, textdiff.DefaultContext)
+	} else if err := compose.ApplyChanges(agentDir, ch); err != nil {
 		return err
 	}
-	fmt.Printf("Applied: enabled %d, disabled %d plugins\n", len(enable), len(disable))
+
+	if jsonOut {
+		return printJSON(report)
+	}
+	printAutoReport(report, chosen)
 	return nil
 }
 
-func runManualApply() error {
-	if dryRun {
-		fmt.Println("Manual apply (dry run):")
-		if len(enablePlugins) > 0 {
-			fmt.Printf("  Enable:  %s\n", strings.Join(enablePlugins, ", "))
-		}
-		if len(disablePlugins) > 0 {
-			fmt.Printf("  Disable: %s\n", strings.Join(disablePlugins, ", "))
+func printAutoReport(report autoReport, chosen *profile.Profile) {
+	if report.DryRun {
+		fmt.Println("Auto-configure (dry run):")
+	} else {
+		fmt.Println("Auto-configure:")
+	}
+	if chosen != nil {
+		fmt.Printf("  Profile: %s\n", profileSummary(chosen))
+	}
+	if agentName != "" {
+		fmt.Printf("  Agent:   %s\n", agentName)
+	}
+	fmt.Println()
+
+	marks := map[policy.Action]string{policy.Enable: "+", policy.Disable: "-", policy.Keep: "="}
+	var enabled, disabled int
+	for _, d := range report.Decisions {
+		fmt.Printf("  %s %-22s %s\n", marks[d.Action], d.Name, d.Reason)
+		switch d.Action {
+		case policy.Enable:
+			enabled++
+		case policy.Disable:
+			disabled++
 		}
-		return nil
 	}
+	if len(report.Set) > 0 {
+		fmt.Printf("\n  Set: %s\n", strings.Join(report.Set, ", "))
+	}
+	fmt.Println()
 
-	if err := compose.ApplyChanges(agentDir, enablePlugins, disablePlugins); err != nil {
+	if !report.DryRun {
+		fmt.Printf("Applied: enabled %d, disabled %d plugins\n", enabled, disabled)
+		return
+	}
+	if report.Diff == "" {
+		fmt.Println("  agent-compose.yaml would not change")
+		return
+	}
+	fmt.Print(report.Diff)
+}
+
+func runManualApply(settings []compose.Setting) error {
+	ch := compose.Changes{Agent: agentName, Enable: enablePlugins, Disable: disablePlugins, Set: settings}
+	ch, pulls, err := compose.Resolve(agentDir, ch)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		return printDryRun("Manual apply (dry run):", ch, pulls)
+	}
+	before, after, err := compose.Preview(agentDir, ch)
+	if err != nil {
+		return err
+	}
+	if string(before) == string(after) {
+		fmt.Println("No changes: agent-compose.yaml already matches")
+		return nil
+	}
+	if err := compose.ApplyChanges(agentDir, ch); err != nil {
 		return err
 	}
+	printPulls(pulls)
 	fmt.Println("Applied changes to agent-compose.yaml")
 	return nil
 }
+
+// printPulls lists plugins enabled only because another plugin needs them.
+func printPulls(pulls []registry.Pull) {
+	for _, p := range pulls {
+		fmt.Printf("Also enabling %s\n", p)
+	}
+}
+
+// taggedNames returns the built-in capabilities carrying any of tags.
+func taggedNames(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	var names []string
+	for _, c := range registry.FilterByTags(registry.All(), tags) {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// printDryRun summarizes ch and prints a unified diff of the compose file
+// ApplyChanges would write.
+func printDryRun(title string, ch compose.Changes, pulls []registry.Pull) error {
+	before, after, err := compose.Preview(agentDir, ch)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(title)
+	if ch.Agent != "" {
+		fmt.Printf("  Agent:   %s\n", ch.Agent)
+	}
+	if len(ch.Enable) > 0 {
+		fmt.Printf("  Enable:  %s\n", strings.Join(ch.Enable, ", "))
+	}
+	for _, p := range pulls {
+		fmt.Printf("  Pulled:  %s\n", p)
+	}
+	if len(ch.Disable) > 0 {
+		fmt.Printf("  Disable: %s\n", strings.Join(ch.Disable, ", "))
+	}
+	if len(ch.Set) > 0 {
+		parts := make([]string, len(ch.Set))
+		for i, s := range ch.Set {
+			parts[i] = s.String()
+		}
+		fmt.Printf("  Set:     %s\n", strings.Join(parts, ", "))
+	}
+	fmt.Println()
+
+	diff := textdiff.Unified("a/agent-compose.yaml", "b/agent-compose.yaml", before, after, textdiff.DefaultContext)
+	if diff == "" {
+		fmt.Println("  agent-compose.yaml would not change")
+		return nil
+	}
+	fmt.Print(diff)
+	return nil
+}
@@ -1,21 +1,32 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/detect"
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
 	"github.com/spf13/cobra"
 )
 
+var (
+	detectWorkers int
+	detectTimeout time.Duration
+	detectTags    []string
+)
+
 var detectCmd = &cobra.Command{
 	Use:   "detect",
 	Short: "Scan environment and detect available capabilities",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		caps := registry.All()
-		results := detect.RunAll(caps)
+		ctx, cancel := context.WithTimeout(cmd.Context(), detectTimeout)
+		defer cancel()
+
+		caps := registry.FilterByTags(registry.All(), detectTags)
+		results := detect.NewEngine(detectWorkers).Run(ctx, caps)
 
 		if jsonOut {
 			return printJSON(results)
@@ -26,11 +37,14 @@ var detectCmd = &cobra.Command{
 }
 
 func init() {
+	detectCmd.Flags().IntVar(&detectWorkers, "workers", detect.DefaultWorkers, "Maximum concurrent dependency probes")
+	detectCmd.Flags().DurationVar(&detectTimeout, "timeout", detect.DefaultTimeout, "Deadline for the whole detection run")
+	detectCmd.Flags().StringSliceVar(&detectTags, "tag", nil, "Only detect capabilities with one of these tags (comma-separated)")
 	rootCmd.AddCommand(detectCmd)
 }
 
-func printJSON(results detect.Results) error {
-	data, err := json.MarshalIndent(results, "", "  ")
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -125,44 +139,39 @@ func printHuman(results detect.Results) {
 	fmt.Println()
 
 	// Capabilities by category
-	categories := []registry.Category{
-		registry.CategoryWorkspace,
-		registry.CategoryChrome,
-		registry.CategoryTelegram,
-		registry.CategoryHeartbeat,
-	}
-	categoryNames := map[registry.Category]string{
-		registry.CategoryWorkspace: "Workspace",
-		registry.CategoryChrome:    "Chrome",
-		registry.CategoryTelegram:  "Telegram",
-		registry.CategoryHeartbeat: "Heartbeat",
-	}
-
 	var available, degraded, unavailable int
 
-	for _, cat := range categories {
-		fmt.Printf("  ── %s ──\n", categoryNames[cat])
-		for _, r := range results.Capabilities {
-			if r.Capability.Category != cat {
-				continue
-			}
+	groups := registry.GroupByCategory(results.Capabilities, func(r registry.DetectionResult) registry.Category {
+		return r.Capability.Category
+	})
+	for _, g := range groups {
+		fmt.Printf("  ── %s ──\n", g.Category.Name)
+		for _, r := range g.Items {
 			icon := "✅"
 			status := ""
 			if !r.Available {
 				icon = "❌"
 				unavailable++
 				if len(r.MissingDeps) > 0 {
-					names := depNames(r.MissingDeps)
+					names := detect.MissingLabels(r)
 					status = fmt.Sprintf(" (missing: %s)", strings.Join(names, ", "))
 				}
 			} else if r.Degraded {
 				icon = "⚠️"
 				degraded++
-				optMissing := depNames(r.MissingDeps)
-				status = fmt.Sprintf(" (optional: %s)", strings.Join(optMissing, ", "))
+				if len(r.MissingDeps) > 0 {
+					optMissing := detect.MissingLabels(r)
+					status = fmt.Sprintf(" (optional: %s)", strings.Join(optMissing, ", "))
+				}
 			} else {
 				available++
 			}
+			if len(r.OutdatedDeps) > 0 {
+				status += fmt.Sprintf(" (too old: %s)", strings.Join(detect.OutdatedLabels(r), ", "))
+			}
+			if len(r.UnknownDeps) > 0 {
+				status += fmt.Sprintf(" (unknown check: %s)", strings.Join(detect.UnknownLabels(r.UnknownDeps), ", "))
+			}
 			fmt.Printf("    %s %-20s %s%s\n", icon, r.Capability.Name, r.Capability.Description, status)
 		}
 		fmt.Println()
@@ -180,14 +189,6 @@ func printHuman(results detect.Results) {
 	fmt.Println()
 }
 
-func depNames(deps []registry.Dependency) []string {
-	names := make([]string, len(deps))
-	for i, d := range deps {
-		names[i] = d.Name
-	}
-	return names
-}
-
 func hwNames(devs []detect.HWDevice) []string {
 	names := make([]string, len(devs))
 	for i, d := range devs {
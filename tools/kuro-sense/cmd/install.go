@@ -1,102 +1,445 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"os/exec"
-	"runtime"
+	"io"
+	"os"
+	"slices"
+	"strings"
 
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/detect"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/installer"
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
 	"github.com/spf13/cobra"
 )
 
+var (
+	installMissing     bool
+	installFor         []string
+	installEnabledOnly bool
+	installYes         bool
+)
+
 var installCmd = &cobra.Command{
-	Use:   "install [dependency]",
+	Use:   "install [dependency...]",
 	Short: "Install missing dependencies",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Install dependencies with the package manager the host has
+(brew, apt, dnf, pacman, apk, pip, npm, go install, or a checksummed
+download into ~/.local/bin), then re-run detection to confirm.
+
+With --missing, install everything detection reports missing for the
+selected capabilities, after showing the plan.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		for _, name := range args {
-			if err := installDep(name); err != nil {
-				return fmt.Errorf("install %s: %w", name, err)
+		var report installReport
+		var err error
+		switch {
+		case installMissing && len(args) > 0:
+			return fmt.Errorf("give dependency names or --missing, not both")
+		case installMissing:
+			report, err = runInstallMissing(cmd.Context())
+		case len(installFor) > 0 || installEnabledOnly:
+			return fmt.Errorf("--for and --enabled-only need --missing")
+		case len(args) == 0:
+			return fmt.Errorf("specify dependencies to install, or --missing")
+		default:
+			report = installNamed(cmd.Context(), args)
+		}
+		if err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+
+		if jsonOut {
+			if err := printJSON(report); err != nil {
+				return err
 			}
+		} else {
+			printInstallReport(report)
+		}
+		if n := countFailed(report.Results); n > 0 {
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			return fmt.Errorf("install: %d of %d dependencies not installed", n, len(report.Results))
 		}
 		return nil
 	},
 }
 
 func init() {
+	installCmd.Flags().BoolVar(&installMissing, "missing", false, "Install every missing dependency of the selected capabilities")
+	installCmd.Flags().StringSliceVar(&installFor, "for", nil, "With --missing, only these capabilities (comma-separated)")
+	installCmd.Flags().BoolVar(&installEnabledOnly, "enabled-only", false, "With --missing, only capabilities enabled in agent-compose.yaml")
+	installCmd.Flags().BoolVarP(&installYes, "yes", "y", false, "With --missing, install without asking")
+	installCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show how each dependency would be installed without installing")
 	rootCmd.AddCommand(installCmd)
 }
 
-func installDep(name string) error {
-	// Look up install hint from registry
-	hint := findInstallHint(name)
-	if hint == nil {
-		return fmt.Errorf("unknown dependency: %s (try installing manually)", name)
+// Install outcomes.
+const (
+	installPlanned  = "planned"       // --dry-run
+	installPresent  = "present"       // already installed; nothing run
+	installDone     = "installed"     // installed and detected
+	installNotFound = "still-missing" // the install ran but detection still fails
+	installManual   = "manual"        // needs a command run by hand
+	installSkipped  = "skipped"       // --missing: no way to install it here
+	installFailed   = "failed"
+)
+
+// installReport is the outcome of one install command.
+type installReport struct {
+	Results []installResult `json:"results"`
+	// NowAvailable lists capabilities that were unavailable before and
+	// are available after installing.
+	NowAvailable []string `json:"nowAvailable,omitempty"`
+
+	planShown bool // the plan was printed; do not list planned items again
+}
+
+// installResult is the outcome for one dependency.
+type installResult struct {
+	Name    string                    `json:"name"`
+	Method  registry.InstallMethod    `json:"method,omitempty"`
+	Command string                    `json:"command,omitempty"`
+	For     []string                  `json:"for,omitempty"` // capabilities that need it
+	Status  string                    `json:"status"`
+	Error   string                    `json:"error,omitempty"`
+	Check   *registry.DependencyCheck `json:"check,omitempty"` // detection after installing
+}
+
+// planItem is one dependency to install and how.
+type planItem struct {
+	dep  registry.Dependency
+	hint registry.InstallHint
+	err  error // no usable hint
+	skip bool  // report err as skipped rather than failed
+	caps []string
+}
+
+// installNamed installs dependencies named on the command line.
+func installNamed(ctx context.Context, names []string) installReport {
+	mgrs := installer.DetectManagers()
+	var caps []registry.Capability
+	var items []planItem
+	var report installReport
+	for _, name := range names {
+		dep, users, ok := findDependency(name)
+		if !ok {
+			report.Results = append(report.Results, installResult{
+				Name: name, Status: installFailed, Error: "unknown dependency (try installing manually)",
+			})
+			continue
+		}
+		item := planItem{dep: dep}
+		item.hint, item.err = installer.Choose(dep, mgrs)
+		for _, c := range users {
+			item.caps = append(item.caps, c.Name)
+			if !slices.ContainsFunc(caps, func(x registry.Capability) bool { return x.Name == c.Name }) {
+				caps = append(caps, c)
+			}
+		}
+		items = append(items, item)
+	}
+
+	var before []registry.DetectionResult
+	if !dryRun {
+		before = detect.NewEngine(0).Check(ctx, caps)
+	}
+	report.Results = append(report.Results, installItems(ctx, items, mgrs)...)
+	if !dryRun {
+		report.NowAvailable = nowAvailable(before, detect.NewEngine(0).Check(ctx, caps))
+	}
+	return report
+}
+
+// runInstallMissing plans and installs every missing dependency of the
+// selected capabilities.
+func runInstallMissing(ctx context.Context) (installReport, error) {
+	caps, err := installCapabilities()
+	if err != nil {
+		return installReport{}, err
 	}
+	before := detect.NewEngine(0).Check(ctx, caps)
 
-	switch hint.Method {
-	case registry.InstallBrew:
-		if runtime.GOOS != "darwin" && runtime.GOOS != "linux" {
-			return fmt.Errorf("brew not available on %s", runtime.GOOS)
+	mgrs := installer.DetectManagers()
+	items := missingPlan(before, mgrs)
+	if len(items) == 0 {
+		if !jsonOut {
+			fmt.Println("Nothing to install: no missing dependencies")
 		}
-		fmt.Printf("Installing %s via brew...\n", hint.Package)
-		cmd := exec.Command("brew", "install", hint.Package)
-		cmd.Stdout = nil
-		cmd.Stderr = nil
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("brew install failed: %w", err)
+		return installReport{}, nil
+	}
+
+	out := installOutput()
+	printInstallPlan(out, items, mgrs)
+	if !dryRun && !installYes {
+		ok, err := confirm(out, "Install these dependencies?")
+		if err != nil {
+			return installReport{}, err
 		}
-		fmt.Printf("✓ %s installed\n", name)
+		if !ok {
+			return installReport{}, fmt.Errorf("cancelled")
+		}
+	}
+
+	report := installReport{Results: installItems(ctx, items, mgrs), planShown: true}
+	if !dryRun {
+		report.NowAvailable = nowAvailable(before, detect.NewEngine(0).Check(ctx, caps))
+	}
+	return report, nil
+}
 
-	case registry.InstallApt:
-		if runtime.GOOS != "linux" {
-			return fmt.Errorf("apt not available on %s", runtime.GOOS)
+// installCapabilities returns the capabilities selected by --for and
+// --enabled-only.
+func installCapabilities() ([]registry.Capability, error) {
+	caps := registry.All()
+	if len(installFor) > 0 {
+		var selected []registry.Capability
+		for _, name := range installFor {
+			c := registry.ByName(name)
+			if c == nil {
+				return nil, fmt.Errorf("unknown capability %q", name)
+			}
+			selected = append(selected, *c)
+		}
+		caps = selected
+	}
+	if installEnabledOnly {
+		cf, err := compose.Load(agentDir)
+		if err != nil {
+			return nil, err
 		}
-		fmt.Printf("Installing %s via apt...\n", hint.Package)
-		cmd := exec.Command("sudo", "apt-get", "install", "-y", hint.Package)
-		cmd.Stdout = nil
-		cmd.Stderr = nil
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("apt install failed: %w", err)
+		enabled, err := compose.GetEnabledPluginNames(cf, agentName)
+		if err != nil {
+			return nil, err
 		}
-		fmt.Printf("✓ %s installed\n", name)
+		caps = slices.DeleteFunc(caps, func(c registry.Capability) bool {
+			return !slices.Contains(enabled, c.Name)
+		})
+	}
+	return caps, nil
+}
 
-	case registry.InstallPip:
-		fmt.Printf("Installing %s via pip...\n", hint.Package)
-		cmd := exec.Command("pip3", "install", hint.Package)
-		cmd.Stdout = nil
-		cmd.Stderr = nil
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("pip install failed: %w", err)
+// missingPlan collects the missing dependencies in results, once per
+// kind and check, and picks how to install each. Items are ordered by
+// install method, then by first appearance.
+func missingPlan(results []registry.DetectionResult, mgrs installer.Managers) []planItem {
+	type key struct {
+		kind  registry.DependencyKind
+		check string
+	}
+	index := make(map[key]int)
+	var items []planItem
+	for _, r := range results {
+		for _, dep := range r.MissingDeps {
+			k := key{dep.Kind, dep.Check}
+			if i, ok := index[k]; ok {
+				items[i].caps = append(items[i].caps, r.Capability.Name)
+				continue
+			}
+			index[k] = len(items)
+			item := planItem{dep: dep, caps: []string{r.Capability.Name}}
+			item.hint, item.err = installer.Choose(dep, mgrs)
+			item.skip = item.err != nil
+			items = append(items, item)
 		}
-		fmt.Printf("✓ %s installed\n", name)
+	}
 
-	case registry.InstallCurl:
-		fmt.Printf("Installing %s via curl...\n", name)
-		// curl install would need more context (URL, destination)
-		return fmt.Errorf("curl install not yet implemented for %s", name)
+	order := mgrs.Methods()
+	rank := func(it planItem) int {
+		if it.err != nil {
+			return len(order)
+		}
+		return slices.Index(order, it.hint.Method)
+	}
+	slices.SortStableFunc(items, func(a, b planItem) int { return rank(a) - rank(b) })
+	return items
+}
 
-	case registry.InstallManual:
-		fmt.Printf("Manual installation required for %s:\n", name)
-		if hint.Command != "" {
-			fmt.Printf("  Run: %s\n", hint.Command)
+// printInstallPlan lists items grouped by install method.
+func printInstallPlan(out io.Writer, items []planItem, mgrs installer.Managers) {
+	fmt.Fprintf(out, "Install plan (%d dependencies):\n", len(items))
+	group := ""
+	for _, it := range items {
+		g := string(it.hint.Method)
+		if it.err != nil {
+			g = "cannot install"
 		}
-		return nil
+		if g != group {
+			fmt.Fprintf(out, "\n  %s:\n", g)
+			group = g
+		}
+		how := installer.Describe(it.dep, it.hint, mgrs)
+		if it.err != nil {
+			how = it.err.Error()
+		}
+		fmt.Fprintf(out, "    %-14s %s\n", it.dep.Name, how)
+		fmt.Fprintf(out, "    %-14s for %s\n", "", strings.Join(it.caps, ", "))
+	}
+	fmt.Fprintln(out)
+}
 
-	default:
-		return fmt.Errorf("no install method for %s", name)
+// confirm asks a yes/no question on the terminal; anything but y/yes is no.
+func confirm(out io.Writer, question string) (bool, error) {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return false, fmt.Errorf("not a terminal; pass --yes to install without asking")
 	}
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return false, nil
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
 
-	return nil
+// installOutput is where package manager output goes: stdout, unless
+// stdout carries the --json report.
+func installOutput() io.Writer {
+	if jsonOut {
+		return os.Stderr
+	}
+	return os.Stdout
 }
 
-func findInstallHint(name string) *registry.InstallHint {
+// installItems installs items in order, streaming package manager output,
+// and re-detects each dependency afterwards. With --dry-run nothing runs.
+func installItems(ctx context.Context, items []planItem, mgrs installer.Managers) []installResult {
+	out := installOutput()
+	var results []installResult
+	for _, it := range items {
+		res := installResult{Name: it.dep.Name, For: it.caps}
+		if it.err != nil {
+			res.Status = installFailed
+			if it.skip {
+				res.Status = installSkipped
+			}
+			res.Error = it.err.Error()
+			results = append(results, res)
+			continue
+		}
+		res.Method = it.hint.Method
+		res.Command = installer.Describe(it.dep, it.hint, mgrs)
+
+		if dryRun {
+			res.Status = installPlanned
+			results = append(results, res)
+			continue
+		}
+		if check := installer.Verify(ctx, it.dep); check.Status == registry.StatusPresent {
+			res.Status = installPresent
+			res.Check = &check
+			results = append(results, res)
+			continue
+		}
+
+		fmt.Fprintf(out, "==> Installing %s via %s: %s\n", it.dep.Name, it.hint.Method, res.Command)
+		err := installer.Install(ctx, it.dep, it.hint, mgrs, out)
+		switch {
+		case errors.Is(err, installer.ErrManual):
+			res.Status = installManual
+			results = append(results, res)
+			continue
+		case err != nil:
+			res.Status = installFailed
+			res.Error = err.Error()
+			results = append(results, res)
+			continue
+		}
+
+		check := installer.Verify(ctx, it.dep)
+		res.Check = &check
+		res.Status = installDone
+		if check.Status != registry.StatusPresent {
+			res.Status = installNotFound
+			res.Error = fmt.Sprintf("detection reports %s", check.Status)
+			if it.hint.Method == registry.InstallCurl {
+				res.Error += fmt.Sprintf(" (is %s on PATH?)", installer.BinDir())
+			}
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// nowAvailable lists capabilities unavailable in before and available in
+// after. Both are results for the same capabilities, in the same order.
+func nowAvailable(before, after []registry.DetectionResult) []string {
+	var names []string
+	for i, r := range after {
+		if r.Available && i < len(before) && !before[i].Available {
+			names = append(names, r.Capability.Name)
+		}
+	}
+	return names
+}
+
+// findDependency returns the first dependency with name that has install
+// hints, and every capability that depends on it.
+func findDependency(name string) (registry.Dependency, []registry.Capability, bool) {
+	var found registry.Dependency
+	var ok bool
+	var caps []registry.Capability
 	for _, cap := range registry.All() {
 		for _, dep := range cap.Dependencies {
-			if dep.Name == name && dep.Install.Method != "" {
-				return &dep.Install
+			if dep.Name != name {
+				continue
 			}
+			caps = append(caps, cap)
+			if !ok && len(detect.InstallHints(dep)) > 0 {
+				found, ok = dep, true
+			}
+			break
+		}
+	}
+	return found, caps, ok
+}
+
+func printInstallReport(report installReport) {
+	if report.planShown && dryRun {
+		return
+	}
+	if len(report.Results) == 0 {
+		return
+	}
+	fmt.Println()
+	for _, r := range report.Results {
+		icon := "✗"
+		switch r.Status {
+		case installDone, installPresent:
+			icon = "✓"
+		case installPlanned, installManual, installSkipped:
+			icon = "·"
+		}
+		line := fmt.Sprintf("  %s %-14s %s", icon, r.Name, r.Status)
+		if r.Method != "" {
+			line += " via " + string(r.Method)
+		}
+		if r.Check != nil && r.Check.Version != "" {
+			line += " (" + r.Check.Version + ")"
+		}
+		fmt.Println(line)
+		switch {
+		case r.Error != "":
+			fmt.Printf("      %s\n", r.Error)
+		case r.Status == installPlanned || r.Status == installManual:
+			fmt.Printf("      %s\n", r.Command)
+		}
+	}
+	if len(report.NowAvailable) > 0 {
+		fmt.Printf("\n  Now available: %s\n", strings.Join(report.NowAvailable, ", "))
+	}
+}
+
+func countFailed(results []installResult) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == installFailed || r.Status == installNotFound {
+			n++
 		}
 	}
-	return nil
+	return n
 }
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/pack"
+	"github.com/spf13/cobra"
+)
+
+// passphraseEnv supplies the archive passphrase without a prompt.
+const passphraseEnv = "KURO_SENSE_PASSPHRASE"
+
+var (
+	identityFile    string
+	trustedKeysFile string
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage the keys that encrypt and sign pack archives",
+	Long: `Manage the identity used to receive encrypted pack archives and to sign
+them, and the list of signers whose archives unpack and verify accept.
+
+An identity holds two key pairs. Share its recipient key (x25519:...) with
+whoever packs archives for you, and its signer key (ed25519:...) with
+whoever unpacks archives you sign.`,
+}
+
+var keysGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Create a new identity",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		path := identityPath()
+		id, err := pack.GenerateIdentity()
+		if err != nil {
+			return err
+		}
+		if err := id.Save(path); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Created identity %s (mode 0600)\n", path)
+		printIdentity(id)
+		return nil
+	},
+}
+
+var keysShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the public keys of the identity",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := pack.LoadIdentity(identityPath())
+		if err != nil {
+			return err
+		}
+		if jsonOut {
+			return printJSON(map[string]string{"recipient": id.Recipient(), "signer": id.Signer()})
+		}
+		printIdentity(id)
+		return nil
+	},
+}
+
+var keysTrustCmd = &cobra.Command{
+	Use:   "trust <ed25519:key> [comment]",
+	Short: "Accept archives signed by a key",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := pack.ParseSigner(args[0])
+		if err != nil {
+			return err
+		}
+		comment := ""
+		if len(args) == 2 {
+			comment = args[1]
+		}
+		path := trustedKeysPath()
+		added, err := pack.AddTrustedKey(path, key, comment)
+		if err != nil {
+			return err
+		}
+		if !added {
+			fmt.Printf("  Already trusted in %s\n", path)
+			return nil
+		}
+		fmt.Printf("✓ Trusted %s in %s\n", args[0], path)
+		return nil
+	},
+}
+
+func init() {
+	keysCmd.PersistentFlags().StringVar(&identityFile, "identity", "", "Identity file (default: $KURO_SENSE_IDENTITY or the user config dir)")
+	keysTrustCmd.Flags().StringVar(&trustedKeysFile, "trusted-keys", "", "Trusted keys file (default: $KURO_SENSE_TRUSTED_KEYS or the user config dir)")
+	keysCmd.AddCommand(keysGenerateCmd, keysShowCmd, keysTrustCmd)
+	rootCmd.AddCommand(keysCmd)
+}
+
+func printIdentity(id *pack.Identity) {
+	fmt.Printf("  recipient  %s\n", id.Recipient())
+	fmt.Printf("  signer     %s\n", id.Signer())
+}
+
+func identityPath() string {
+	if identityFile != "" {
+		return identityFile
+	}
+	return pack.DefaultIdentityPath()
+}
+
+func trustedKeysPath() string {
+	if trustedKeysFile != "" {
+		return trustedKeysFile
+	}
+	return pack.DefaultTrustedKeysPath()
+}
+
+// loadIdentity returns the identity, or nil when there is none and it
+// was not asked for by --identity.
+func loadIdentity() (*pack.Identity, error) {
+	id, err := pack.LoadIdentity(identityPath())
+	if errors.Is(err, os.ErrNotExist) && identityFile == "" {
+		return nil, nil
+	}
+	return id, err
+}
+
+// verifyOptions builds what unpack and verify need to read an archive:
+// the identity and a passphrase prompt for decryption, and the trusted
+// signers, which include the identity's own key.
+func verifyOptions(requireSignature bool) (pack.VerifyOptions, error) {
+	id, err := loadIdentity()
+	if err != nil {
+		return pack.VerifyOptions{}, err
+	}
+	trusted, err := pack.LoadTrustedKeys(trustedKeysPath())
+	if err != nil {
+		return pack.VerifyOptions{}, err
+	}
+	if id != nil {
+		own, _ := pack.ParseSigner(id.Signer())
+		trusted = append(trusted, pack.TrustedKey{Key: own, Comment: "this identity"})
+	}
+
+	var pass string
+	return pack.VerifyOptions{
+		Decryption: pack.Decryption{
+			Identity: id,
+			Passphrase: func() (string, error) {
+				// Verify and extract each read the archive; ask once.
+				if pass == "" {
+					p, err := readPassphrase(false)
+					if err != nil {
+						return "", err
+					}
+					pass = p
+				}
+				return pass, nil
+			},
+		},
+		Trusted:          trusted,
+		RequireSignature: requireSignature,
+	}, nil
+}
+
+// readPassphrase reads the archive passphrase from $KURO_SENSE_PASSPHRASE
+// or the terminal, asking twice when confirm is set.
+func readPassphrase(confirm bool) (string, error) {
+	if p := os.Getenv(passphraseEnv); p != "" {
+		return p, nil
+	}
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return "", fmt.Errorf("not a terminal; set %s to give the passphrase", passphraseEnv)
+	}
+	p, err := promptHidden("Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", fmt.Errorf("empty passphrase")
+	}
+	if confirm {
+		again, err := promptHidden("Passphrase again: ")
+		if err != nil {
+			return "", err
+		}
+		if again != p {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return p, nil
+}
+
+// promptHidden reads a line from the terminal with echo off.
+func promptHidden(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if setEcho(false) == nil {
+		defer func() {
+			setEcho(true)
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("no input")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,13 +10,111 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var packOutput string
+var (
+	packOutput     string
+	packEncrypt    bool
+	packRecipients []string
+	packSign       bool
+	packInclude    []string
+	packExclude    []string
+	packInstance   bool
+	packList       bool
+	packSince      string
+)
 
 var packCmd = &cobra.Command{
 	Use:   "pack",
 	Short: "Pack agent data for migration",
+	Long: `Pack agent data for migration.
+
+--encrypt protects the archive with a passphrase (from $KURO_SENSE_PASSPHRASE
+or a prompt); --recipient encrypts it to the x25519 key of an identity (see
+kuro-sense keys) instead. --sign adds an Ed25519 signature over the manifest
+with your identity, which unpack and verify check against trusted keys.
+
+What is archived comes from .kuro-sense-pack.yaml in the agent directory
+(include and exclude patterns with gitignore semantics, per-path max_size,
+and instance: true to add ~/.mini-agent) and .kuro-sense-packignore.
+Without a config pack takes memory/, plugins/, skills/, scripts/ and
+agent-compose.yaml. --include and --exclude add patterns; --list shows
+what would be archived without writing anything.
+
+--since makes an incremental archive against an earlier pack (an archive,
+its manifest.json, or "last" for the latest pack of this agent directory):
+only files whose SHA-256 changed are archived, and deleted files are
+recorded as tombstones. Restore with unpack FULL INCREMENTAL... in order.
+Every pack is recorded in .kuro-sense/ in the agent directory; see
+kuro-sense pack history.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		outPath, err := pack.Pack(agentDir, packOutput)
+		cfg, err := pack.LoadConfig(agentDir)
+		if err != nil {
+			return err
+		}
+		cfg.Include = append(cfg.Include, packInclude...)
+		cfg.Exclude = append(cfg.Exclude, packExclude...)
+		cfg.Instance = cfg.Instance || packInstance
+		if packList {
+			plan, err := pack.Plan(agentDir, "", cfg)
+			if err != nil {
+				return err
+			}
+			if jsonOut {
+				return printJSON(map[string]any{
+					"parts":   plan.Parts(),
+					"files":   plan.Files,
+					"skipped": plan.Skipped,
+					"size":    plan.Size(),
+				})
+			}
+			printPlan(plan)
+			return nil
+		}
+
+		opts := pack.PackOptions{Output: packOutput, Config: cfg}
+		if packSince != "" {
+			since := packSince
+			if since == "last" {
+				since = pack.LastPackPath(agentDir)
+			}
+			vopts, err := verifyOptions(false)
+			if err != nil {
+				return err
+			}
+			base, err := pack.ReadManifest(since, vopts.Decryption)
+			if errors.Is(err, os.ErrNotExist) && packSince == "last" {
+				return fmt.Errorf("no earlier pack recorded for %s; make a full pack first", agentDir)
+			}
+			if err != nil {
+				return fmt.Errorf("--since: %w", err)
+			}
+			opts.Since = base
+		}
+		for _, r := range packRecipients {
+			rec, err := pack.ParseRecipient(r)
+			if err != nil {
+				return err
+			}
+			opts.Encrypt.Recipients = append(opts.Encrypt.Recipients, rec)
+		}
+		if packEncrypt && len(opts.Encrypt.Recipients) == 0 {
+			p, err := readPassphrase(true)
+			if err != nil {
+				return err
+			}
+			opts.Encrypt.Passphrase = p
+		}
+		if packSign {
+			id, err := loadIdentity()
+			if err != nil {
+				return err
+			}
+			if id == nil {
+				return fmt.Errorf("no identity at %s; run kuro-sense keys generate", identityPath())
+			}
+			opts.Sign = id
+		}
+
+		outPath, err := pack.Pack(agentDir, opts)
 		if err != nil {
 			return err
 		}
@@ -28,9 +127,76 @@ var packCmd = &cobra.Command{
 
 func init() {
 	packCmd.Flags().StringVarP(&packOutput, "output", "o", "", "Output file path (default: kuro-sense-pack-YYYY-MM-DD.tar.gz)")
+	packCmd.Flags().BoolVar(&packEncrypt, "encrypt", false, "Encrypt the archive with a passphrase")
+	packCmd.Flags().StringArrayVar(&packRecipients, "recipient", nil, "Encrypt to this x25519: public key instead (repeatable)")
+	packCmd.Flags().BoolVar(&packSign, "sign", false, "Sign the manifest with your identity")
+	packCmd.Flags().StringArrayVar(&packInclude, "include", nil, "Also archive paths matching this pattern (repeatable)")
+	packCmd.Flags().StringArrayVar(&packExclude, "exclude", nil, "Leave out paths matching this pattern (repeatable)")
+	packCmd.Flags().BoolVar(&packInstance, "instance", false, "Include instance data from ~/.mini-agent")
+	packCmd.Flags().BoolVar(&packList, "list", false, "List what would be archived and exit")
+	packCmd.Flags().StringVar(&packSince, "since", "", `Archive only changes since an earlier archive, manifest, or "last"`)
+	packCmd.Flags().StringVar(&identityFile, "identity", "", "Identity file (default: $KURO_SENSE_IDENTITY or the user config dir)")
+	packCmd.AddCommand(packHistoryCmd)
 	rootCmd.AddCommand(packCmd)
 }
 
+var packHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the packs made from the agent directory",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := pack.History(agentDir)
+		if err != nil {
+			return err
+		}
+		if jsonOut {
+			return printJSON(entries)
+		}
+		if len(entries) == 0 {
+			fmt.Printf("No packs recorded for %s\n", agentDir)
+			return nil
+		}
+		for _, e := range entries {
+			kind := "full"
+			if e.Base != "" {
+				kind = "since " + shortPackID(e.Base)
+			}
+			exists := ""
+			if _, err := os.Stat(e.Archive); err != nil {
+				exists = " (archive gone)"
+			}
+			fmt.Printf("%s  %s  %-18s %5d file(s) %5d deleted  %9s  %s%s\n",
+				e.CreatedAt.Local().Format("2006-01-02 15:04"), shortPackID(e.ID), kind,
+				e.Files, e.Deleted, humanSize(e.Size), e.Archive, exists)
+		}
+		return nil
+	},
+}
+
+func shortPackID(id string) string {
+	return id[:min(len(id), 12)]
+}
+
+// printPlan shows the size of each top-level part and what is skipped.
+func printPlan(plan *pack.PackPlan) {
+	fmt.Printf("Pack plan for %s\n\n", agentDir)
+	if len(plan.Files) == 0 {
+		fmt.Println("  Nothing to pack")
+	}
+	for _, p := range plan.Parts() {
+		fmt.Printf("  %-24s %6d file(s)  %10s\n", p.Name, p.Files, humanSize(p.Size))
+	}
+	if len(plan.Files) > 0 {
+		fmt.Printf("  %-24s %6d file(s)  %10s\n", "total", len(plan.Files), humanSize(plan.Size()))
+	}
+	if len(plan.Skipped) > 0 {
+		fmt.Printf("\nSkipped:\n")
+		for _, s := range plan.Skipped {
+			fmt.Printf("  %-40s %s\n", s.Path, s.Reason)
+		}
+	}
+}
+
 func humanSize(b int64) string {
 	const unit = 1024
 	if b < unit {
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/profile"
+	"github.com/spf13/cobra"
+)
+
+var profileDescription string
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Save and switch between named perception configurations",
+}
+
+var profileSaveCmd = &cobra.Command{
+	Use:   "save <name>",
+	Short: "Save the agent's enabled plugins and overrides as a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if err := profile.ValidName(name); err != nil {
+			return err
+		}
+		cf, err := compose.Load(agentDir)
+		if err != nil {
+			return err
+		}
+		p, err := profile.Snapshot(cf, agentName, name)
+		if err != nil {
+			return err
+		}
+		// Re-saving keeps the hand-written parts of an existing profile.
+		if old, err := profile.Load(agentDir, name); err == nil {
+			p.Description = old.Description
+			p.When = old.When
+		}
+		if profileDescription != "" {
+			p.Description = profileDescription
+		}
+		if err := profile.Save(agentDir, p); err != nil {
+			return err
+		}
+		fmt.Printf("Saved profile %s: %d plugins enabled\n", name, len(p.Enabled))
+		return nil
+	},
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved profiles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profiles, errs := profile.List(agentDir)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", err)
+		}
+		if jsonOut {
+			return printJSON(profiles)
+		}
+		if len(profiles) == 0 {
+			fmt.Printf("  No profiles in %s\n", profile.Dir(agentDir))
+			return nil
+		}
+		for _, p := range profiles {
+			fmt.Printf("  %-20s %2d plugins  %s\n", p.Name, len(p.Enabled), p.Description)
+			if when := p.When.String(); when != "" {
+				fmt.Printf("  %-20s when: %s\n", "", when)
+			}
+		}
+		return nil
+	},
+}
+
+var profileApplyCmd = &cobra.Command{
+	Use:   "apply <name>",
+	Short: "Switch agent-compose.yaml to a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ch, err := profileChanges(args[0])
+		if err != nil {
+			return err
+		}
+		ch, pulls, err := compose.Resolve(agentDir, ch)
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			return printDryRun(fmt.Sprintf("Profile %s (dry run):", args[0]), ch, pulls)
+		}
+		if err := compose.ApplyChanges(agentDir, ch); err != nil {
+			return err
+		}
+		printPulls(pulls)
+		fmt.Printf("Applied profile %s: %d enabled, %d disabled\n", args[0], len(ch.Enable), len(ch.Disable))
+		return nil
+	},
+}
+
+var profileDiffCmd = &cobra.Command{
+	Use:   "diff <name>",
+	Short: "Show how agent-compose.yaml would change under a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ch, err := profileChanges(args[0])
+		if err != nil {
+			return err
+		}
+		ch, pulls, err := compose.Resolve(agentDir, ch)
+		if err != nil {
+			return err
+		}
+		return printDryRun(fmt.Sprintf("Profile %s:", args[0]), ch, pulls)
+	},
+}
+
+func init() {
+	profileSaveCmd.Flags().StringVar(&profileDescription, "description", "", "Profile description")
+	profileApplyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show changes without writing")
+	profileCmd.AddCommand(profileSaveCmd, profileListCmd, profileApplyCmd, profileDiffCmd)
+	rootCmd.AddCommand(profileCmd)
+}
+
+// profileChanges loads a profile and computes the edit for --agent.
+func profileChanges(name string) (compose.Changes, error) {
+	p, err := profile.Load(agentDir, name)
+	if err != nil {
+		return compose.Changes{}, err
+	}
+	return profileChangesFor(p)
+}
+
+func profileChangesFor(p *profile.Profile) (compose.Changes, error) {
+	cf, err := compose.Load(agentDir)
+	if err != nil {
+		return compose.Changes{}, err
+	}
+	return p.Changes(cf, agentName)
+}
+
+// profileSummary is a one-line description of a profile for messages.
+func profileSummary(p *profile.Profile) string {
+	s := p.Name
+	if when := p.When.String(); when != "" {
+		s += " (" + when + ")"
+	}
+	if len(p.Enabled) > 0 {
+		s += ": " + strings.Join(p.Enabled, ", ")
+	}
+	return s
+}
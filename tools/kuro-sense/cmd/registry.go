@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/lint"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+	"github.com/spf13/cobra"
+)
+
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Inspect the capability registry",
+}
+
+var registryLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Cross-check the registry, plugins/ and agent-compose.yaml",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report := lint.Run(agentDir, readAgent(), registry.All(), manifestErrs)
+
+		if jsonOut {
+			if err := printJSON(report); err != nil {
+				return err
+			}
+		} else {
+			printLintReport(report)
+		}
+
+		if !report.OK() {
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			return fmt.Errorf("registry lint: %d problem(s) found", len(report.Findings))
+		}
+		return nil
+	},
+}
+
+func init() {
+	registryCmd.AddCommand(registryLintCmd)
+	rootCmd.AddCommand(registryCmd)
+}
+
+func printLintReport(report lint.Report) {
+	fmt.Printf("  Checked %d capabilities, %d plugin scripts, %d compose entries\n",
+		report.Capabilities, report.Scripts, report.ComposeEntries)
+	if report.ComposeSkipped != "" {
+		fmt.Printf("  Compose not checked: %s\n", report.ComposeSkipped)
+	}
+	fmt.Println()
+
+	if report.OK() && report.ComposeSkipped != "" {
+		fmt.Println("  ✓ registry and plugins/ agree")
+		return
+	}
+	if report.OK() {
+		fmt.Println("  ✓ registry, plugins/ and agent-compose.yaml agree")
+		return
+	}
+
+	for _, f := range report.Findings {
+		fmt.Printf("  ✗ %-24s %s\n", f.Kind, f.Message)
+	}
+	fmt.Println()
+	fmt.Printf("  %d problem(s) found\n", len(report.Findings))
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rollbackSteps int
+	rollbackList  bool
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Restore agent-compose.yaml from the backups taken before each write",
+	Long: `Restore agent-compose.yaml from the backups taken before each write.
+The current file is backed up first, so running rollback again undoes
+the rollback.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rollbackList {
+			backups, err := compose.Backups(agentDir)
+			if err != nil {
+				return err
+			}
+			if jsonOut {
+				return printJSON(backups)
+			}
+			if len(backups) == 0 {
+				fmt.Println("  No backups")
+				return nil
+			}
+			for i, b := range backups {
+				fmt.Printf("  %2d  %s  %s\n", i+1, b.Time.Local().Format("2006-01-02 15:04:05"), filepath.Base(b.Path))
+			}
+			return nil
+		}
+
+		b, err := compose.Rollback(agentDir, rollbackSteps)
+		if err != nil {
+			return err
+		}
+		if jsonOut {
+			return printJSON(b)
+		}
+		fmt.Printf("Restored agent-compose.yaml from %s (%s)\n",
+			filepath.Base(b.Path), b.Time.Local().Format("2006-01-02 15:04:05"))
+		return nil
+	},
+}
+
+func init() {
+	rollbackCmd.Flags().IntVarP(&rollbackSteps, "steps", "n", 1, "Number of writes to undo")
+	rollbackCmd.Flags().BoolVar(&rollbackList, "list", false, "List backups, newest first")
+	rootCmd.AddCommand(rollbackCmd)
+}
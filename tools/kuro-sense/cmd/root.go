@@ -4,27 +4,59 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/detect"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/secrets"
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/tui"
 	"github.com/spf13/cobra"
 )
 
 var (
-	agentDir string
-	jsonOut  bool
+	agentDir    string
+	agentName   string
+	jsonOut     bool
+	secretsFile string
+
+	// manifestErrs holds per-file errors from loading capability manifests.
+	manifestErrs []registry.ManifestError
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "kuro-sense",
 	Short: "Perception capability manager for AI agents",
 	Long:  "Detect environment capabilities, configure agent perception plugins, install dependencies, and migrate agent data.",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		detect.SetEnvFile(secretsPath())
+		manifestErrs = registry.LoadManifests(agentDir)
+		for _, e := range manifestErrs {
+			fmt.Fprintf(os.Stderr, "warning: skipped manifest %s\n", e)
+		}
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return tui.Run(agentDir)
+		return tui.Run(agentDir, agentName)
 	},
 }
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&agentDir, "agent-dir", ".", "Agent project directory")
+	rootCmd.PersistentFlags().StringVar(&agentName, "agent", "", `Agent in agent-compose.yaml to configure ("all" for every agent; default: the only one)`)
 	rootCmd.PersistentFlags().BoolVar(&jsonOut, "json", false, "Output as JSON")
+	rootCmd.PersistentFlags().StringVar(&secretsFile, "secrets-file", "", "Secrets file the agent loads (default: $"+secrets.FileEnv+" or <agent-dir>/.env)")
+}
+
+// secretsPath is the secrets file detection and the secrets command use.
+func secretsPath() string {
+	return secrets.Path(agentDir, secretsFile)
+}
+
+// readAgent is the agent selector for read-only commands, which look at
+// every agent unless --agent picks one.
+func readAgent() string {
+	if agentName == "" {
+		return compose.AllAgents
+	}
+	return agentName
 }
 
 func Execute() {
@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var secretsForce bool
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Set and check the credentials plugins read from the environment",
+	Long: `Manage the environment variables capabilities depend on, such as API
+keys and bot tokens. Values are written to the agent's .env (or the file
+given by --secrets-file) with mode 0600; the agent loads that file at
+startup, and detection reads it too. Values are never printed.`,
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set <NAME> [value]",
+	Short: "Write a value into the secrets file",
+	Long: `Write a value into the secrets file. Without a value argument it is
+read from standard input, so it stays out of shell history:
+
+  kuro-sense secrets set XAI_API_KEY
+  pass show xai | kuro-sense secrets set XAI_API_KEY
+
+The value is checked against the format the registry expects for the
+variable; --force writes it anyway.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if !secrets.ValidKey(name) {
+			return fmt.Errorf("invalid variable name %q", name)
+		}
+		cmd.SilenceUsage = true
+		v, known := lookupEnvVar(name)
+
+		var value string
+		if len(args) == 2 {
+			value = args[1]
+		} else {
+			var err error
+			if value, err = readSecret(name, v.Format); err != nil {
+				return err
+			}
+		}
+		if err := v.Format.Validate(value); err != nil {
+			if !secretsForce {
+				return fmt.Errorf("%s: %w (use --force to write it anyway)", name, err)
+			}
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", name, err)
+		}
+
+		path := secretsPath()
+		f, err := secrets.Load(path)
+		if err != nil {
+			return err
+		}
+		if err := f.Set(name, value); err != nil {
+			return err
+		}
+		if err := f.Save(); err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Set %s in %s (mode 0600)\n", name, path)
+		if !known {
+			fmt.Printf("  No capability uses %s.\n", name)
+		}
+		if env, ok := os.LookupEnv(name); ok && env != value {
+			fmt.Printf("  %s is also set in your environment; the agent keeps that value instead.\n", name)
+		}
+		return nil
+	},
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the variables capabilities use and where each is set",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		statuses, err := secretStatuses()
+		if err != nil {
+			return err
+		}
+		if jsonOut {
+			return printJSON(statuses)
+		}
+		printSecretStatuses(statuses)
+		return nil
+	},
+}
+
+var secretsCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check that required variables are set and well-formed",
+	Long: `Check that every variable required by an enabled capability is set and
+that each set value matches its expected format. Without agent-compose.yaml,
+every capability counts as enabled. Exits non-zero on any problem.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		statuses, err := secretStatuses()
+		if err != nil {
+			return err
+		}
+		enabled := enabledCapabilities()
+
+		var problems []string
+		path := secretsPath()
+		if mode, bad := secrets.Insecure(path); bad {
+			problems = append(problems, fmt.Sprintf("%s is mode %04o; run chmod 600 %s", path, mode, path))
+		}
+		for _, s := range statuses {
+			switch {
+			case s.Problem != "":
+				problems = append(problems, fmt.Sprintf("%s: %s", s.Name, s.Problem))
+			case s.Source == "" && s.Required && usedBy(s, enabled):
+				problems = append(problems, fmt.Sprintf("%s: not set (required by %s); run kuro-sense secrets set %s",
+					s.Name, strings.Join(s.Capabilities, ", "), s.Name))
+			}
+		}
+
+		if jsonOut {
+			if err := printJSON(map[string]any{"ok": len(problems) == 0, "problems": problems, "variables": statuses}); err != nil {
+				return err
+			}
+		} else if len(problems) == 0 {
+			fmt.Printf("✓ %d variables OK\n", len(statuses))
+		} else {
+			for _, p := range problems {
+				fmt.Printf("  ✗ %s\n", p)
+			}
+		}
+		if len(problems) > 0 {
+			cmd.SilenceErrors = true
+			return fmt.Errorf("secrets check: %d problem(s) found", len(problems))
+		}
+		return nil
+	},
+}
+
+func init() {
+	secretsSetCmd.Flags().BoolVar(&secretsForce, "force", false, "Write the value even if it does not match the expected format")
+	secretsCmd.AddCommand(secretsSetCmd, secretsListCmd, secretsCheckCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
+
+// secretStatus describes one variable without its value.
+type secretStatus struct {
+	Name         string   `json:"name"`
+	Source       string   `json:"source,omitempty"` // "file", "environment", or "" when unset
+	Required     bool     `json:"required"`
+	Capabilities []string `json:"capabilities,omitempty"`
+	Format       string   `json:"format,omitempty"`
+	Problem      string   `json:"problem,omitempty"` // why the value is rejected
+}
+
+// lookupEnvVar returns the registry entry for a variable.
+func lookupEnvVar(name string) (registry.EnvVar, bool) {
+	for _, v := range registry.EnvVars(registry.All()) {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return registry.EnvVar{Name: name}, false
+}
+
+// secretStatuses reports every variable a capability uses, then any other
+// variables in the secrets file. The value checked is the one the agent
+// ends up with: the environment wins over the file.
+func secretStatuses() ([]secretStatus, error) {
+	f, err := secrets.Load(secretsPath())
+	if err != nil {
+		return nil, err
+	}
+	fileValues := f.Values()
+
+	vars := registry.EnvVars(registry.All())
+	known := make(map[string]bool)
+	for _, v := range vars {
+		known[v.Name] = true
+	}
+	var extra []string
+	for name := range fileValues {
+		if !known[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		vars = append(vars, registry.EnvVar{Name: name})
+	}
+
+	out := make([]secretStatus, 0, len(vars))
+	for _, v := range vars {
+		s := secretStatus{
+			Name:         v.Name,
+			Required:     v.Required,
+			Capabilities: v.Capabilities,
+			Format:       v.Format.Describe(),
+		}
+		value := os.Getenv(v.Name)
+		if value != "" {
+			s.Source = "environment"
+		} else if value = fileValues[v.Name]; value != "" {
+			s.Source = "file"
+		}
+		if s.Source != "" {
+			if err := v.Format.Validate(value); err != nil {
+				s.Problem = err.Error()
+			}
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func printSecretStatuses(statuses []secretStatus) {
+	path := secretsPath()
+	fmt.Printf("Secrets file: %s\n", path)
+	if mode, bad := secrets.Insecure(path); bad {
+		fmt.Printf("  warning: mode %04o lets other users read it; run chmod 600 %s\n", mode, path)
+	}
+	fmt.Println()
+	for _, s := range statuses {
+		icon, state := "✓", "set in "+s.Source
+		switch {
+		case s.Source == "" && s.Required:
+			icon, state = "✗", "not set"
+		case s.Source == "":
+			icon, state = "·", "not set"
+		case s.Problem != "":
+			icon, state = "⚠", fmt.Sprintf("set in %s, %s", s.Source, s.Problem)
+		}
+		fmt.Printf("  %s %-22s %s\n", icon, s.Name, state)
+		if len(s.Capabilities) == 0 {
+			fmt.Printf("    %-22s not used by any capability\n", "")
+		} else {
+			fmt.Printf("    %-22s used by %s\n", "", strings.Join(s.Capabilities, ", "))
+		}
+		if s.Format != "" && s.Source == "" {
+			fmt.Printf("    %-22s %s\n", "", s.Format)
+		}
+	}
+}
+
+// enabledCapabilities returns the capabilities enabled for the selected
+// agents, or nil when there is no compose file to say.
+func enabledCapabilities() []string {
+	cf, err := compose.Load(agentDir)
+	if err != nil {
+		return nil
+	}
+	names, err := compose.GetEnabledPluginNames(cf, readAgent())
+	if err != nil {
+		return nil
+	}
+	return append([]string{}, names...) // non-nil: none enabled is not "no list"
+}
+
+// usedBy reports whether an enabled capability uses s; with no enabled
+// list every capability counts.
+func usedBy(s secretStatus, enabled []string) bool {
+	if enabled == nil {
+		return true
+	}
+	for _, c := range s.Capabilities {
+		if slices.Contains(enabled, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// readSecret reads a value from standard input. On a terminal it prompts
+// and turns off echo while the value is typed.
+func readSecret(name string, format registry.SecretFormat) (string, error) {
+	var line string
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		if d := format.Describe(); d != "" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, d)
+		}
+		if line, err = promptHidden("Value for " + name + ": "); err != nil {
+			return "", fmt.Errorf("read value for %s: %w", name, err)
+		}
+	} else {
+		var err error
+		line, err = bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("read value for %s: no input", name)
+		}
+	}
+	value := strings.TrimRight(line, "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("no value given for %s", name)
+	}
+	return value, nil
+}
+
+// setEcho turns terminal echo on or off with stty.
+func setEcho(on bool) error {
+	arg := "-echo"
+	if on {
+		arg = "echo"
+	}
+	c := exec.Command("stty", arg)
+	c.Stdin = os.Stdin
+	return c.Run()
+}
@@ -19,7 +19,7 @@ var serveCmd = &cobra.Command{
 			fmt.Printf("Open in browser: http://%s:%d\n", ip, servePort)
 		}
 		fmt.Printf("Listening on http://localhost:%d\n", servePort)
-		return web.Serve(servePort, agentDir)
+		return web.Serve(servePort, agentDir, agentName)
 	},
 }
 
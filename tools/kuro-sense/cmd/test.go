@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/runner"
+	"github.com/spf13/cobra"
+)
+
+var testAll bool
+
+var testCmd = &cobra.Command{
+	Use:   "test [plugin]",
+	Short: "Smoke-run plugin scripts the way the agent would",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if testAll {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var specs []runner.Spec
+		if testAll {
+			cf, err := compose.Load(agentDir)
+			if err != nil {
+				return err
+			}
+			perceptions, err := compose.GetCustomPerceptions(cf, readAgent())
+			if err != nil {
+				return err
+			}
+			for _, p := range perceptions {
+				if p.Enabled == nil || *p.Enabled {
+					specs = append(specs, composeSpec(p))
+				}
+			}
+			if len(specs) == 0 {
+				return fmt.Errorf("no enabled plugins in agent-compose.yaml")
+			}
+		} else {
+			spec, err := findSpec(args[0])
+			if err != nil {
+				return err
+			}
+			specs = []runner.Spec{spec}
+		}
+
+		env := runner.AgentEnv(secretsPath())
+		results := runner.RunAll(cmd.Context(), agentDir, specs, env)
+
+		if jsonOut {
+			if err := printJSON(results); err != nil {
+				return err
+			}
+		} else if testAll {
+			printTestTable(results)
+		} else {
+			printTestResult(results[0])
+		}
+
+		failed := 0
+		for _, r := range results {
+			if !r.OK() {
+				failed++
+			}
+		}
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			return fmt.Errorf("test: %d of %d plugin(s) failed", failed, len(results))
+		}
+		return nil
+	},
+}
+
+func init() {
+	testCmd.Flags().BoolVar(&testAll, "all", false, "Run every enabled plugin from agent-compose.yaml in parallel")
+	rootCmd.AddCommand(testCmd)
+}
+
+// findSpec resolves a plugin by name. The compose entry wins because it is
+// what the agent actually runs; the registry fills in the rest.
+func findSpec(name string) (runner.Spec, error) {
+	if cf, err := compose.Load(agentDir); err == nil {
+		perceptions, _ := compose.GetCustomPerceptions(cf, readAgent())
+		for _, p := range perceptions {
+			if p.Name == name {
+				return composeSpec(p), nil
+			}
+		}
+	}
+	if cap := registry.ByName(name); cap != nil {
+		return runner.Spec{
+			Name:    cap.Name,
+			Script:  cap.Script,
+			Timeout: time.Duration(cap.Timeout) * time.Millisecond,
+		}, nil
+	}
+	return runner.Spec{}, fmt.Errorf("unknown plugin: %s", name)
+}
+
+func composeSpec(p compose.ComposePerception) runner.Spec {
+	spec := runner.Spec{Name: p.Name, Script: p.Script}
+	if p.Timeout != nil {
+		spec.Timeout = time.Duration(*p.Timeout) * time.Millisecond
+	}
+	if p.OutputCap != nil {
+		spec.OutputCap = *p.OutputCap
+	}
+	if spec.Timeout == 0 {
+		if cap := registry.ByName(p.Name); cap != nil {
+			spec.Timeout = time.Duration(cap.Timeout) * time.Millisecond
+		}
+	}
+	return spec
+}
+
+func printTestResult(r runner.Result) {
+	icon := "✓"
+	if !r.OK() {
+		icon = "✗"
+	}
+	fmt.Printf("  %s %s (%s)\n\n", icon, r.Name, r.Script)
+	fmt.Printf("    Exit code:  %d\n", r.ExitCode)
+	fmt.Printf("    Duration:   %dms (timeout %dms)\n", r.DurationMs, r.TimeoutMs)
+	fmt.Printf("    Output:     %d / %d chars%s\n", r.OutputLen, r.OutputCap, truncNote(r))
+	if r.Wrapped {
+		fmt.Printf("    Wrapped:    yes, as <%s>...</%s>\n", r.Name, r.Name)
+	} else {
+		fmt.Println("    Wrapped:    no (the agent would inject nothing)")
+	}
+	if r.Error != "" {
+		fmt.Printf("    Error:      %s\n", r.Error)
+	}
+	if r.Stderr != "" {
+		fmt.Println("    Stderr:")
+		printIndented(r.Stderr, 10)
+	}
+	if r.Stdout != "" {
+		fmt.Println("    Stdout:")
+		printIndented(r.Stdout, 10)
+	}
+}
+
+func printTestTable(results []runner.Result) {
+	fmt.Printf("  %-22s %-5s %6s %10s  %-15s %s\n", "PLUGIN", "EXIT", "TIME", "TIMEOUT", "OUTPUT", "WRAPPED")
+	for _, r := range results {
+		icon := "✓"
+		if !r.OK() {
+			icon = "✗"
+		}
+		exit := fmt.Sprintf("%d", r.ExitCode)
+		if r.TimedOut {
+			exit = "T/O"
+		}
+		output := fmt.Sprintf("%d/%d", r.OutputLen, r.OutputCap)
+		if r.Truncated {
+			output += "!"
+		}
+		wrapped := "no"
+		if r.Wrapped {
+			wrapped = "yes"
+		}
+		fmt.Printf("%s %-22s %-5s %5dms %8dms  %-15s %s\n", icon, r.Name, exit, r.DurationMs, r.TimeoutMs, output, wrapped)
+	}
+}
+
+func truncNote(r runner.Result) string {
+	if r.Truncated {
+		return " (over cap: the agent truncates)"
+	}
+	return ""
+}
+
+// printIndented prints the first n lines of s, indented.
+func printIndented(s string, n int) {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if i == n {
+			fmt.Printf("      … %d more lines\n", len(lines)-n)
+			break
+		}
+		fmt.Printf("      %s\n", line)
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/pack"
@@ -8,20 +9,68 @@ import (
 )
 
 var (
-	unpackForce bool
-	unpackDest  string
+	unpackForce  bool
+	unpackDest   string
+	unpackHome   string
+	unpackStrict bool
+	unpackPlan   bool
+	requireSig   bool
 )
 
 var unpackCmd = &cobra.Command{
-	Use:   "unpack <archive>",
+	Use:   "unpack <archive> [incremental-archive...]",
 	Short: "Restore agent data from a pack archive",
-	Args:  cobra.ExactArgs(1),
+	Long: `Restore agent data from a pack archive: agent files into --dest and
+.mini-agent instance data into your home directory.
+
+Several archives are applied in order: a full archive followed by the
+incremental archives made with pack --since, each of which must build on
+the one before it. An incremental archive on its own is applied only if
+--dest was last brought up to the pack it builds on.
+
+Each file is compared with the copy from the last unpack into --dest:
+archive changes replace unchanged local files, local changes stay, and
+text files changed on both sides get a line-based three-way merge. What
+cannot be merged keeps the local copy and gets the archive's version in
+a .conflict file next to it. --plan shows all of this without writing
+anything; --force makes the archive copy win everywhere.
+
+Encrypted archives are decrypted with your identity or a passphrase (from
+$KURO_SENSE_PASSPHRASE or a prompt). A signed archive is only unpacked if
+its signer is trusted, and then always as if --strict were given.`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		dest := unpackDest
 		if dest == "" {
 			dest = agentDir
 		}
-		if err := pack.Unpack(args[0], dest, unpackForce); err != nil {
+		// Execute prints the error; a rejection list reads best once.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		if jsonOut && !unpackPlan {
+			return fmt.Errorf("--json needs --plan: unpack reports what it did as text")
+		}
+		vopts, err := verifyOptions(requireSig)
+		if err != nil {
+			return err
+		}
+		opts := pack.UnpackOptions{Dest: dest, HomeDir: unpackHome, Force: unpackForce, Strict: unpackStrict, VerifyOptions: vopts}
+		if unpackPlan {
+			plan, err := pack.PlanUnpack(args, opts)
+			if err != nil {
+				return err
+			}
+			if jsonOut {
+				return printJSON(plan)
+			}
+			printUnpackPlan(plan)
+			return nil
+		}
+		if err := pack.Unpack(args, opts); err != nil {
+			var verr *pack.VerifyError
+			if errors.As(err, &verr) {
+				printVerifyReport(verr.Report)
+			}
 			return err
 		}
 		fmt.Printf("✓ Unpacked to %s\n", dest)
@@ -30,7 +79,41 @@ var unpackCmd = &cobra.Command{
 }
 
 func init() {
-	unpackCmd.Flags().BoolVar(&unpackForce, "force", false, "Overwrite newer files")
+	unpackCmd.Flags().BoolVar(&unpackForce, "force", false, "Let archive copies replace local changes instead of merging")
+	unpackCmd.Flags().BoolVar(&unpackPlan, "plan", false, "Show what unpack would do to each file and exit")
 	unpackCmd.Flags().StringVar(&unpackDest, "dest", "", "Destination directory (default: --agent-dir)")
+	unpackCmd.Flags().BoolVar(&unpackStrict, "strict", false, "Stage files and move them into place only if the whole archive verifies")
+	unpackCmd.Flags().StringVar(&unpackHome, "home", "", "Where .mini-agent instance data is restored (default: your home directory)")
+	addArchiveKeyFlags(unpackCmd)
 	rootCmd.AddCommand(unpackCmd)
 }
+
+// printUnpackPlan lists every file unpack would touch, then the totals.
+func printUnpackPlan(plan *pack.UnpackPlan) {
+	base := "no earlier unpack (files that differ become conflicts)"
+	if plan.Base != "" {
+		base = "pack " + shortPackID(plan.Base)
+	}
+	fmt.Printf("Unpack plan for %s, base: %s\n\n", plan.Dest, base)
+
+	counts := map[pack.Class]int{}
+	for _, c := range plan.Changes {
+		counts[c.Class]++
+		if c.Action == pack.ActNone {
+			continue
+		}
+		line := fmt.Sprintf("  %-9s %-40s %s", c.Action, c.Path, c.Class)
+		if c.Note != "" {
+			line += ": " + c.Note
+		}
+		if c.ConflictFile != "" {
+			line += " → " + c.ConflictFile
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("\n%d file(s): %d unchanged, %d only local, %d only in the archive, %d changed on both sides\n",
+		len(plan.Changes), counts[pack.Unchanged], counts[pack.OnlyLocal], counts[pack.OnlyArchive], counts[pack.BothChanged])
+	fmt.Printf("Would write %d, merge %d, keep %d local, delete %d; %d conflict(s)\n",
+		plan.Count(pack.ActWrite), plan.Count(pack.ActMerge), plan.Count(pack.ActKeep),
+		plan.Count(pack.ActDelete), plan.Count(pack.ActConflict))
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/pack"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <archive>",
+	Short: "Check a pack archive against its manifest without extracting it",
+	Long: `Check every file in a pack archive against the size and SHA-256 in its
+manifest, without extracting anything. Reports files missing from the
+archive, files the manifest does not list, corrupted files, and entries
+unpack would refuse. Encrypted archives are decrypted with your identity
+or a passphrase; a signed archive verifies only if its signer is trusted.
+Exits non-zero unless the archive verifies.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Execute prints the error; reports read best without usage.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		opts, err := verifyOptions(requireSig)
+		if err != nil {
+			return err
+		}
+		report, err := pack.Verify(args[0], opts)
+		if err != nil {
+			return err
+		}
+
+		if jsonOut {
+			if err := printJSON(report); err != nil {
+				return err
+			}
+		} else {
+			printVerifyReport(report)
+		}
+
+		if !report.OK() {
+			return fmt.Errorf("verify: %d problem(s) found", report.Count())
+		}
+		return nil
+	},
+}
+
+func init() {
+	addArchiveKeyFlags(verifyCmd)
+	rootCmd.AddCommand(verifyCmd)
+}
+
+// addArchiveKeyFlags adds the flags for reading encrypted and signed
+// archives.
+func addArchiveKeyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&identityFile, "identity", "", "Identity file (default: $KURO_SENSE_IDENTITY or the user config dir)")
+	cmd.Flags().StringVar(&trustedKeysFile, "trusted-keys", "", "Trusted keys file (default: $KURO_SENSE_TRUSTED_KEYS or the user config dir)")
+	cmd.Flags().BoolVar(&requireSig, "require-signature", false, "Refuse archives that are not signed by a trusted key")
+}
+
+func printVerifyReport(r *pack.VerifyReport) {
+	fmt.Printf("%s (format version %d)\n", r.Archive, r.Version)
+	if r.Base != "" {
+		fmt.Printf("  incremental: pack %s on pack %s, %d deletion(s)\n", r.ID, r.Base, r.Deleted)
+	} else if r.ID != "" {
+		fmt.Printf("  full: pack %s\n", r.ID)
+	}
+	if r.Encrypted {
+		fmt.Println("  encrypted")
+	}
+	switch {
+	case r.SignatureProblem != "":
+		fmt.Printf("  ✗ %s\n", r.SignatureProblem)
+	case r.Signer != "":
+		fmt.Printf("  signed by %s (trusted)\n", r.Signer)
+	default:
+		fmt.Println("  not signed")
+	}
+	for _, p := range r.Problems {
+		fmt.Printf("  ✗ %s\n", p)
+	}
+	for _, c := range r.Corrupted {
+		fmt.Printf("  ✗ corrupted  %s\n", c.Path)
+		if c.Size != c.WantSize {
+			fmt.Printf("               size %d, manifest says %d\n", c.Size, c.WantSize)
+		}
+		fmt.Printf("               sha256 %s, manifest says %s\n", c.SHA256, c.WantSHA256)
+	}
+	for _, p := range r.Missing {
+		fmt.Printf("  ✗ missing    %s\n", p)
+	}
+	for _, p := range r.Extra {
+		fmt.Printf("  ✗ extra      %s\n", p)
+	}
+	for _, rej := range r.Rejected {
+		fmt.Printf("  ✗ unsafe     %s: %s\n", rej.Name, rej.Reason)
+	}
+	if r.OK() {
+		fmt.Printf("✓ %d/%d files verified\n", r.Verified, r.Files)
+	} else {
+		fmt.Printf("  %d/%d files verified\n", r.Verified, r.Files)
+	}
+}
@@ -0,0 +1,65 @@
+package compose
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AllAgents selects every agent in the compose file.
+const AllAgents = "all"
+
+// AgentSummary describes one agent for listings.
+type AgentSummary struct {
+	ID      string `json:"id"` // key under agents:
+	Name    string `json:"name,omitempty"`
+	Port    int    `json:"port,omitempty"`
+	Plugins int    `json:"plugins"` // custom perception entries
+	Enabled int    `json:"enabled"`
+}
+
+// ListAgents summarizes the agents in file order.
+func ListAgents(cf *ComposeFile) []AgentSummary {
+	var out []AgentSummary
+	for _, id := range cf.AgentIDs() {
+		agent := cf.Agents[id]
+		s := AgentSummary{ID: id, Name: agent.Name}
+		if agent.Port != nil {
+			s.Port = *agent.Port
+		}
+		if agent.Perception != nil {
+			s.Plugins = len(agent.Perception.Custom)
+			for _, p := range agent.Perception.Custom {
+				if p.Enabled == nil || *p.Enabled {
+					s.Enabled++
+				}
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// SelectAgents resolves an agent selector against the agent keys of a
+// compose file. An empty selector means the only agent and is an error
+// when there are several; AllAgents selects every agent.
+func SelectAgents(ids []string, agent string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no agents in compose file")
+	}
+	switch agent {
+	case AllAgents:
+		return ids, nil
+	case "":
+		if len(ids) > 1 {
+			return nil, fmt.Errorf("compose file has %d agents (%s); pick one or use %q",
+				len(ids), strings.Join(ids, ", "), AllAgents)
+		}
+		return ids, nil
+	}
+	for _, id := range ids {
+		if id == agent {
+			return []string{id}, nil
+		}
+	}
+	return nil, fmt.Errorf("no agent %q in compose file (have: %s)", agent, strings.Join(ids, ", "))
+}
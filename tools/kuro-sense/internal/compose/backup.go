@@ -0,0 +1,124 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/fsutil"
+)
+
+const (
+	// MaxBackups is how many compose backups are kept; older ones are pruned.
+	MaxBackups = 50
+
+	composeFile      = "agent-compose.yaml"
+	backupPrefix     = "agent-compose."
+	backupSuffix     = ".yaml"
+	backupTimeLayout = "20060102-150405.000000"
+)
+
+// Backup is a copy of agent-compose.yaml taken before kuro-sense rewrote it.
+type Backup struct {
+	Path string    `json:"path"`
+	Time time.Time `json:"time"`
+}
+
+// BackupDir is where compose backups are kept.
+func BackupDir(agentDir string) string {
+	return filepath.Join(agentDir, ".kuro-sense", "backups")
+}
+
+// Backups lists compose backups, newest first.
+func Backups(agentDir string) ([]Backup, error) {
+	entries, err := os.ReadDir(BackupDir(agentDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read backups: %w", err)
+	}
+	var out []Backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix)
+		t, err := time.Parse(backupTimeLayout, stamp)
+		if err != nil {
+			continue
+		}
+		out = append(out, Backup{Path: filepath.Join(BackupDir(agentDir), name), Time: t})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.After(out[j].Time) })
+	return out, nil
+}
+
+// Rollback restores the compose file from the n-th newest backup, undoing
+// the last n writes. The n backups it consumes are removed, and the file
+// it replaces is backed up first, so the rollback itself can be undone
+// with another rollback.
+func Rollback(agentDir string, n int) (Backup, error) {
+	backups, err := Backups(agentDir)
+	if err != nil {
+		return Backup{}, err
+	}
+	if n < 1 || n > len(backups) {
+		return Backup{}, fmt.Errorf("cannot roll back %d step(s): %d backup(s) in %s", n, len(backups), BackupDir(agentDir))
+	}
+	target := backups[n-1]
+	data, err := os.ReadFile(target.Path)
+	if err != nil {
+		return Backup{}, fmt.Errorf("read backup: %w", err)
+	}
+	if err := writeCompose(agentDir, data); err != nil {
+		return Backup{}, err
+	}
+	for _, b := range backups[:n] {
+		os.Remove(b.Path)
+	}
+	return target, nil
+}
+
+// writeCompose backs up the current compose file, then atomically
+// replaces it with data.
+func writeCompose(agentDir string, data []byte) error {
+	if err := backupCompose(agentDir); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(filepath.Join(agentDir, composeFile), data, 0o644)
+}
+
+// backupCompose copies the compose file into BackupDir, if it exists, and
+// prunes backups beyond MaxBackups.
+func backupCompose(agentDir string) error {
+	data, err := os.ReadFile(filepath.Join(agentDir, composeFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read compose file: %w", err)
+	}
+
+	dir := BackupDir(agentDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create backup dir: %w", err)
+	}
+	name := backupPrefix + time.Now().UTC().Format(backupTimeLayout) + backupSuffix
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, name), data, 0o644); err != nil {
+		return fmt.Errorf("back up compose file: %w", err)
+	}
+
+	backups, err := Backups(agentDir)
+	if err != nil {
+		return nil // the backup itself succeeded
+	}
+	for _, b := range backups[min(len(backups), MaxBackups):] {
+		os.Remove(b.Path)
+	}
+	return nil
+}
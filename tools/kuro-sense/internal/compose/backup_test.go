@@ -0,0 +1,38 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRollbackCanBeUndone(t *testing.T) {
+	src := "agents:\n  a:\n    perception:\n      custom:\n        - name: x\n          script: ./x.sh\n"
+	dir := composeDir(t, src)
+	if err := ApplyChanges(dir, Changes{Disable: []string{"x"}}); err != nil {
+		t.Fatal(err)
+	}
+	changed := readCompose(t, dir)
+
+	if _, err := Rollback(dir, 1); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if got := readCompose(t, dir); got != src {
+		t.Errorf("after rollback:\n%s\nwant:\n%s", got, src)
+	}
+	if _, err := Rollback(dir, 1); err != nil {
+		t.Fatalf("second Rollback: %v", err)
+	}
+	if got := readCompose(t, dir); got != changed {
+		t.Errorf("rolling back the rollback gave:\n%s\nwant:\n%s", got, changed)
+	}
+}
+
+func readCompose(t *testing.T, dir string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, composeFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
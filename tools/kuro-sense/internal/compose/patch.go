@@ -0,0 +1,390 @@
+package compose
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Re-encoding a yaml.Node tree keeps comments but reflows quoting,
+// indentation, blank lines and inline comments. render instead patches the
+// original bytes: it compares the parsed tree with the edited one and
+// rewrites only the scalars that changed and the lines that were added.
+// The result is re-parsed and must match the edited tree exactly. Edits the
+// patcher cannot express (flow collections, multi-line scalars, ...) are
+// refused rather than silently reformatting the whole file.
+
+// render returns the bytes for edited, a modified copy of orig, which was
+// parsed from src.
+func render(src []byte, orig, edited *yaml.Node) ([]byte, error) {
+	patched, err := patchYAML(src, orig, edited)
+	if err == nil && !sameContent(patched, edited) {
+		err = fmt.Errorf("patched file does not match the edit")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot edit %s in place: %w (edit it by hand)", composeFile, err)
+	}
+	return patched, nil
+}
+
+// sameContent reports whether data parses to the same values as doc.
+func sameContent(data []byte, doc *yaml.Node) bool {
+	var want, got interface{}
+	if err := doc.Decode(&want); err != nil {
+		return false
+	}
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+// textEdit replaces src[start:end] with text; start == end inserts.
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+type patcher struct {
+	src   []byte
+	lines []string // without line terminators
+	start []int    // byte offset of each line
+	unit  int      // indentation step for new nested blocks
+	edits []textEdit
+}
+
+// patchYAML rewrites src so it parses to edited. edited may differ from
+// orig only by changed scalars, keys appended to mappings, items appended
+// to sequences, and empty values turned into blocks.
+func patchYAML(src []byte, orig, edited *yaml.Node) ([]byte, error) {
+	p := &patcher{src: src, unit: indentUnit(orig)}
+	off := 0
+	for _, l := range strings.SplitAfter(string(src), "\n") {
+		if l == "" {
+			break
+		}
+		p.start = append(p.start, off)
+		p.lines = append(p.lines, strings.TrimRight(l, "\r\n"))
+		off += len(l)
+	}
+	p.start = append(p.start, off)
+
+	if err := p.walk(orig, edited, nil); err != nil {
+		return nil, err
+	}
+	return p.apply(), nil
+}
+
+func (p *patcher) walk(o, e, key *yaml.Node) error {
+	if o.Kind != e.Kind {
+		return p.fillEmpty(o, e, key)
+	}
+	switch o.Kind {
+	case yaml.DocumentNode:
+		if len(o.Content) != len(e.Content) {
+			return fmt.Errorf("document shape changed")
+		}
+		for i := range o.Content {
+			if err := p.walk(o.Content[i], e.Content[i], nil); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if o.Value == e.Value && o.ShortTag() == e.ShortTag() {
+			return nil
+		}
+		return p.replaceScalar(o, e)
+	case yaml.MappingNode:
+		if len(e.Content) < len(o.Content) {
+			return fmt.Errorf("line %d: keys removed", o.Line)
+		}
+		for i := 0; i < len(o.Content)-1; i += 2 {
+			if o.Content[i].Value != e.Content[i].Value {
+				return fmt.Errorf("line %d: keys reordered", o.Line)
+			}
+			if err := p.walk(o.Content[i+1], e.Content[i+1], o.Content[i]); err != nil {
+				return err
+			}
+		}
+		if extra := e.Content[len(o.Content):]; len(extra) > 0 {
+			if len(o.Content) == 0 {
+				return p.fillFlow(o, &yaml.Node{Kind: yaml.MappingNode, Content: extra}, key)
+			}
+			return p.appendBlock(o, &yaml.Node{Kind: yaml.MappingNode, Content: extra})
+		}
+	case yaml.SequenceNode:
+		if len(e.Content) < len(o.Content) {
+			return fmt.Errorf("line %d: items removed", o.Line)
+		}
+		for i := range o.Content {
+			if err := p.walk(o.Content[i], e.Content[i], nil); err != nil {
+				return err
+			}
+		}
+		if extra := e.Content[len(o.Content):]; len(extra) > 0 {
+			if len(o.Content) == 0 {
+				return p.fillFlow(o, &yaml.Node{Kind: yaml.SequenceNode, Content: extra}, key)
+			}
+			return p.appendBlock(o, &yaml.Node{Kind: yaml.SequenceNode, Content: extra})
+		}
+	case yaml.AliasNode:
+		if o.Value != e.Value {
+			return fmt.Errorf("line %d: alias changed", o.Line)
+		}
+	}
+	return nil
+}
+
+// replaceScalar rewrites a single-line scalar in place, keeping whatever
+// follows it on the line (such as an inline comment).
+func (p *patcher) replaceScalar(o, e *yaml.Node) error {
+	start, end, ok := p.scalarRange(o)
+	if !ok {
+		return fmt.Errorf("line %d: cannot locate scalar", o.Line)
+	}
+	text, err := encodeNode(&yaml.Node{Kind: yaml.ScalarNode, Tag: e.Tag, Value: e.Value, Style: e.Style})
+	if err != nil {
+		return err
+	}
+	text = strings.TrimSuffix(text, "\n")
+	if strings.Contains(text, "\n") {
+		return fmt.Errorf("line %d: replacement spans lines", o.Line)
+	}
+	p.edits = append(p.edits, textEdit{start: start, end: end, text: text})
+	return nil
+}
+
+// fillEmpty handles a key whose empty value ("key:" with nothing after it)
+// became a block, by inserting the block below the key.
+func (p *patcher) fillEmpty(o, e, key *yaml.Node) error {
+	if key == nil || o.Kind != yaml.ScalarNode || o.ShortTag() != "!!null" || o.Value != "" {
+		return fmt.Errorf("line %d: node kind changed", o.Line)
+	}
+	if e.Kind != yaml.MappingNode && e.Kind != yaml.SequenceNode || e.Style&yaml.FlowStyle != 0 {
+		return fmt.Errorf("line %d: node kind changed", o.Line)
+	}
+	if len(e.Content) == 0 {
+		return fmt.Errorf("line %d: empty block", o.Line)
+	}
+	indent := key.Column - 1 + p.unit
+	return p.insertAfter(key.Line, e, indent)
+}
+
+// fillFlow handles a key whose empty flow value ("key: []" or "key: {}")
+// gained entries, by dropping the brackets and inserting a block below the
+// key.
+func (p *patcher) fillFlow(o, extra, key *yaml.Node) error {
+	if key == nil || o.Style&yaml.FlowStyle == 0 || o.Line != key.Line {
+		return fmt.Errorf("line %d: cannot append to empty collection", o.Line)
+	}
+	line := p.lines[o.Line-1]
+	col := byteOffset(line, o.Column)
+	if col < 0 {
+		return fmt.Errorf("line %d: cannot locate collection", o.Line)
+	}
+	open, closing := "[", "]"
+	if o.Kind == yaml.MappingNode {
+		open, closing = "{", "}"
+	}
+	rest := line[col:]
+	if !strings.HasPrefix(rest, open) || !strings.HasPrefix(strings.TrimLeft(rest[1:], " \t"), closing) {
+		return fmt.Errorf("line %d: cannot locate collection", o.Line)
+	}
+	end := col + len(rest) - len(strings.TrimLeft(rest[1:], " \t")) + 1
+	start := len(strings.TrimRight(line[:col], " \t"))
+	p.edits = append(p.edits, textEdit{start: p.start[o.Line-1] + start, end: p.start[o.Line-1] + end})
+	return p.insertAfter(key.Line, extra, key.Column-1+p.unit)
+}
+
+// appendBlock adds extra keys or items after the last line of a block
+// mapping or sequence, at the indentation of its existing entries.
+func (p *patcher) appendBlock(o, extra *yaml.Node) error {
+	if o.Style&yaml.FlowStyle != 0 || len(o.Content) == 0 {
+		return fmt.Errorf("line %d: cannot append to flow collection", o.Line)
+	}
+	last, err := p.lastLine(o)
+	if err != nil {
+		return err
+	}
+	first := o.Content[0]
+	indent := first.Column - 1
+	if o.Kind == yaml.SequenceNode {
+		// Items start at their "- "; the node position is the content after it.
+		line := p.lines[first.Line-1]
+		col := byteOffset(line, first.Column)
+		dash := strings.LastIndexByte(line[:col], '-')
+		if dash < 0 || strings.TrimSpace(line[:dash]) != "" {
+			return fmt.Errorf("line %d: cannot locate sequence item", first.Line)
+		}
+		indent = dash
+	}
+	return p.insertAfter(last, extra, indent)
+}
+
+// insertAfter inserts n, rendered as a block at the given indentation,
+// after line (1-based).
+func (p *patcher) insertAfter(line int, n *yaml.Node, indent int) error {
+	text, err := encodeNode(n)
+	if err != nil {
+		return err
+	}
+	pad := strings.Repeat(" ", indent)
+	var b strings.Builder
+	if line == len(p.lines) && !bytes.HasSuffix(p.src, []byte("\n")) {
+		b.WriteString("\n")
+	}
+	for _, l := range strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n") {
+		if strings.TrimSpace(l) != "" {
+			b.WriteString(pad)
+		}
+		b.WriteString(strings.TrimSuffix(l, "\n"))
+		b.WriteString("\n")
+	}
+	at := p.start[line]
+	p.edits = append(p.edits, textEdit{start: at, end: at, text: b.String()})
+	return nil
+}
+
+// lastLine returns the last line (1-based) occupied by n.
+func (p *patcher) lastLine(n *yaml.Node) (int, error) {
+	if n.Kind == yaml.ScalarNode {
+		if _, _, ok := p.scalarRange(n); !ok && n.Value != "" {
+			return 0, fmt.Errorf("line %d: multi-line scalar", n.Line)
+		}
+		return n.Line, nil
+	}
+	last := n.Line
+	for _, c := range n.Content {
+		l, err := p.lastLine(c)
+		if err != nil {
+			return 0, err
+		}
+		last = max(last, l)
+	}
+	return last, nil
+}
+
+// scalarRange returns the byte range of a scalar written on one line.
+func (p *patcher) scalarRange(n *yaml.Node) (int, int, bool) {
+	if n.Line < 1 || n.Line > len(p.lines) {
+		return 0, 0, false
+	}
+	line := p.lines[n.Line-1]
+	col := byteOffset(line, n.Column)
+	if col < 0 {
+		return 0, 0, false
+	}
+	rest := line[col:]
+	var end int
+	switch n.Style {
+	case 0, yaml.TaggedStyle:
+		if n.Value == "" || !strings.HasPrefix(rest, n.Value) {
+			return 0, 0, false
+		}
+		end = len(n.Value)
+	case yaml.SingleQuotedStyle:
+		end = closingQuote(rest, '\'')
+	case yaml.DoubleQuotedStyle:
+		end = closingQuote(rest, '"')
+	default:
+		return 0, 0, false
+	}
+	if end <= 0 {
+		return 0, 0, false
+	}
+	base := p.start[n.Line-1] + col
+	return base, base + end, true
+}
+
+// apply returns src with all edits applied. Insertions at the same offset
+// keep the order in which they were recorded.
+func (p *patcher) apply() []byte {
+	edits := p.edits
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out bytes.Buffer
+	pos := 0
+	for _, e := range edits {
+		out.Write(p.src[pos:e.start])
+		out.WriteString(e.text)
+		pos = e.end
+	}
+	out.Write(p.src[pos:])
+	return out.Bytes()
+}
+
+// closingQuote returns the length of the quoted scalar at the start of s,
+// or -1 if it does not close on this line.
+func closingQuote(s string, q byte) int {
+	if s == "" || s[0] != q {
+		return -1
+	}
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == q:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// byteOffset converts a 1-based character column to a byte offset in line.
+func byteOffset(line string, column int) int {
+	off := 0
+	for c := 1; c < column; c++ {
+		if off >= len(line) {
+			return -1
+		}
+		_, size := utf8.DecodeRuneInString(line[off:])
+		off += size
+	}
+	return off
+}
+
+// indentUnit guesses the document's indentation step from the first
+// nested block mapping, defaulting to 2.
+func indentUnit(n *yaml.Node) int {
+	if u, ok := findIndent(n); ok {
+		return u
+	}
+	return 2
+}
+
+func findIndent(n *yaml.Node) (int, bool) {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i < len(n.Content)-1; i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if v.Kind == yaml.MappingNode && v.Style&yaml.FlowStyle == 0 && v.Line > k.Line && v.Column > k.Column {
+				return v.Column - k.Column, true
+			}
+		}
+	}
+	for _, c := range n.Content {
+		if u, ok := findIndent(c); ok {
+			return u, true
+		}
+	}
+	return 0, false
+}
+
+// encodeNode renders n as a standalone YAML block with 2-space indents.
+func encodeNode(n *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(n); err != nil {
+		return "", fmt.Errorf("encode yaml: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("encode yaml: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,178 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreviewPatchesInPlace(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		src  string
+		ch   Changes
+		want string
+	}{
+		{
+			name: "disable keeps comments and quoting",
+			src: `# top comment
+agents:
+  a:
+    perception:
+      custom:
+        - name: x   # the x plugin
+          script: "./plugins/x.sh"
+          enabled: true
+`,
+			ch: Changes{Disable: []string{"x"}},
+			want: `# top comment
+agents:
+  a:
+    perception:
+      custom:
+        - name: x   # the x plugin
+          script: "./plugins/x.sh"
+          enabled: false
+`,
+		},
+		{
+			name: "set keeps the value's quotes",
+			src: `agents:
+  a:
+    perception:
+      custom:
+        - name: x
+          interval: "60m"
+          timeout: '3000'
+`,
+			ch: Changes{Set: []Setting{{Plugin: "x", Field: "interval", Value: "5m"}, {Plugin: "x", Field: "timeout", Value: "500"}}},
+			want: `agents:
+  a:
+    perception:
+      custom:
+        - name: x
+          interval: "5m"
+          timeout: 500
+`,
+		},
+		{
+			name: "enable appends at the list's indentation",
+			src: `agents:
+  a:
+      perception:
+          custom:
+          -   name: x
+              script: ./plugins/x.sh
+
+  b: {}
+`,
+			ch: Changes{Agent: "a", Enable: []string{"y"}},
+			want: `agents:
+  a:
+      perception:
+          custom:
+          -   name: x
+              script: ./plugins/x.sh
+          - name: y
+            script: ./plugins/y.sh
+
+  b: {}
+`,
+		},
+		{
+			name: "empty value becomes a block",
+			src: `agents:
+  a:
+    perception:
+`,
+			ch: Changes{Enable: []string{"y"}},
+			want: `agents:
+  a:
+    perception:
+      custom:
+        - name: y
+          script: ./plugins/y.sh
+`,
+		},
+		{
+			name: "empty flow list becomes a block",
+			src: `agents:
+  a:
+    perception:
+      custom: []  # none yet
+`,
+			ch: Changes{Enable: []string{"y"}},
+			want: `agents:
+  a:
+    perception:
+      custom:  # none yet
+        - name: y
+          script: ./plugins/y.sh
+`,
+		},
+		{
+			name: "no change",
+			src: `agents:
+  a:
+    perception:
+      custom:
+        - {name: x, script: ./plugins/x.sh}
+`,
+			ch: Changes{Enable: []string{"x"}},
+			want: `agents:
+  a:
+    perception:
+      custom:
+        - {name: x, script: ./plugins/x.sh}
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := composeDir(t, tt.src)
+			before, after, err := Preview(dir, tt.ch)
+			if err != nil {
+				t.Fatalf("Preview: %v", err)
+			}
+			if string(before) != tt.src {
+				t.Errorf("before = %q, want the file as read", before)
+			}
+			if string(after) != tt.want {
+				t.Errorf("after:\n%s\nwant:\n%s", after, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewRefusesReformat(t *testing.T) {
+	for _, src := range []string{
+		"agents:\n  a: {perception: {custom: [{name: x, script: ./x.sh}]}}\n",
+		"agents:\n  a:\n    perception:\n      custom: [{name: x, script: ./x.sh}]\n",
+	} {
+		dir := composeDir(t, src)
+		_, _, err := Preview(dir, Changes{Enable: []string{"y"}})
+		if err == nil || !strings.Contains(err.Error(), "in place") {
+			t.Errorf("Preview(%q) error = %v, want a refusal to edit in place", src, err)
+		}
+	}
+}
+
+func TestApplyChangesLeavesUnchangedFile(t *testing.T) {
+	src := "agents:\n  a:\n    perception:\n      custom:\n        - name: x\n          script: ./x.sh\n"
+	dir := composeDir(t, src)
+	if err := ApplyChanges(dir, Changes{Enable: []string{"x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if backups, _ := Backups(dir); len(backups) != 0 {
+		t.Errorf("no-op apply took %d backup(s)", len(backups))
+	}
+}
+
+func composeDir(t *testing.T, src string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, composeFile), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
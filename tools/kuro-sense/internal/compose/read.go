@@ -4,28 +4,68 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Load reads and parses an agent-compose.yaml file.
 func Load(agentDir string) (*ComposeFile, error) {
-	path := filepath.Join(agentDir, "agent-compose.yaml")
+	path := filepath.Join(agentDir, composeFile)
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read compose file: %w", err)
 	}
 
+	return Parse(data)
+}
+
+// Parse decodes compose file contents. The file order of agents is
+// recorded so Save writes them back in the same order.
+func Parse(data []byte) (*ComposeFile, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse compose file: %w", err)
+	}
 	var cf ComposeFile
-	if err := yaml.Unmarshal(data, &cf); err != nil {
+	if len(doc.Content) == 0 {
+		return &cf, nil
+	}
+	if err := doc.Decode(&cf); err != nil {
 		return nil, fmt.Errorf("parse compose file: %w", err)
 	}
+	if agents := findMappingValue(doc.Content[0], "agents"); agents != nil && agents.Kind == yaml.MappingNode {
+		for i := 0; i < len(agents.Content)-1; i += 2 {
+			cf.order = append(cf.order, agents.Content[i].Value)
+		}
+	}
 	return &cf, nil
 }
 
+// AgentIDs returns the agent keys in file order. Agents added after
+// loading follow, sorted.
+func (cf *ComposeFile) AgentIDs() []string {
+	ids := make([]string, 0, len(cf.Agents))
+	seen := make(map[string]bool, len(cf.Agents))
+	for _, id := range cf.order {
+		if _, ok := cf.Agents[id]; ok && !seen[id] {
+			ids = append(ids, id)
+			seen[id] = true
+		}
+	}
+	var rest []string
+	for id := range cf.Agents {
+		if !seen[id] {
+			rest = append(rest, id)
+		}
+	}
+	sort.Strings(rest)
+	return append(ids, rest...)
+}
+
 // LoadRaw reads the compose file as a yaml.Node tree for comment-preserving edits.
 func LoadRaw(agentDir string) (*yaml.Node, error) {
-	path := filepath.Join(agentDir, "agent-compose.yaml")
+	path := filepath.Join(agentDir, composeFile)
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("read compose file: %w", err)
@@ -38,24 +78,43 @@ func LoadRaw(agentDir string) (*yaml.Node, error) {
 	return &doc, nil
 }
 
-// GetCustomPerceptions extracts the custom perception list from a parsed compose file.
-func GetCustomPerceptions(cf *ComposeFile) []ComposePerception {
-	for _, agent := range cf.Agents {
-		if agent.Perception != nil && agent.Perception.Custom != nil {
-			return agent.Perception.Custom
+// GetCustomPerceptions returns the custom perception list of the selected
+// agent (see SelectAgents). With AllAgents, entries are merged in agent
+// order and the first entry of each name wins.
+func GetCustomPerceptions(cf *ComposeFile, agent string) ([]ComposePerception, error) {
+	ids, err := SelectAgents(cf.AgentIDs(), agent)
+	if err != nil {
+		return nil, err
+	}
+	var out []ComposePerception
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		perc := cf.Agents[id].Perception
+		if perc == nil {
+			continue
+		}
+		for _, p := range perc.Custom {
+			if !seen[p.Name] {
+				seen[p.Name] = true
+				out = append(out, p)
+			}
 		}
 	}
-	return nil
+	return out, nil
 }
 
-// GetEnabledPluginNames returns the names of all enabled custom perception plugins.
-func GetEnabledPluginNames(cf *ComposeFile) []string {
-	perceptions := GetCustomPerceptions(cf)
+// GetEnabledPluginNames returns the names of the selected agent's enabled
+// custom perception plugins.
+func GetEnabledPluginNames(cf *ComposeFile, agent string) ([]string, error) {
+	perceptions, err := GetCustomPerceptions(cf, agent)
+	if err != nil {
+		return nil, err
+	}
 	var names []string
 	for _, p := range perceptions {
 		if p.Enabled == nil || *p.Enabled {
 			names = append(names, p.Name)
 		}
 	}
-	return names
+	return names, nil
 }
@@ -0,0 +1,109 @@
+package compose
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "rewrite the .golden files")
+
+// TestRoundTrip loads each testdata/*.yaml, saves it, and checks that the
+// saved file matches its golden copy and holds every value of the input.
+func TestRoundTrip(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no test data")
+	}
+	for _, input := range inputs {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			src, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, composeFile), src, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cf, err := Load(dir)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if err := Save(dir, cf); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			saved, err := os.ReadFile(filepath.Join(dir, composeFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := strings.TrimSuffix(input, ".yaml") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, saved, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if string(saved) != string(want) {
+				t.Errorf("saved file differs from %s:\n%s", golden, saved)
+			}
+
+			// Comments and key order may change; values may not.
+			var before, after interface{}
+			if err := yaml.Unmarshal(src, &before); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal(saved, &after); err != nil {
+				t.Fatalf("saved file does not parse: %v", err)
+			}
+			if !reflect.DeepEqual(before, after) {
+				t.Errorf("saved file lost or changed values:\nbefore: %v\nafter:  %v", before, after)
+			}
+
+			reloaded, err := Load(dir)
+			if err != nil {
+				t.Fatalf("Load saved file: %v", err)
+			}
+			if !reflect.DeepEqual(cf.AgentIDs(), reloaded.AgentIDs()) {
+				t.Errorf("agent order %v, want %v", reloaded.AgentIDs(), cf.AgentIDs())
+			}
+
+			backups, err := Backups(dir)
+			if err != nil || len(backups) != 1 {
+				t.Errorf("Backups = %v, %v; want the file Save replaced", backups, err)
+			}
+		})
+	}
+}
+
+func TestOptionalListKeepsEmpty(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want OptionalList[string]
+	}{
+		{"agents:\n  a:\n    perception:\n      builtin: []\n", OptionalList[string]{}},
+		{"agents:\n  a:\n    perception:\n      builtin: [git]\n", OptionalList[string]{"git"}},
+		{"agents:\n  a:\n    perception:\n      custom: []\n", nil},
+	} {
+		cf, err := Parse([]byte(tt.src))
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.src, err)
+		}
+		got := cf.Agents["a"].Perception.Builtin
+		if !reflect.DeepEqual(got, tt.want) || (got == nil) != (tt.want == nil) {
+			t.Errorf("Parse(%q) builtin = %#v, want %#v", tt.src, got, tt.want)
+		}
+	}
+}
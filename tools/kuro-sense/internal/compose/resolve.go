@@ -0,0 +1,49 @@
+package compose
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+// Resolve checks ch against the capability dependency graph for each agent
+// it targets (see registry.Resolve). Plugins the enabled ones require are
+// added to ch.Enable and returned as pulls; disabling a plugin that stays
+// required, or enabling one that conflicts with an enabled plugin, is an
+// error.
+func Resolve(agentDir string, ch Changes) (Changes, []registry.Pull, error) {
+	cf, err := Load(agentDir)
+	if err != nil {
+		return ch, nil, err
+	}
+	ids, err := SelectAgents(cf.AgentIDs(), ch.Agent)
+	if err != nil {
+		return ch, nil, err
+	}
+
+	caps := registry.All()
+	var pulls []registry.Pull
+	out := ch
+	out.Enable = slices.Clone(ch.Enable)
+	for _, id := range ids {
+		current, err := GetEnabledPluginNames(cf, id)
+		if err != nil {
+			return ch, nil, err
+		}
+		res, err := registry.Resolve(caps, current, ch.Enable, ch.Disable)
+		if err != nil {
+			if len(ids) > 1 {
+				return ch, nil, fmt.Errorf("agent %s: %w", id, err)
+			}
+			return ch, nil, err
+		}
+		for _, p := range res.Pulled {
+			if !slices.Contains(out.Enable, p.Name) {
+				out.Enable = append(out.Enable, p.Name)
+				pulls = append(pulls, p)
+			}
+		}
+	}
+	return out, pulls, nil
+}
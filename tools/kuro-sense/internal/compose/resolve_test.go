@@ -0,0 +1,73 @@
+package compose
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+func TestResolve(t *testing.T) {
+	dir := composeDir(t, `agents:
+  a:
+    perception:
+      custom:
+        - name: github-issues
+          script: ./plugins/github-issues.sh
+  b:
+    perception:
+      custom:
+        - name: state-changes
+          script: ./plugins/state-watcher.sh
+`)
+	for _, tt := range []struct {
+		name       string
+		ch         Changes
+		wantEnable []string
+		wantPulls  []registry.Pull
+		wantErr    string
+	}{
+		{
+			name:       "requirement already enabled",
+			ch:         Changes{Agent: "a", Enable: []string{"github-prs"}},
+			wantEnable: []string{"github-prs"},
+		},
+		{
+			name:       "requirement pulled in",
+			ch:         Changes{Agent: "b", Enable: []string{"github-prs"}},
+			wantEnable: []string{"github-prs", "github-issues"},
+			wantPulls:  []registry.Pull{{Name: "github-issues", By: "github-prs"}},
+		},
+		{
+			name:       "pulled once across agents",
+			ch:         Changes{Agent: AllAgents, Enable: []string{"github-prs"}},
+			wantEnable: []string{"github-prs", "github-issues"},
+			wantPulls:  []registry.Pull{{Name: "github-issues", By: "github-prs"}},
+		},
+		{
+			name:    "conflict names the agent",
+			ch:      Changes{Agent: AllAgents, Enable: []string{"git-detail"}},
+			wantErr: "agent b: git-detail conflicts with state-changes",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, pulls, err := Resolve(dir, tt.ch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if !reflect.DeepEqual(got.Enable, tt.wantEnable) {
+				t.Errorf("Enable = %v, want %v", got.Enable, tt.wantEnable)
+			}
+			if !reflect.DeepEqual(pulls, tt.wantPulls) {
+				t.Errorf("pulls = %v, want %v", pulls, tt.wantPulls)
+			}
+		})
+	}
+}
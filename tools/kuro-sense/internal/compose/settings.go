@@ -0,0 +1,70 @@
+package compose
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Setting is a per-plugin field edit such as website.interval=60m.
+type Setting struct {
+	Plugin string
+	Field  string // "interval", "timeout" or "output_cap"
+	Value  string // normalized: interval as written, numbers in decimal
+}
+
+func (s Setting) String() string {
+	return fmt.Sprintf("%s.%s=%s", s.Plugin, s.Field, s.Value)
+}
+
+// intervalRe is the format the agent's perception scheduler accepts.
+var intervalRe = regexp.MustCompile(`^(\d+)(s|m|h)$`)
+
+// ParseSetting parses and validates "plugin.field=value".
+//
+//	interval    "30s", "5m" or "1h" (the agent ignores other formats)
+//	timeout     milliseconds ("30000") or a duration ("30s")
+//	output_cap  characters, a positive integer
+func ParseSetting(s string) (Setting, error) {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return Setting{}, fmt.Errorf("setting %q: want plugin.field=value", s)
+	}
+	plugin, field, ok := strings.Cut(strings.TrimSpace(key), ".")
+	if !ok || plugin == "" || field == "" {
+		return Setting{}, fmt.Errorf("setting %q: want plugin.field=value", s)
+	}
+	value = strings.TrimSpace(value)
+
+	switch field {
+	case "interval":
+		m := intervalRe.FindStringSubmatch(value)
+		if m == nil || strings.TrimLeft(m[1], "0") == "" {
+			return Setting{}, fmt.Errorf("setting %q: interval must be a positive number followed by s, m or h", s)
+		}
+	case "timeout":
+		ms, err := strconv.Atoi(value)
+		if err != nil {
+			d, derr := time.ParseDuration(value)
+			if derr != nil {
+				return Setting{}, fmt.Errorf("setting %q: timeout must be milliseconds or a duration like 30s", s)
+			}
+			ms = int(d.Milliseconds())
+		}
+		if ms <= 0 {
+			return Setting{}, fmt.Errorf("setting %q: timeout must be positive", s)
+		}
+		value = strconv.Itoa(ms)
+	case "output_cap":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return Setting{}, fmt.Errorf("setting %q: output_cap must be a positive integer", s)
+		}
+		value = strconv.Itoa(n)
+	default:
+		return Setting{}, fmt.Errorf("setting %q: unknown field %q (want interval, timeout or output_cap)", s, field)
+	}
+	return Setting{Plugin: plugin, Field: field, Value: value}, nil
+}
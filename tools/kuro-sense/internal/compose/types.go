@@ -1,60 +1,129 @@
 package compose
 
+// The typed model covers every field the mini-agent runtime reads from
+// agent-compose.yaml (src/types.ts). Keys it does not know are kept in the
+// Extra map of the enclosing struct, so Load followed by Save loses nothing
+// but comments and key order. Numbers and lists that can be written as 0 or
+// [] are pointers or OptionalLists, so explicit values survive a Save.
+
 // ComposeFile represents the top-level agent-compose.yaml structure.
 type ComposeFile struct {
-	Version string                 `yaml:"version"`
-	Paths   *ComposePaths          `yaml:"paths,omitempty"`
+	Version string                  `yaml:"version,omitempty"`
+	Paths   *ComposePaths           `yaml:"paths,omitempty"`
+	Memory  *ComposeMemory          `yaml:"memory,omitempty"`
 	Agents  map[string]ComposeAgent `yaml:"agents"`
+	Extra   map[string]interface{}  `yaml:",inline"`
+
+	order []string // agent keys in file order, set by Load
 }
 
 // ComposePaths holds path configuration.
 type ComposePaths struct {
-	Memory string `yaml:"memory,omitempty"`
-	Logs   string `yaml:"logs,omitempty"`
+	Memory string                 `yaml:"memory,omitempty"`
+	Logs   string                 `yaml:"logs,omitempty"`
+	Extra  map[string]interface{} `yaml:",inline"`
+}
+
+// ComposeMemory holds shared memory configuration.
+type ComposeMemory struct {
+	Shared *SharedMemory          `yaml:"shared,omitempty"`
+	Extra  map[string]interface{} `yaml:",inline"`
+}
+
+// SharedMemory configures a memory directory shared between agents.
+type SharedMemory struct {
+	Path  string                 `yaml:"path,omitempty"`
+	Sync  *bool                  `yaml:"sync,omitempty"`
+	Extra map[string]interface{} `yaml:",inline"`
 }
 
 // ComposeAgent represents an agent definition.
 type ComposeAgent struct {
-	Name       string           `yaml:"name,omitempty"`
-	Port       int              `yaml:"port,omitempty"`
-	Persona    string           `yaml:"persona,omitempty"`
-	Loop       *ComposeLoop     `yaml:"loop,omitempty"`
-	Cron       []ComposeCron    `yaml:"cron,omitempty"`
-	Perception *ComposePerc     `yaml:"perception,omitempty"`
-	Skills     []string         `yaml:"skills,omitempty"`
-	DependsOn  []string         `yaml:"depends_on,omitempty"`
+	Name       string                    `yaml:"name,omitempty"`
+	Port       *int                      `yaml:"port,omitempty"`
+	Role       string                    `yaml:"role,omitempty"`
+	Persona    string                    `yaml:"persona,omitempty"`
+	Paths      *ComposePaths             `yaml:"paths,omitempty"`
+	Proactive  *ComposeProactive         `yaml:"proactive,omitempty"`
+	Loop       *ComposeLoop              `yaml:"loop,omitempty"`
+	Cron       OptionalList[ComposeCron] `yaml:"cron,omitempty"`
+	Perception *ComposePerc              `yaml:"perception,omitempty"`
+	Skills     OptionalList[string]      `yaml:"skills,omitempty"`
+	DependsOn  OptionalList[string]      `yaml:"depends_on,omitempty"`
+	Hooks      OptionalList[ComposeHook] `yaml:"hooks,omitempty"`
+	Rules      OptionalList[string]      `yaml:"rules,omitempty"`
+	Extra      map[string]interface{}    `yaml:",inline"`
+}
+
+// ComposeProactive holds the proactive schedule.
+type ComposeProactive struct {
+	Schedule string                 `yaml:"schedule,omitempty"`
+	Extra    map[string]interface{} `yaml:",inline"`
 }
 
 // ComposeLoop holds loop configuration.
 type ComposeLoop struct {
-	Enabled     *bool         `yaml:"enabled,omitempty"`
-	Interval    string        `yaml:"interval,omitempty"`
-	ActiveHours *ActiveHours  `yaml:"activeHours,omitempty"`
+	Enabled     *bool                  `yaml:"enabled,omitempty"`
+	Interval    string                 `yaml:"interval,omitempty"`
+	ActiveHours *ActiveHours           `yaml:"activeHours,omitempty"`
+	Extra       map[string]interface{} `yaml:",inline"`
 }
 
 // ActiveHours defines when the agent is active.
 type ActiveHours struct {
-	Start *int `yaml:"start,omitempty"`
-	End   *int `yaml:"end,omitempty"`
+	Start *int                   `yaml:"start,omitempty"`
+	End   *int                   `yaml:"end,omitempty"`
+	Extra map[string]interface{} `yaml:",inline"`
 }
 
 // ComposeCron represents a scheduled task.
 type ComposeCron struct {
-	Schedule string `yaml:"schedule"`
-	Task     string `yaml:"task"`
+	Schedule string                 `yaml:"schedule"`
+	Task     string                 `yaml:"task"`
+	Enabled  *bool                  `yaml:"enabled,omitempty"`
+	Extra    map[string]interface{} `yaml:",inline"`
 }
 
 // ComposePerc holds perception configuration.
 type ComposePerc struct {
-	Builtin []string            `yaml:"builtin,omitempty"`
-	Custom  []ComposePerception `yaml:"custom,omitempty"`
+	Builtin OptionalList[string]            `yaml:"builtin,omitempty"`
+	Custom  OptionalList[ComposePerception] `yaml:"custom,omitempty"`
+	Extra   map[string]interface{}          `yaml:",inline"`
 }
 
 // ComposePerception represents a single perception plugin entry.
 type ComposePerception struct {
-	Name     string `yaml:"name"`
-	Script   string `yaml:"script"`
-	Interval string `yaml:"interval,omitempty"`
-	Timeout  int    `yaml:"timeout,omitempty"`
-	Enabled  *bool  `yaml:"enabled,omitempty"`
+	Name      string                 `yaml:"name"`
+	Script    string                 `yaml:"script"`
+	Args      OptionalList[string]   `yaml:"args,omitempty"`
+	Interval  string                 `yaml:"interval,omitempty"`
+	Timeout   *int                   `yaml:"timeout,omitempty"`
+	Enabled   *bool                  `yaml:"enabled,omitempty"`
+	OutputCap *int                   `yaml:"output_cap,omitempty"` // chars before truncation (agent default 4000)
+	Env       map[string]string      `yaml:"env,omitempty"`
+	Extra     map[string]interface{} `yaml:",inline"`
+}
+
+// ComposeHook is a lifecycle hook (shell script or HTTP target).
+type ComposeHook struct {
+	Name      string                 `yaml:"name"`
+	Event     string                 `yaml:"event"`
+	Type      string                 `yaml:"type,omitempty"` // "shell" (default) or "http"
+	Target    string                 `yaml:"target"`
+	Async     *bool                  `yaml:"async,omitempty"`
+	Timeout   *int                   `yaml:"timeout,omitempty"`
+	Condition map[string]string      `yaml:"condition,omitempty"`
+	Env       map[string]string      `yaml:"env,omitempty"`
+	Enabled   *bool                  `yaml:"enabled,omitempty"`
+	Extra     map[string]interface{} `yaml:",inline"`
+}
+
+// OptionalList is a list where "absent" and "empty" differ: perception
+// builtin: [] disables every built-in perception, while omitting the key
+// enables all of them. Only a nil list is omitted when saving.
+type OptionalList[T any] []T
+
+// IsZero reports whether the list is absent, for yaml omitempty.
+func (l OptionalList[T]) IsZero() bool {
+	return l == nil
 }
@@ -1,29 +1,77 @@
 package compose
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
 	"gopkg.in/yaml.v3"
 )
 
-// ApplyChanges modifies the compose file to enable/disable perception plugins.
-// It uses the Node API to preserve comments and formatting.
-func ApplyChanges(agentDir string, enable, disable []string) error {
-	doc, err := LoadRaw(agentDir)
+// Changes is a set of plugin edits for one or more agents.
+type Changes struct {
+	Agent   string // agent key, AllAgents, or "" for the only agent
+	Enable  []string
+	Disable []string
+	Set     []Setting // applied after enable/disable
+}
+
+// ApplyChanges modifies the compose file to enable/disable perception plugins
+// of the agents selected by ch.Agent, creating perception.custom where an
+// agent has none. The previous file is backed up first (see Rollback), and
+// nothing is written when the edit changes nothing.
+func ApplyChanges(agentDir string, ch Changes) error {
+	before, after, err := Preview(agentDir, ch)
 	if err != nil {
 		return err
 	}
+	if string(before) == string(after) {
+		return nil
+	}
+	return writeCompose(agentDir, after)
+}
 
-	// Navigate: doc.Content[0] → mapping → agents → {agent} → perception → custom
-	customNode := findCustomNode(doc)
-	if customNode == nil {
-		return fmt.Errorf("could not find perception.custom in compose file")
+// Preview returns the current compose file and what ApplyChanges would
+// write for ch. Only the affected lines change; see render.
+func Preview(agentDir string, ch Changes) (before, after []byte, err error) {
+	before, err = os.ReadFile(filepath.Join(agentDir, composeFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read compose file: %w", err)
+	}
+	// doc is edited in place; orig stays as parsed so the edit can be
+	// written as a patch of the original bytes.
+	var orig, doc yaml.Node
+	if err := yaml.Unmarshal(before, &orig); err != nil {
+		return nil, nil, fmt.Errorf("parse compose file: %w", err)
 	}
+	if err := yaml.Unmarshal(before, &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse compose file: %w", err)
+	}
+
+	agents, err := agentNodes(&doc, ch.Agent)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, agentNode := range agents {
+		if err := applyToCustom(ensureCustomNode(agentNode), ch); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	after, err = render(before, &orig, &doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
 
-	enableSet := toSet(enable)
-	disableSet := toSet(disable)
+// applyToCustom updates one agent's perception.custom sequence.
+func applyToCustom(customNode *yaml.Node, ch Changes) error {
+	enableSet := toSet(ch.Enable)
+	disableSet := toSet(ch.Disable)
 
 	// Walk existing entries and update enabled field
 	for _, item := range customNode.Content {
@@ -35,7 +83,10 @@ func ApplyChanges(agentDir string, enable, disable []string) error {
 			continue
 		}
 		if _, ok := enableSet[name]; ok {
-			setEnabledField(item, true)
+			// Entries without an enabled key are already enabled.
+			if findMappingValue(item, "enabled") != nil {
+				setEnabledField(item, true)
+			}
 			delete(enableSet, name)
 		}
 		if _, ok := disableSet[name]; ok {
@@ -44,40 +95,140 @@ func ApplyChanges(agentDir string, enable, disable []string) error {
 		}
 	}
 
-	// Add new entries for plugins not already in the file
-	for name := range enableSet {
-		node := newPerceptionNode(name)
-		customNode.Content = append(customNode.Content, node)
+	// Add new entries for plugins not already in the file, in the
+	// order they were requested.
+	for _, name := range ch.Enable {
+		if enableSet[name] {
+			customNode.Content = append(customNode.Content, newPerceptionNode(name))
+			delete(enableSet, name)
+		}
+	}
+
+	for _, s := range ch.Set {
+		item := findPerception(customNode, s.Plugin)
+		if item == nil {
+			return fmt.Errorf("cannot set %s: %s is not in perception.custom (enable it first)", s, s.Plugin)
+		}
+		tag := "!!int"
+		if s.Field == "interval" {
+			tag = "!!str"
+		}
+		setField(item, s.Field, s.Value, tag)
 	}
+	return nil
+}
 
-	path := filepath.Join(agentDir, "agent-compose.yaml")
-	return writeYAML(path, doc)
+// findPerception returns the perception.custom entry with the given name.
+func findPerception(customNode *yaml.Node, name string) *yaml.Node {
+	for _, item := range customNode.Content {
+		if item.Kind == yaml.MappingNode && getScalarField(item, "name") == name {
+			return item
+		}
+	}
+	return nil
 }
 
-// findCustomNode navigates the YAML tree to find the perception.custom sequence node.
-func findCustomNode(doc *yaml.Node) *yaml.Node {
+// agentNodes returns the value nodes of the selected agents, in file order.
+func agentNodes(doc *yaml.Node, agent string) ([]*yaml.Node, error) {
 	if doc == nil || len(doc.Content) == 0 {
-		return nil
+		return nil, fmt.Errorf("compose file is empty")
+	}
+	agentsNode := findMappingValue(doc.Content[0], "agents")
+	if agentsNode == nil || agentsNode.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("no agents in compose file")
 	}
-	root := doc.Content[0] // document root mapping
 
-	agentsNode := findMappingValue(root, "agents")
-	if agentsNode == nil {
-		return nil
+	var ids []string
+	values := make(map[string]*yaml.Node)
+	for i := 0; i < len(agentsNode.Content)-1; i += 2 {
+		id := agentsNode.Content[i].Value
+		ids = append(ids, id)
+		values[id] = agentsNode.Content[i+1]
 	}
+	selected, err := SelectAgents(ids, agent)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*yaml.Node, len(selected))
+	for i, id := range selected {
+		nodes[i] = values[id]
+	}
+	return nodes, nil
+}
 
-	// Find first agent
-	if agentsNode.Kind != yaml.MappingNode || len(agentsNode.Content) < 2 {
-		return nil
+// ensureCustomNode returns the agent's perception.custom sequence node,
+// creating perception and custom (or replacing empty values) as needed.
+func ensureCustomNode(agentNode *yaml.Node) *yaml.Node {
+	percNode := ensureChild(agentNode, "perception", yaml.MappingNode)
+	return ensureChild(percNode, "custom", yaml.SequenceNode)
+}
+
+// ensureChild returns the value of key in mapping, adding an empty node of
+// the given kind if the key is absent or null.
+func ensureChild(mapping *yaml.Node, key string, kind yaml.Kind) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		resetNode(mapping, yaml.MappingNode)
+	}
+	if v := findMappingValue(mapping, key); v != nil {
+		if v.Kind != kind {
+			resetNode(v, kind)
+		}
+		return v
 	}
-	agentNode := agentsNode.Content[1] // first agent value
+	v := &yaml.Node{}
+	resetNode(v, kind)
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, v)
+	return v
+}
 
-	percNode := findMappingValue(agentNode, "perception")
-	if percNode == nil {
-		return nil
+// resetNode turns n into an empty node of the given kind. It is only used
+// to replace a null or mistyped value, whose style (a quoted scalar, a flow
+// "[]") says nothing about how the new collection should look, so the
+// result is block style like the rest of the file.
+func resetNode(n *yaml.Node, kind yaml.Kind) {
+	n.Kind = kind
+	n.Style = 0
+	n.Value = ""
+	n.Content = nil
+	n.Tag = "!!map"
+	if kind == yaml.SequenceNode {
+		n.Tag = "!!seq"
+	}
+}
+
+// Save writes cf to agentDir/agent-compose.yaml. Every field, including
+// unknown keys kept in Extra, is written back and agents keep their file
+// order; comments are lost, so prefer ApplyChanges for edits to a file a
+// person maintains.
+func Save(agentDir string, cf *ComposeFile) error {
+	doc, err := Encode(cf)
+	if err != nil {
+		return err
+	}
+	data, err := encodeYAML(doc)
+	if err != nil {
+		return err
 	}
+	return writeCompose(agentDir, data)
+}
 
-	return findMappingValue(percNode, "custom")
+// Encode converts cf to a YAML document node, agents in AgentIDs order.
+func Encode(cf *ComposeFile) (*yaml.Node, error) {
+	var root yaml.Node
+	if err := root.Encode(cf); err != nil {
+		return nil, fmt.Errorf("encode compose file: %w", err)
+	}
+	if agents := findMappingValue(&root, "agents"); agents != nil && agents.Kind == yaml.MappingNode {
+		pairs := make(map[string][2]*yaml.Node, len(agents.Content)/2)
+		for i := 0; i < len(agents.Content)-1; i += 2 {
+			pairs[agents.Content[i].Value] = [2]*yaml.Node{agents.Content[i], agents.Content[i+1]}
+		}
+		agents.Content = agents.Content[:0]
+		for _, id := range cf.AgentIDs() {
+			agents.Content = append(agents.Content, pairs[id][0], pairs[id][1])
+		}
+	}
+	return &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&root}}, nil
 }
 
 func findMappingValue(node *yaml.Node, key string) *yaml.Node {
@@ -106,21 +257,41 @@ func setEnabledField(mapping *yaml.Node, enabled bool) {
 	if !enabled {
 		val = "false"
 	}
+	setField(mapping, "enabled", val, "!!bool")
+}
+
+// setField sets a scalar field, adding it if not present.
+func setField(mapping *yaml.Node, key, value, tag string) {
 	for i := 0; i < len(mapping.Content)-1; i += 2 {
-		if mapping.Content[i].Value == "enabled" {
-			mapping.Content[i+1].Value = val
+		if mapping.Content[i].Value == key {
+			v := mapping.Content[i+1]
+			// Keep the value's quoting when it still reads as the same
+			// type; a quoted "true" would otherwise stay a string.
+			if v.Kind != yaml.ScalarNode || tag != "!!str" {
+				v.Style = 0
+			}
+			v.Style &^= yaml.LiteralStyle | yaml.FoldedStyle
+			v.Kind = yaml.ScalarNode
+			v.Tag = tag
+			v.Value = value
+			v.Content = nil
 			return
 		}
 	}
-	// Add enabled field if not present
-	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: "enabled"}
-	valNode := &yaml.Node{Kind: yaml.ScalarNode, Value: val, Tag: "!!bool"}
+	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+	valNode := &yaml.Node{Kind: yaml.ScalarNode, Value: value, Tag: tag}
 	mapping.Content = append(mapping.Content, keyNode, valNode)
 }
 
+// newPerceptionNode builds an entry for a plugin from its registry
+// definition; names the registry does not know get ./plugins/<name>.sh.
 func newPerceptionNode(name string) *yaml.Node {
-	// Look up registry for script path
 	script := fmt.Sprintf("./plugins/%s.sh", name)
+	timeout := 0
+	if cap := registry.ByName(name); cap != nil {
+		script = cap.Script
+		timeout = cap.Timeout
+	}
 
 	node := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
 		{Kind: yaml.ScalarNode, Value: "name"},
@@ -128,22 +299,23 @@ func newPerceptionNode(name string) *yaml.Node {
 		{Kind: yaml.ScalarNode, Value: "script"},
 		{Kind: yaml.ScalarNode, Value: script},
 	}}
+	if timeout > 0 {
+		setField(node, "timeout", strconv.Itoa(timeout), "!!int")
+	}
 	return node
 }
 
-func writeYAML(path string, doc *yaml.Node) error {
-	f, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("create file: %w", err)
-	}
-	defer f.Close()
-
-	enc := yaml.NewEncoder(f)
+func encodeYAML(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
 	enc.SetIndent(2)
 	if err := enc.Encode(doc); err != nil {
-		return fmt.Errorf("encode yaml: %w", err)
+		return nil, fmt.Errorf("encode yaml: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("encode yaml: %w", err)
 	}
-	return enc.Close()
+	return buf.Bytes(), nil
 }
 
 func toSet(items []string) map[string]bool {
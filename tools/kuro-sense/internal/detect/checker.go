@@ -0,0 +1,103 @@
+package detect
+
+import (
+	"context"
+	"sync"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+// Checker probes one kind of dependency.
+type Checker interface {
+	// Check reports whether dep is present. A non-nil error means the
+	// probe could not tell (tool missing, permission denied, ...).
+	// The Engine gives access to host scans memoized for the run.
+	Check(ctx context.Context, e *Engine, dep registry.Dependency) (bool, error)
+
+	// Install returns the ways to satisfy dep when it is missing.
+	Install(dep registry.Dependency) []registry.InstallHint
+}
+
+// Versioner is implemented by checkers that can report the installed
+// version of a dependency, for Dependency.Version constraints.
+type Versioner interface {
+	Version(ctx context.Context, dep registry.Dependency) (string, error)
+}
+
+// CheckFunc adapts a probe function to Checker. Install returns the
+// dependency's own hint.
+type CheckFunc func(ctx context.Context, e *Engine, dep registry.Dependency) (bool, error)
+
+func (f CheckFunc) Check(ctx context.Context, e *Engine, dep registry.Dependency) (bool, error) {
+	return f(ctx, e, dep)
+}
+
+func (f CheckFunc) Install(dep registry.Dependency) []registry.InstallHint {
+	return dep.Install
+}
+
+var (
+	checkersMu sync.RWMutex
+	checkers   = map[registry.DependencyKind]Checker{}
+)
+
+// Register makes c the checker for kind, replacing any previous one.
+func Register(kind registry.DependencyKind, c Checker) {
+	checkersMu.Lock()
+	defer checkersMu.Unlock()
+	checkers[kind] = c
+}
+
+// CheckerFor returns the checker registered for kind.
+func CheckerFor(kind registry.DependencyKind) (Checker, bool) {
+	checkersMu.RLock()
+	defer checkersMu.RUnlock()
+	c, ok := checkers[kind]
+	return c, ok
+}
+
+// InstallHints returns the ways to install dep, asking its kind's checker.
+// Dependencies of unknown kinds fall back to their own hints.
+func InstallHints(dep registry.Dependency) []registry.InstallHint {
+	if c, ok := CheckerFor(dep.Kind); ok {
+		return c.Install(dep)
+	}
+	return dep.Install
+}
+
+func init() {
+	Register(registry.KindBinary, binaryChecker{})
+	Register(registry.KindService, CheckFunc(func(ctx context.Context, _ *Engine, dep registry.Dependency) (bool, error) {
+		return HasService(ctx, dep.Check), nil
+	}))
+	Register(registry.KindEnvVar, envVarChecker{})
+	Register(registry.KindPython, CheckFunc(func(ctx context.Context, _ *Engine, dep registry.Dependency) (bool, error) {
+		return pythonModule(ctx, dep.Check)
+	}))
+	Register(registry.KindFile, CheckFunc(func(_ context.Context, _ *Engine, dep registry.Dependency) (bool, error) {
+		return fileExists(dep.Check), nil
+	}))
+	Register(registry.KindHardware, CheckFunc(func(ctx context.Context, e *Engine, dep registry.Dependency) (bool, error) {
+		hw, err := e.hw.get(ctx, hardwareScan)
+		if err != nil {
+			return false, err
+		}
+		return hardwarePresent(hw, dep.Check), nil
+	}))
+	Register(registry.KindNetwork, CheckFunc(func(ctx context.Context, e *Engine, dep registry.Dependency) (bool, error) {
+		if dep.Check == "internet" {
+			inet, err := e.inet.get(ctx, internetScan)
+			return inet.Connected, err
+		}
+		return HasNetwork(ctx, dep.Check), nil
+	}))
+
+	Register(registry.KindNpm, npmChecker{})
+	Register(registry.KindDockerImage, dockerImageChecker{})
+	Register(registry.KindHTTP, CheckFunc(func(ctx context.Context, _ *Engine, dep registry.Dependency) (bool, error) {
+		return HasHTTP(ctx, dep.Check), nil
+	}))
+	Register(registry.KindMacOSPermission, permissionChecker{})
+	Register(registry.KindProcess, processChecker{})
+	Register(registry.KindPortFree, portFreeChecker{})
+}
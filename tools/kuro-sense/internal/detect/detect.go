@@ -1,6 +1,7 @@
 package detect
 
 import (
+	"context"
 	"runtime"
 
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
@@ -15,42 +16,62 @@ type Results struct {
 	Capabilities []registry.DetectionResult
 }
 
-// RunAll detects all capabilities against the current environment.
+// RunAll detects all capabilities against the current environment,
+// using a concurrent engine bounded by DefaultTimeout.
 func RunAll(caps []registry.Capability) Results {
-	osInfo := DetectOS()
-	hardware := DetectHardware()
-	network := DetectNetwork()
-	runtimes := DetectRuntimes()
-
-	results := make([]registry.DetectionResult, 0, len(caps))
-	for _, cap := range caps {
-		results = append(results, checkCapability(cap, osInfo))
-	}
-
-	return Results{
-		OS:           osInfo,
-		Hardware:     hardware,
-		Network:      network,
-		Runtimes:     runtimes,
-		Capabilities: results,
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	return NewEngine(DefaultWorkers).Run(ctx, caps)
 }
 
-func checkCapability(cap registry.Capability, osInfo OSInfo) registry.DetectionResult {
+func checkCapability(cap registry.Capability, outcomes map[probeKey]probeOutcome) registry.DetectionResult {
 	result := registry.DetectionResult{
 		Capability: cap,
 		Available:  true,
 	}
 
 	// Platform check
-	if !platformMatch(cap.Platform, osInfo) {
+	if !platformMatch(cap.Platform) {
 		result.Available = false
 		return result
 	}
 
 	for _, dep := range cap.Dependencies {
-		present := checkDependency(dep)
-		if !present {
+		o, ok := outcomes[keyOf(dep)]
+		if !ok {
+			o.Status = registry.StatusTimeout // never ran: the run was cancelled
+		}
+		check := registry.DependencyCheck{
+			Name:       dep.Name,
+			Kind:       dep.Kind,
+			Check:      dep.Check,
+			Status:     o.Status,
+			DurationMs: o.Duration.Milliseconds(),
+			Error:      o.Err,
+		}
+		if dep.Version != "" && o.Status == registry.StatusPresent {
+			check.Version = o.Version
+			check.Want = dep.Version
+			check.Status = versionStatus(dep.Version, o)
+		}
+		result.Checks = append(result.Checks, check)
+
+		switch check.Status {
+		case registry.StatusPresent:
+			continue
+		case registry.StatusOutdated:
+			result.OutdatedDeps = append(result.OutdatedDeps, dep)
+			if dep.Required {
+				result.Available = false
+			} else {
+				result.Degraded = true
+			}
+		case registry.StatusUnknown:
+			result.UnknownDeps = append(result.UnknownDeps, dep)
+			if dep.Required {
+				result.Available = false // cannot confirm a required dep
+			}
+		default:
 			result.MissingDeps = append(result.MissingDeps, dep)
 			if dep.Required {
 				result.Available = false
@@ -63,53 +84,24 @@ func checkCapability(cap registry.Capability, osInfo OSInfo) registry.DetectionR
 	return result
 }
 
-func checkDependency(dep registry.Dependency) bool {
-	switch dep.Kind {
-	case registry.KindBinary:
-		return HasBinary(dep.Check)
-	case registry.KindService:
-		return HasService(dep.Check)
-	case registry.KindEnvVar:
-		return HasEnvVar(dep.Check)
-	case registry.KindPython:
-		return HasPythonModule(dep.Check)
-	case registry.KindFile:
-		return fileExists(dep.Check)
-	case registry.KindHardware:
-		return HasHardware(dep.Check)
-	case registry.KindNetwork:
-		return HasNetwork(dep.Check)
-	default:
-		return false
+// versionStatus applies a dependency's Version constraint to a present
+// probe. An undeterminable version counts as a failed probe.
+func versionStatus(want string, o probeOutcome) registry.CheckStatus {
+	if o.Version == "" {
+		return registry.StatusFailed
 	}
-}
-
-func platformMatch(p registry.Platform, osInfo OSInfo) bool {
-	if len(p.OS) > 0 {
-		found := false
-		for _, os := range p.OS {
-			if os == runtime.GOOS {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return false
-		}
+	c, err := registry.ParseVersionConstraint(want)
+	if err != nil {
+		return registry.StatusFailed
 	}
-	if len(p.Arch) > 0 {
-		found := false
-		for _, arch := range p.Arch {
-			if arch == runtime.GOARCH {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return false
-		}
+	if !c.Allows(o.Version) {
+		return registry.StatusOutdated
 	}
-	return true
+	return registry.StatusPresent
+}
+
+func platformMatch(p registry.Platform) bool {
+	return p.Matches(runtime.GOOS, runtime.GOARCH)
 }
 
 func fileExists(path string) bool {
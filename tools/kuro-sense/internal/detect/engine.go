@@ -0,0 +1,326 @@
+package detect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+const (
+	// DefaultWorkers bounds how many dependency probes run at once.
+	DefaultWorkers = 8
+	// DefaultTimeout bounds a whole detection run.
+	DefaultTimeout = 30 * time.Second
+	// defaultProbeTimeout applies to kinds missing from ProbeTimeouts.
+	defaultProbeTimeout = 5 * time.Second
+)
+
+// ProbeTimeouts is the deadline for a single dependency probe, per kind.
+var ProbeTimeouts = map[registry.DependencyKind]time.Duration{
+	registry.KindBinary:   2 * time.Second,
+	registry.KindService:  1 * time.Second,
+	registry.KindFile:     1 * time.Second,
+	registry.KindEnvVar:   1 * time.Second,
+	registry.KindPython:   5 * time.Second,
+	registry.KindHardware: 10 * time.Second, // system_profiler is slow
+	registry.KindNetwork:  4 * time.Second,
+
+	registry.KindNpm:             10 * time.Second, // npm ls -g walks the global tree
+	registry.KindDockerImage:     5 * time.Second,
+	registry.KindHTTP:            5 * time.Second,
+	registry.KindMacOSPermission: 5 * time.Second,
+	registry.KindProcess:         2 * time.Second,
+	registry.KindPortFree:        1 * time.Second,
+}
+
+// The host-level scans shared by a run, as variables so tests can stand
+// in for them.
+var (
+	hardwareScan = DetectHardware
+	internetScan = checkInternet
+)
+
+// probeKey identifies a dependency probe. Dependencies with the same
+// Kind and Check are probed once per run.
+type probeKey struct {
+	Kind  registry.DependencyKind
+	Check string
+}
+
+// errNoChecker marks a dependency kind with no registered Checker.
+var errNoChecker = errors.New("no checker")
+
+// probeOutcome is the shared result of one probe.
+type probeOutcome struct {
+	Status   registry.CheckStatus
+	Duration time.Duration
+	Version  string // installed version, when some dependency constrains it
+	Err      string
+}
+
+// probe is one unique dependency probe.
+type probe struct {
+	dep         registry.Dependency // representative dependency
+	wantVersion bool                // some dependency with this key has a Version constraint
+}
+
+// Engine runs detection concurrently. Host-level scans (hardware, internet)
+// are memoized, so an Engine should be used for a single run.
+type Engine struct {
+	Workers int
+
+	hw   scan[HardwareInfo]
+	inet scan[InternetStatus]
+}
+
+// NewEngine returns an engine with a bounded worker pool.
+func NewEngine(workers int) *Engine {
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+	return &Engine{Workers: workers}
+}
+
+// Run detects all capabilities. Each probe gets its kind's timeout from
+// ProbeTimeouts, further bounded by ctx.
+func (e *Engine) Run(ctx context.Context, caps []registry.Capability) Results {
+	var res Results
+	var wg sync.WaitGroup
+
+	// Start the shared scans with the run context so a probe's own
+	// timeout never cuts them short for other probes.
+	e.hw.start(ctx, hardwareScan)
+	e.inet.start(ctx, internetScan)
+
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		res.OS = DetectOS(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		res.Hardware, _ = e.hw.wait(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		res.Network = e.network(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		res.Runtimes = DetectRuntimes(ctx)
+	}()
+
+	res.Capabilities = e.Check(ctx, caps)
+	wg.Wait()
+	return res
+}
+
+// Check detects caps without the host summary (OS, network, runtimes)
+// that Run also reports, such as to confirm a dependency after installing
+// it.
+func (e *Engine) Check(ctx context.Context, caps []registry.Capability) []registry.DetectionResult {
+	probes := uniqueProbes(caps)
+	e.startScans(ctx, probes)
+	outcomes := e.probeAll(ctx, probes)
+	results := make([]registry.DetectionResult, 0, len(caps))
+	for _, cap := range caps {
+		results = append(results, checkCapability(cap, outcomes))
+	}
+	return results
+}
+
+// startScans starts the shared scans that probes need under ctx, as Run
+// does, so the first probe to wait on one cannot start it under its own
+// per-kind timeout and leave a cut-short result for the rest.
+func (e *Engine) startScans(ctx context.Context, probes []probe) {
+	for _, p := range probes {
+		switch {
+		case p.dep.Kind == registry.KindHardware:
+			e.hw.start(ctx, hardwareScan)
+		case p.dep.Kind == registry.KindNetwork && p.dep.Check == "internet":
+			e.inet.start(ctx, internetScan)
+		}
+	}
+}
+
+// uniqueProbes collects the distinct probes needed by capabilities that
+// can run on this platform.
+func uniqueProbes(caps []registry.Capability) []probe {
+	index := make(map[probeKey]int)
+	var probes []probe
+	for _, cap := range caps {
+		if !platformMatch(cap.Platform) {
+			continue
+		}
+		for _, dep := range cap.Dependencies {
+			k := keyOf(dep)
+			i, ok := index[k]
+			if !ok {
+				i = len(probes)
+				index[k] = i
+				probes = append(probes, probe{dep: dep})
+			}
+			if dep.Version != "" {
+				probes[i].wantVersion = true
+			}
+		}
+	}
+	return probes
+}
+
+func keyOf(dep registry.Dependency) probeKey {
+	return probeKey{Kind: dep.Kind, Check: dep.Check}
+}
+
+// probeAll runs probes through a bounded worker pool.
+func (e *Engine) probeAll(ctx context.Context, probes []probe) map[probeKey]probeOutcome {
+	outcomes := make(map[probeKey]probeOutcome, len(probes))
+	var mu sync.Mutex
+
+	jobs := make(chan probe)
+	var wg sync.WaitGroup
+	workers := e.Workers
+	if workers > len(probes) {
+		workers = len(probes)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range jobs {
+				o := e.timedProbe(ctx, p)
+				mu.Lock()
+				outcomes[keyOf(p.dep)] = o
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, p := range probes {
+		jobs <- p
+	}
+	close(jobs)
+	wg.Wait()
+	return outcomes
+}
+
+// timedProbe runs one probe under its per-kind deadline and classifies
+// the result. Version constraints are evaluated per dependency later, in
+// checkCapability.
+func (e *Engine) timedProbe(ctx context.Context, p probe) probeOutcome {
+	dep := p.dep
+	timeout, ok := ProbeTimeouts[dep.Kind]
+	if !ok {
+		timeout = defaultProbeTimeout
+	}
+	pctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	present, err := e.probe(pctx, dep)
+	var o probeOutcome
+
+	switch {
+	case err == errNoChecker:
+		o.Status = registry.StatusUnknown
+		o.Err = fmt.Sprintf("no checker for kind %q", dep.Kind)
+	case present:
+		o.Status = registry.StatusPresent
+		if p.wantVersion {
+			o.Version, o.Err = e.version(pctx, dep)
+		}
+	case pctx.Err() != nil:
+		o.Status = registry.StatusTimeout
+		o.Err = pctx.Err().Error()
+	case err != nil:
+		o.Status = registry.StatusFailed
+		o.Err = err.Error()
+	default:
+		o.Status = registry.StatusMissing
+	}
+	o.Duration = time.Since(start)
+	return o
+}
+
+// probe reports whether a dependency is present, via the checker
+// registered for its kind.
+func (e *Engine) probe(ctx context.Context, dep registry.Dependency) (bool, error) {
+	c, ok := CheckerFor(dep.Kind)
+	if !ok {
+		return false, errNoChecker
+	}
+	return c.Check(ctx, e, dep)
+}
+
+// version asks the kind's checker for the installed version. The error
+// string is empty on success.
+func (e *Engine) version(ctx context.Context, dep registry.Dependency) (string, string) {
+	c, _ := CheckerFor(dep.Kind)
+	v, ok := c.(Versioner)
+	if !ok {
+		return "", fmt.Sprintf("kind %q cannot report versions", dep.Kind)
+	}
+	version, err := v.Version(ctx, dep)
+	if err != nil {
+		return "", err.Error()
+	}
+	return version, ""
+}
+
+// network runs the full network scan, reusing the memoized internet check.
+func (e *Engine) network(ctx context.Context) NetworkInfo {
+	info := NetworkInfo{}
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		info.Internet, _ = e.inet.get(ctx, internetScan)
+	}()
+	go func() {
+		defer wg.Done()
+		info.Services = checkEndpoints(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		info.VPN = detectVPN(ctx)
+	}()
+	info.LAN = detectLAN()
+	wg.Wait()
+	return info
+}
+
+// scan is a host-level scan shared by every probe in a run. It runs once,
+// under the context of whoever starts it; callers wait under their own.
+type scan[T any] struct {
+	once sync.Once
+	done chan struct{}
+	v    T
+}
+
+func (s *scan[T]) start(ctx context.Context, fn func(context.Context) T) {
+	s.once.Do(func() {
+		s.done = make(chan struct{})
+		go func() {
+			s.v = fn(ctx)
+			close(s.done)
+		}()
+	})
+}
+
+func (s *scan[T]) wait(ctx context.Context) (T, error) {
+	select {
+	case <-s.done:
+		return s.v, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func (s *scan[T]) get(ctx context.Context, fn func(context.Context) T) (T, error) {
+	s.start(ctx, fn)
+	return s.wait(ctx)
+}
@@ -0,0 +1,44 @@
+package detect
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+func TestCheckSlowScanTimesOut(t *testing.T) {
+	// The scan gives up when its context ends, as DetectHardware does
+	// when its commands are killed.
+	origScan, origTimeout := hardwareScan, ProbeTimeouts[registry.KindHardware]
+	t.Cleanup(func() {
+		hardwareScan = origScan
+		ProbeTimeouts[registry.KindHardware] = origTimeout
+	})
+	hardwareScan = func(ctx context.Context) HardwareInfo {
+		select {
+		case <-time.After(500 * time.Millisecond):
+			return HardwareInfo{Cameras: []HWDevice{{}}, Displays: []Display{{}}}
+		case <-ctx.Done():
+			return HardwareInfo{}
+		}
+	}
+	ProbeTimeouts[registry.KindHardware] = 20 * time.Millisecond
+
+	caps := []registry.Capability{{
+		Name: "vision",
+		Dependencies: []registry.Dependency{
+			{Name: "camera", Kind: registry.KindHardware, Check: "camera", Required: true},
+			{Name: "display", Kind: registry.KindHardware, Check: "display", Required: true},
+		},
+	}}
+	// One worker probes the camera and then the display, so the display
+	// probe sees whatever the camera probe left behind.
+	results := NewEngine(1).Check(context.Background(), caps)
+	for _, c := range results[0].Checks {
+		if c.Status != registry.StatusTimeout {
+			t.Errorf("%s: status %s, want %s", c.Name, c.Status, registry.StatusTimeout)
+		}
+	}
+}
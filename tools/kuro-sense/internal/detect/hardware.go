@@ -1,6 +1,7 @@
 package detect
 
 import (
+	"context"
 	"encoding/json"
 	"os/exec"
 	"runtime"
@@ -28,8 +29,11 @@ type Display struct {
 
 // HasHardware checks if a hardware type is present.
 // kind: "camera", "microphone", "speaker", "display"
-func HasHardware(kind string) bool {
-	hw := DetectHardware()
+func HasHardware(ctx context.Context, kind string) bool {
+	return hardwarePresent(DetectHardware(ctx), kind)
+}
+
+func hardwarePresent(hw HardwareInfo, kind string) bool {
 	switch kind {
 	case "camera":
 		return len(hw.Cameras) > 0
@@ -44,12 +48,12 @@ func HasHardware(kind string) bool {
 }
 
 // DetectHardware scans for perception-relevant hardware.
-func DetectHardware() HardwareInfo {
+func DetectHardware(ctx context.Context) HardwareInfo {
 	if runtime.GOOS == "darwin" {
-		return detectHardwareDarwin()
+		return detectHardwareDarwin(ctx)
 	}
 	if runtime.GOOS == "linux" {
-		return detectHardwareLinux()
+		return detectHardwareLinux(ctx)
 	}
 	return HardwareInfo{}
 }
@@ -57,10 +61,10 @@ func DetectHardware() HardwareInfo {
 // ── macOS ──
 // Single system_profiler call with JSON output for speed.
 
-func detectHardwareDarwin() HardwareInfo {
+func detectHardwareDarwin(ctx context.Context) HardwareInfo {
 	hw := HardwareInfo{}
 
-	out, err := exec.Command("system_profiler",
+	out, err := exec.CommandContext(ctx, "system_profiler",
 		"SPCameraDataType", "SPAudioDataType", "SPDisplaysDataType",
 		"-json",
 	).Output()
@@ -158,11 +162,11 @@ func parseDarwinDisplays(raw json.RawMessage) []Display {
 
 // ── Linux ──
 
-func detectHardwareLinux() HardwareInfo {
+func detectHardwareLinux(ctx context.Context) HardwareInfo {
 	hw := HardwareInfo{}
 
 	// Cameras: /dev/video*
-	if out, err := exec.Command("sh", "-c", "ls /dev/video* 2>/dev/null").Output(); err == nil {
+	if out, err := exec.CommandContext(ctx, "sh", "-c", "ls /dev/video* 2>/dev/null").Output(); err == nil {
 		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
 			if line != "" {
 				hw.Cameras = append(hw.Cameras, HWDevice{Name: line})
@@ -171,7 +175,7 @@ func detectHardwareLinux() HardwareInfo {
 	}
 
 	// Audio devices via /proc/asound
-	if out, err := exec.Command("sh", "-c", "cat /proc/asound/cards 2>/dev/null").Output(); err == nil {
+	if out, err := exec.CommandContext(ctx, "sh", "-c", "cat /proc/asound/cards 2>/dev/null").Output(); err == nil {
 		for _, line := range strings.Split(string(out), "\n") {
 			line = strings.TrimSpace(line)
 			// Lines with card info start with a number
@@ -187,7 +191,7 @@ func detectHardwareLinux() HardwareInfo {
 	}
 
 	// Displays: xrandr
-	if out, err := exec.Command("sh", "-c", "xrandr --query 2>/dev/null | grep ' connected'").Output(); err == nil {
+	if out, err := exec.CommandContext(ctx, "sh", "-c", "xrandr --query 2>/dev/null | grep ' connected'").Output(); err == nil {
 		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
 			if line == "" {
 				continue
@@ -0,0 +1,49 @@
+package detect
+
+import (
+	"fmt"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+// MissingLabels names the missing dependencies of r, marking probes that
+// timed out or failed rather than finding the dependency absent.
+func MissingLabels(r registry.DetectionResult) []string {
+	status := make(map[string]registry.CheckStatus, len(r.Checks))
+	for _, c := range r.Checks {
+		status[c.Name] = c.Status
+	}
+	names := make([]string, len(r.MissingDeps))
+	for i, d := range r.MissingDeps {
+		names[i] = d.Name
+		switch status[d.Name] {
+		case registry.StatusTimeout:
+			names[i] += " [timed out]"
+		case registry.StatusFailed:
+			names[i] += " [probe failed]"
+		}
+	}
+	return names
+}
+
+// OutdatedLabels describes each outdated dependency as "name version, need constraint".
+func OutdatedLabels(r registry.DetectionResult) []string {
+	installed := make(map[string]string, len(r.Checks))
+	for _, c := range r.Checks {
+		installed[c.Name] = c.Version
+	}
+	labels := make([]string, len(r.OutdatedDeps))
+	for i, d := range r.OutdatedDeps {
+		labels[i] = fmt.Sprintf("%s %s, need %s", d.Name, installed[d.Name], d.Version)
+	}
+	return labels
+}
+
+// UnknownLabels describes each dependency as "name [kind]".
+func UnknownLabels(deps []registry.Dependency) []string {
+	labels := make([]string, len(deps))
+	for i, d := range deps {
+		labels[i] = fmt.Sprintf("%s [%s]", d.Name, d.Kind)
+	}
+	return labels
+}
@@ -1,10 +1,12 @@
 package detect
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -54,46 +56,28 @@ var defaultEndpoints = []struct {
 
 // HasNetwork checks network reachability.
 // check: "internet" for general connectivity, or "host:port" for specific endpoint.
-func HasNetwork(check string) bool {
+func HasNetwork(ctx context.Context, check string) bool {
 	if check == "internet" {
-		return DetectNetwork().Internet.Connected
+		return checkInternet(ctx).Connected
 	}
 	// Specific endpoint check
-	conn, err := net.DialTimeout("tcp", check, 2*time.Second)
-	if err != nil {
-		return false
-	}
-	conn.Close()
-	return true
+	return dial(ctx, check, networkDialTimeout) == nil
 }
 
 // DetectNetwork performs a full network scan.
-func DetectNetwork() NetworkInfo {
-	info := NetworkInfo{}
-
-	// Internet connectivity — try a few well-known endpoints
-	info.Internet = checkInternet()
-
-	// LAN IPs
-	info.LAN = detectLAN()
-
-	// Known service endpoints
-	info.Services = checkEndpoints()
-
-	// VPN/tunnel
-	info.VPN = detectVPN()
-
-	return info
+func DetectNetwork(ctx context.Context) NetworkInfo {
+	return NewEngine(DefaultWorkers).network(ctx)
 }
 
-func checkInternet() InternetStatus {
+func checkInternet(ctx context.Context) InternetStatus {
 	// Try connecting to a reliable endpoint
 	targets := []string{"api.github.com:443", "1.1.1.1:443", "8.8.8.8:443"}
 	for _, target := range targets {
 		start := time.Now()
-		conn, err := net.DialTimeout("tcp", target, 3*time.Second)
+		dctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		err := dial(dctx, target, 3*time.Second)
+		cancel()
 		if err == nil {
-			conn.Close()
 			latency := time.Since(start)
 			return InternetStatus{
 				Connected: true,
@@ -120,26 +104,32 @@ func detectLAN() LANInfo {
 	return lan
 }
 
-func checkEndpoints() []ServiceCheck {
-	checks := make([]ServiceCheck, 0, len(defaultEndpoints))
-	for _, ep := range defaultEndpoints {
-		sc := ServiceCheck{
-			Name:     ep.name,
-			Endpoint: ep.endpoint,
-		}
-		start := time.Now()
-		conn, err := net.DialTimeout("tcp", ep.endpoint, 2*time.Second)
-		if err == nil {
-			conn.Close()
-			sc.Reachable = true
-			sc.Latency = fmt.Sprintf("%dms", time.Since(start).Milliseconds())
-		}
-		checks = append(checks, sc)
+func checkEndpoints(ctx context.Context) []ServiceCheck {
+	checks := make([]ServiceCheck, len(defaultEndpoints))
+	var wg sync.WaitGroup
+	for i, ep := range defaultEndpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sc := ServiceCheck{
+				Name:     ep.name,
+				Endpoint: ep.endpoint,
+			}
+			start := time.Now()
+			dctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+			if dial(dctx, ep.endpoint, networkDialTimeout) == nil {
+				sc.Reachable = true
+				sc.Latency = fmt.Sprintf("%dms", time.Since(start).Milliseconds())
+			}
+			checks[i] = sc
+		}()
 	}
+	wg.Wait()
 	return checks
 }
 
-func detectVPN() VPNInfo {
+func detectVPN(ctx context.Context) VPNInfo {
 	vpn := VPNInfo{}
 
 	ifaces, err := net.Interfaces()
@@ -167,7 +157,7 @@ func detectVPN() VPNInfo {
 	// Also check for tailscale specifically
 	if !vpn.Active {
 		if _, err := exec.LookPath("tailscale"); err == nil {
-			if out, err := exec.Command("tailscale", "status", "--json").Output(); err == nil {
+			if out, err := exec.CommandContext(ctx, "tailscale", "status", "--json").Output(); err == nil {
 				if strings.Contains(string(out), `"Online":true`) || strings.Contains(string(out), `"BackendState":"Running"`) {
 					vpn.Active = true
 					vpn.Interfaces = append(vpn.Interfaces, "tailscale")
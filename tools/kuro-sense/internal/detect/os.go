@@ -1,6 +1,7 @@
 package detect
 
 import (
+	"context"
 	"os/exec"
 	"runtime"
 	"strings"
@@ -15,25 +16,25 @@ type OSInfo struct {
 }
 
 // DetectOS returns OS and architecture info.
-func DetectOS() OSInfo {
+func DetectOS(ctx context.Context) OSInfo {
 	info := OSInfo{
 		OS:   runtime.GOOS,
 		Arch: runtime.GOARCH,
 	}
 
-	if out, err := exec.Command("hostname").Output(); err == nil {
+	if out, err := exec.CommandContext(ctx, "hostname").Output(); err == nil {
 		info.Hostname = strings.TrimSpace(string(out))
 	}
 
-	if home, err := homeDir(); err == nil {
+	if home, err := homeDir(ctx); err == nil {
 		info.Home = home
 	}
 
 	return info
 }
 
-func homeDir() (string, error) {
-	out, err := exec.Command("sh", "-c", "echo $HOME").Output()
+func homeDir(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "sh", "-c", "echo $HOME").Output()
 	if err != nil {
 		return "", err
 	}
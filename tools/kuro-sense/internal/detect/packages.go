@@ -0,0 +1,70 @@
+package detect
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+// npmChecker checks for a globally installed npm package.
+// Check is the package name (e.g. "lighthouse").
+type npmChecker struct{}
+
+func (npmChecker) Check(ctx context.Context, _ *Engine, dep registry.Dependency) (bool, error) {
+	if !HasBinary(ctx, "npm") {
+		return false, fmt.Errorf("npm not found")
+	}
+	// `npm ls -g <pkg>` exits non-zero when the package is not installed.
+	return exitStatus(exec.CommandContext(ctx, "npm", "ls", "-g", "--depth=0", dep.Check))
+}
+
+func (npmChecker) Install(dep registry.Dependency) []registry.InstallHint {
+	if len(dep.Install) > 0 {
+		return dep.Install
+	}
+	return []registry.InstallHint{{Method: registry.InstallNpm, Package: dep.Check}}
+}
+
+// dockerImageChecker checks that an image is present locally.
+// Check is the image reference (e.g. "redis:7").
+type dockerImageChecker struct{}
+
+func (dockerImageChecker) Check(ctx context.Context, _ *Engine, dep registry.Dependency) (bool, error) {
+	if !HasBinary(ctx, "docker") {
+		return false, fmt.Errorf("docker not found")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", "image", "inspect", dep.Check)
+	cmd.Stderr = &stderr
+	ok, err := exitStatus(cmd)
+	if err == nil && !ok && !bytes.Contains(bytes.ToLower(stderr.Bytes()), []byte("no such image")) {
+		// Non-zero for another reason, most likely the daemon is down.
+		return false, fmt.Errorf("docker: %s", bytes.TrimSpace(stderr.Bytes()))
+	}
+	return ok, err
+}
+
+func (dockerImageChecker) Install(dep registry.Dependency) []registry.InstallHint {
+	if len(dep.Install) > 0 {
+		return dep.Install
+	}
+	return []registry.InstallHint{{Method: registry.InstallDocker, Package: dep.Check}}
+}
+
+// exitStatus runs cmd and maps exit 0 to true and any other exit code to
+// false. Errors are returned only when the command could not run.
+func exitStatus(cmd *exec.Cmd) (bool, error) {
+	err := cmd.Run()
+	if err == nil {
+		return true, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return false, nil
+	}
+	return false, err
+}
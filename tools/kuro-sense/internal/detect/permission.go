@@ -0,0 +1,74 @@
+package detect
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+// permissionChecker checks a macOS privacy permission for the current
+// terminal. Check: "screen-recording" / "accessibility" / "full-disk-access".
+type permissionChecker struct{}
+
+// JXA snippets that return "true" when the permission is granted.
+var permissionScripts = map[string]string{
+	"screen-recording": `ObjC.import("CoreGraphics"); $.CGPreflightScreenCaptureAccess()`,
+	"accessibility":    `ObjC.import("ApplicationServices"); $.AXIsProcessTrusted()`,
+}
+
+// System Settings panes for each permission.
+var permissionPanes = map[string]string{
+	"screen-recording": "Privacy_ScreenCapture",
+	"accessibility":    "Privacy_Accessibility",
+	"full-disk-access": "Privacy_AllFiles",
+}
+
+func (permissionChecker) Check(ctx context.Context, _ *Engine, dep registry.Dependency) (bool, error) {
+	if runtime.GOOS != "darwin" {
+		return false, nil
+	}
+
+	if dep.Check == "full-disk-access" {
+		// The TCC database is only readable with Full Disk Access.
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return false, err
+		}
+		f, err := os.Open(filepath.Join(home, "Library/Application Support/com.apple.TCC/TCC.db"))
+		if err != nil {
+			return false, nil
+		}
+		f.Close()
+		return true, nil
+	}
+
+	script, ok := permissionScripts[dep.Check]
+	if !ok {
+		return false, fmt.Errorf("cannot probe permission %q", dep.Check)
+	}
+	out, err := exec.CommandContext(ctx, "osascript", "-l", "JavaScript", "-e", script).Output()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(out)) == "true", nil
+}
+
+func (permissionChecker) Install(dep registry.Dependency) []registry.InstallHint {
+	if len(dep.Install) > 0 {
+		return dep.Install
+	}
+	pane := permissionPanes[dep.Check]
+	if pane == "" {
+		pane = "Privacy"
+	}
+	return []registry.InstallHint{{
+		Method:  registry.InstallManual,
+		Command: fmt.Sprintf(`open "x-apple.systempreferences:com.apple.preference.security?%s"`, pane),
+	}}
+}
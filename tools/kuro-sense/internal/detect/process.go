@@ -0,0 +1,55 @@
+package detect
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+// processChecker checks that a process with the exact name is running.
+// Check is the process name (e.g. "ollama").
+type processChecker struct{}
+
+func (processChecker) Check(ctx context.Context, _ *Engine, dep registry.Dependency) (bool, error) {
+	if !HasBinary(ctx, "pgrep") {
+		return false, fmt.Errorf("pgrep not found")
+	}
+	return exitStatus(exec.CommandContext(ctx, "pgrep", "-x", dep.Check))
+}
+
+func (processChecker) Install(dep registry.Dependency) []registry.InstallHint {
+	if len(dep.Install) > 0 {
+		return dep.Install
+	}
+	return []registry.InstallHint{{Method: registry.InstallManual, Command: "start " + dep.Check}}
+}
+
+// portFreeChecker checks that nothing listens on a TCP port.
+// Check is "port" or "host:port" (e.g. "8090", "127.0.0.1:3001").
+type portFreeChecker struct{}
+
+func (portFreeChecker) Check(ctx context.Context, _ *Engine, dep registry.Dependency) (bool, error) {
+	addr := dep.Check
+	if !strings.Contains(addr, ":") {
+		addr = ":" + addr
+	}
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return false, nil
+	}
+	ln.Close()
+	return true, nil
+}
+
+func (portFreeChecker) Install(dep registry.Dependency) []registry.InstallHint {
+	if len(dep.Install) > 0 {
+		return dep.Install
+	}
+	port := dep.Check[strings.LastIndex(dep.Check, ":")+1:]
+	return []registry.InstallHint{{Method: registry.InstallManual, Command: "lsof -i :" + port + "  # stop the process holding the port"}}
+}
@@ -1,20 +1,75 @@
 package detect
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
-	"strings"
+	"sync/atomic"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/secrets"
 )
 
-// HasEnvVar checks if an environment variable is set and non-empty.
+var envFile atomic.Value // string
+
+// SetEnvFile makes HasEnvVar also look in the secrets file at path, which
+// the agent loads into its environment at startup.
+func SetEnvFile(path string) {
+	envFile.Store(path)
+}
+
+// HasEnvVar checks if an environment variable is set and non-empty, in the
+// process environment or the secrets file.
 func HasEnvVar(name string) bool {
-	return os.Getenv(name) != ""
+	if os.Getenv(name) != "" {
+		return true
+	}
+	path, _ := envFile.Load().(string)
+	return path != "" && secrets.Lookup(path, name) != ""
+}
+
+// envVarChecker looks for a variable in the environment and the secrets
+// file. It is installed by setting it with the secrets command.
+type envVarChecker struct{}
+
+func (envVarChecker) Check(_ context.Context, _ *Engine, dep registry.Dependency) (bool, error) {
+	return HasEnvVar(dep.Check), nil
+}
+
+func (envVarChecker) Install(dep registry.Dependency) []registry.InstallHint {
+	hints := append([]registry.InstallHint(nil), dep.Install...)
+	return append(hints, registry.InstallHint{
+		Method:  registry.InstallManual,
+		Command: "kuro-sense secrets set " + dep.Check,
+	})
 }
 
 // HasPythonModule checks if a Python module is importable.
-func HasPythonModule(module string) bool {
-	cmd := exec.Command("python3", "-c", "import "+module)
-	return cmd.Run() == nil
+func HasPythonModule(ctx context.Context, module string) bool {
+	ok, _ := pythonModule(ctx, module)
+	return ok
+}
+
+// pythonModule reports whether module imports. The error is non-nil when
+// the probe itself could not run (no python3, cancelled).
+func pythonModule(ctx context.Context, module string) (bool, error) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		return false, fmt.Errorf("python3 not found")
+	}
+	err := exec.CommandContext(ctx, "python3", "-c", "import "+module).Run()
+	if err == nil {
+		return true, nil
+	}
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return false, nil // import failed: module missing
+	}
+	return false, err
 }
 
 // RuntimeVersions holds detected runtime versions.
@@ -25,19 +80,16 @@ type RuntimeVersions struct {
 }
 
 // DetectRuntimes checks for common runtime versions.
-func DetectRuntimes() RuntimeVersions {
+func DetectRuntimes(ctx context.Context) RuntimeVersions {
 	rv := RuntimeVersions{}
-	if out, err := exec.Command("node", "--version").Output(); err == nil {
-		rv.Node = strings.TrimSpace(string(out))
+	if v, err := BinaryVersion(ctx, "node"); err == nil {
+		rv.Node = "v" + v
 	}
-	if out, err := exec.Command("python3", "--version").Output(); err == nil {
-		rv.Python = strings.TrimPrefix(strings.TrimSpace(string(out)), "Python ")
+	if v, err := BinaryVersion(ctx, "python3"); err == nil {
+		rv.Python = v
 	}
-	if out, err := exec.Command("go", "version").Output(); err == nil {
-		parts := strings.Fields(string(out))
-		if len(parts) >= 3 {
-			rv.Go = strings.TrimPrefix(parts[2], "go")
-		}
+	if v, err := BinaryVersion(ctx, "go"); err == nil {
+		rv.Go = v
 	}
 	return rv
 }
@@ -1,17 +1,46 @@
 package detect
 
 import (
+	"context"
 	"net"
+	"net/http"
 	"time"
 )
 
+// Default dial timeouts; a shorter ctx deadline still wins.
+const (
+	serviceDialTimeout = 500 * time.Millisecond
+	networkDialTimeout = 2 * time.Second
+)
+
 // HasService checks if a TCP service is listening on the given address.
 // addr should be "host:port" (e.g. "localhost:9867").
-func HasService(addr string) bool {
-	conn, err := net.DialTimeout("tcp", addr, 500*time.Millisecond)
+func HasService(ctx context.Context, addr string) bool {
+	return dial(ctx, addr, serviceDialTimeout) == nil
+}
+
+// dial opens and closes a TCP connection, giving up after timeout or
+// when ctx is done, whichever comes first.
+func dial(ctx context.Context, addr string, timeout time.Duration) error {
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
 	if err != nil {
-		return false
+		return err
 	}
 	conn.Close()
-	return true
+	return nil
+}
+
+// HasHTTP checks that a GET on url returns a 2xx status.
+func HasHTTP(ctx context.Context, url string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
 }
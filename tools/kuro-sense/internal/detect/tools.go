@@ -1,11 +1,63 @@
 package detect
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"os/exec"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
 )
 
 // HasBinary checks if a binary is available in PATH.
-func HasBinary(name string) bool {
+func HasBinary(ctx context.Context, name string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
 	_, err := exec.LookPath(name)
 	return err == nil
 }
+
+// versionArgs lists the arguments that print a binary's version, for
+// binaries that do not accept --version.
+var versionArgs = map[string][]string{
+	"go":   {"version"},
+	"java": {"-version"},
+}
+
+// BinaryVersion runs the binary's version command and extracts the first
+// dotted version number from its output.
+func BinaryVersion(ctx context.Context, name string) (string, error) {
+	args, ok := versionArgs[name]
+	if !ok {
+		args = []string{"--version"}
+	}
+	// Some tools (python2, java) print their version on stderr.
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil && out.Len() == 0 {
+		return "", err
+	}
+	v := registry.ExtractVersion(out.String())
+	if v == "" {
+		return "", fmt.Errorf("no version in %s output", name)
+	}
+	return v, nil
+}
+
+// binaryChecker checks PATH and reports versions via BinaryVersion.
+type binaryChecker struct{}
+
+func (binaryChecker) Check(ctx context.Context, _ *Engine, dep registry.Dependency) (bool, error) {
+	return HasBinary(ctx, dep.Check), nil
+}
+
+func (binaryChecker) Install(dep registry.Dependency) []registry.InstallHint {
+	return dep.Install
+}
+
+func (binaryChecker) Version(ctx context.Context, dep registry.Dependency) (string, error) {
+	return BinaryVersion(ctx, dep.Check)
+}
@@ -0,0 +1,108 @@
+package installer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+// BinDir is where downloaded binaries are installed.
+func BinDir() string {
+	if dir := os.Getenv("KURO_SENSE_BIN_DIR"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".", "bin")
+	}
+	return filepath.Join(home, ".local", "bin")
+}
+
+// download fetches a single binary from h.Package into BinDir under the
+// dependency's check name. The file is only put in place once its
+// checksum matches h.SHA256.
+func download(ctx context.Context, dep registry.Dependency, h registry.InstallHint, out io.Writer) error {
+	if h.SHA256 == "" {
+		return fmt.Errorf("refusing to download %s without a sha256", h.Package)
+	}
+	dir := BinDir()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create %s: %w", dir, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.Package, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("download %s: %w", h.Package, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s: %s", h.Package, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".kuro-sense-download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+
+	fmt.Fprintf(out, "  Downloading %s\n", h.Package)
+	sum := sha256.New()
+	body := io.TeeReader(resp.Body, sum)
+	if resp.ContentLength > 0 {
+		body = &progress{r: body, total: resp.ContentLength, out: out}
+	}
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("download %s: %w", h.Package, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	got := hex.EncodeToString(sum.Sum(nil))
+	if !strings.EqualFold(got, h.SHA256) {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", h.Package, got, h.SHA256)
+	}
+	fmt.Fprintf(out, "  Checksum OK (sha256 %s)\n", got)
+
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+	dest := filepath.Join(dir, filepath.Base(dep.Check))
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("install %s: %w", dest, err)
+	}
+	fmt.Fprintf(out, "  Installed %s\n", dest)
+	return nil
+}
+
+// progress reports download progress in 10% steps.
+type progress struct {
+	r     io.Reader
+	total int64
+	read  int64
+	step  int64
+	out   io.Writer
+}
+
+func (p *progress) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if step := p.read * 10 / p.total; step > p.step {
+		p.step = step
+		fmt.Fprintf(p.out, "  %3d%%  %.1f / %.1f MB\n", step*10, float64(p.read)/(1<<20), float64(p.total)/(1<<20))
+	}
+	return n, err
+}
@@ -0,0 +1,186 @@
+// Package installer installs missing dependencies with whichever package
+// manager the host has, and confirms the result by re-running detection.
+package installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/detect"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+// ErrManual means the chosen hint is a command for the user to run.
+var ErrManual = errors.New("manual installation required")
+
+// managerBinaries are the executables that make a method usable. curl
+// downloads natively and manual needs nothing, so both are always usable.
+var managerBinaries = map[registry.InstallMethod][]string{
+	registry.InstallBrew:   {"brew"},
+	registry.InstallApt:    {"apt-get"},
+	registry.InstallDnf:    {"dnf"},
+	registry.InstallPacman: {"pacman"},
+	registry.InstallApk:    {"apk"},
+	registry.InstallPip:    {"pip3", "pip"},
+	registry.InstallNpm:    {"npm"},
+	registry.InstallGo:     {"go"},
+	registry.InstallDocker: {"docker"},
+}
+
+// Managers maps each usable install method to the executable that runs it.
+type Managers map[registry.InstallMethod]string
+
+// DetectManagers looks for package managers on PATH.
+func DetectManagers() Managers {
+	m := Managers{registry.InstallCurl: "", registry.InstallManual: ""}
+	for method, bins := range managerBinaries {
+		for _, bin := range bins {
+			if _, err := exec.LookPath(bin); err == nil {
+				m[method] = bin
+				break
+			}
+		}
+	}
+	return m
+}
+
+// Methods lists the usable methods in preference order.
+func (m Managers) Methods() []registry.InstallMethod {
+	var out []registry.InstallMethod
+	for _, method := range preference(runtime.GOOS) {
+		if _, ok := m[method]; ok {
+			out = append(out, method)
+		}
+	}
+	return out
+}
+
+// preference orders install methods for goos: the native system package
+// manager first, then language package managers, then downloads, and a
+// manual command last.
+func preference(goos string) []registry.InstallMethod {
+	system := []registry.InstallMethod{registry.InstallBrew}
+	if goos == "linux" {
+		system = []registry.InstallMethod{
+			registry.InstallApt, registry.InstallDnf, registry.InstallPacman, registry.InstallApk,
+			registry.InstallBrew,
+		}
+	}
+	return append(system,
+		registry.InstallPip, registry.InstallNpm, registry.InstallGo,
+		registry.InstallCurl, registry.InstallDocker, registry.InstallManual,
+	)
+}
+
+// Choose picks the hint for dep that the host can run: among hints for
+// this platform, the one whose method comes first in preference order.
+func Choose(dep registry.Dependency, m Managers) (registry.InstallHint, error) {
+	hints := detect.InstallHints(dep)
+	if len(hints) == 0 {
+		return registry.InstallHint{}, fmt.Errorf("no install hints for %s", dep.Name)
+	}
+	for _, method := range m.Methods() {
+		for _, h := range hints {
+			if h.Method == method && h.Platform.Matches(runtime.GOOS, runtime.GOARCH) {
+				return h, nil
+			}
+		}
+	}
+	var known []string
+	for _, h := range hints {
+		known = append(known, string(h.Method))
+	}
+	return registry.InstallHint{}, fmt.Errorf("no usable install method for %s on %s/%s (known: %s)",
+		dep.Name, runtime.GOOS, runtime.GOARCH, strings.Join(known, ", "))
+}
+
+// Command returns the command line that installs h, or nil for methods
+// that do not run one (curl, manual).
+func Command(h registry.InstallHint, m Managers) []string {
+	bin := m[h.Method]
+	switch h.Method {
+	case registry.InstallBrew:
+		return []string{bin, "install", h.Package}
+	case registry.InstallApt:
+		return sudo(bin, "install", "-y", h.Package)
+	case registry.InstallDnf:
+		return sudo(bin, "install", "-y", h.Package)
+	case registry.InstallPacman:
+		return sudo(bin, "-S", "--noconfirm", "--needed", h.Package)
+	case registry.InstallApk:
+		return sudo(bin, "add", h.Package)
+	case registry.InstallPip:
+		return []string{bin, "install", "--user", h.Package}
+	case registry.InstallNpm:
+		return []string{bin, "install", "-g", h.Package}
+	case registry.InstallGo:
+		pkg := h.Package
+		if !strings.Contains(pkg, "@") {
+			pkg += "@latest"
+		}
+		return []string{bin, "install", pkg}
+	case registry.InstallDocker:
+		return []string{bin, "pull", h.Package}
+	}
+	return nil
+}
+
+// sudo prefixes a system package manager command with sudo unless
+// already running as root.
+func sudo(args ...string) []string {
+	if os.Geteuid() == 0 {
+		return args
+	}
+	return append([]string{"sudo"}, args...)
+}
+
+// Describe is a one-line summary of how h installs dep.
+func Describe(dep registry.Dependency, h registry.InstallHint, m Managers) string {
+	switch h.Method {
+	case registry.InstallCurl:
+		return fmt.Sprintf("download %s to %s", h.Package, BinDir())
+	case registry.InstallManual:
+		return h.Command
+	}
+	return strings.Join(Command(h, m), " ")
+}
+
+// Install runs h, streaming the package manager's output to out. A manual
+// hint prints its command and returns ErrManual.
+func Install(ctx context.Context, dep registry.Dependency, h registry.InstallHint, m Managers, out io.Writer) error {
+	switch h.Method {
+	case registry.InstallManual:
+		fmt.Fprintf(out, "  Run: %s\n", h.Command)
+		return ErrManual
+	case registry.InstallCurl:
+		return download(ctx, dep, h, out)
+	}
+
+	args := Command(h, m)
+	if args == nil {
+		return fmt.Errorf("cannot install with %s", h.Method)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.Stdin = os.Stdin // sudo may ask for a password
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// Verify re-runs detection for dep and returns its check.
+func Verify(ctx context.Context, dep registry.Dependency) registry.DependencyCheck {
+	results := detect.NewEngine(1).Check(ctx, []registry.Capability{{
+		Name:         dep.Name,
+		Dependencies: []registry.Dependency{dep},
+	}})
+	return results[0].Checks[0]
+}
@@ -0,0 +1,180 @@
+// Package lint cross-checks the capability registry against the scripts
+// in plugins/ and the custom perceptions in agent-compose.yaml, for
+// `kuro-sense registry lint` and CI.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+// FindingKind classifies a lint finding.
+type FindingKind string
+
+const (
+	UnregisteredScript   FindingKind = "unregistered-script"    // executable in plugins/ with no capability
+	MissingScript        FindingKind = "missing-script"         // capability Script does not exist
+	ComposeMissingScript FindingKind = "compose-missing-script" // compose entry points at a missing file
+	ComposeUnregistered  FindingKind = "compose-unregistered"   // compose entry matches no capability
+	NameMismatch         FindingKind = "name-mismatch"          // compose name and registry script disagree
+	InvalidManifest      FindingKind = "invalid-manifest"       // manifest file failed validation
+	InvalidCompose       FindingKind = "invalid-compose"        // compose file or agent could not be read
+)
+
+// Finding is one inconsistency between the registry, plugins/ and the compose file.
+type Finding struct {
+	Kind    FindingKind `json:"kind"`
+	Name    string      `json:"name,omitempty"`
+	Path    string      `json:"path,omitempty"`
+	Message string      `json:"message"`
+}
+
+// Report is the outcome of a lint run.
+type Report struct {
+	Capabilities   int       `json:"capabilities"`
+	Scripts        int       `json:"scripts"`
+	ComposeEntries int       `json:"composeEntries"`
+	ComposeSkipped string    `json:"composeSkipped,omitempty"` // set when there is no compose file
+	Findings       []Finding `json:"findings"`
+}
+
+// OK reports whether the lint run found no problems.
+func (r Report) OK() bool {
+	return len(r.Findings) == 0
+}
+
+// Run cross-checks capabilities, executable files in agentDir/plugins and the
+// perception.custom entries of the selected agent in agent-compose.yaml
+// (compose.AllAgents checks every agent).
+func Run(agentDir, agent string, caps []registry.Capability, manifestErrs []registry.ManifestError) Report {
+	report := Report{Capabilities: len(caps), Findings: []Finding{}}
+
+	for _, e := range manifestErrs {
+		report.add(Finding{Kind: InvalidManifest, Path: relPath(agentDir, e.Path), Message: e.Err.Error()})
+	}
+
+	// Index capabilities by normalized script path.
+	byScript := make(map[string]registry.Capability, len(caps))
+	byName := make(map[string]registry.Capability, len(caps))
+	for _, c := range caps {
+		byName[c.Name] = c
+		if c.Script == "" {
+			report.add(Finding{Kind: MissingScript, Name: c.Name, Message: "capability has no script"})
+			continue
+		}
+		script := normScript(c.Script)
+		byScript[script] = c
+		if !fileExists(agentDir, script) {
+			report.add(Finding{Kind: MissingScript, Name: c.Name, Path: script,
+				Message: fmt.Sprintf("script %s does not exist", script)})
+		}
+	}
+
+	// Executables in plugins/ without a capability definition.
+	scripts := pluginScripts(agentDir)
+	report.Scripts = len(scripts)
+	for _, s := range scripts {
+		if _, ok := byScript[s]; !ok {
+			report.add(Finding{Kind: UnregisteredScript, Path: s,
+				Message: fmt.Sprintf("%s has no capability definition", s)})
+		}
+	}
+
+	// Compose entries.
+	// Only a missing file is skipped; anything else would let a broken
+	// compose file pass CI.
+	cf, err := compose.Load(agentDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		report.ComposeSkipped = err.Error()
+		return report
+	}
+	if err != nil {
+		report.add(Finding{Kind: InvalidCompose, Path: "agent-compose.yaml", Message: err.Error()})
+		return report
+	}
+	entries, err := compose.GetCustomPerceptions(cf, agent)
+	if err != nil {
+		report.add(Finding{Kind: InvalidCompose, Path: "agent-compose.yaml", Message: err.Error()})
+		return report
+	}
+	report.ComposeEntries = len(entries)
+	for _, p := range entries {
+		script := normScript(p.Script)
+		if !fileExists(agentDir, script) {
+			report.add(Finding{Kind: ComposeMissingScript, Name: p.Name, Path: script,
+				Message: fmt.Sprintf("compose entry %s points at missing file %s", p.Name, script)})
+		}
+
+		named, hasName := byName[p.Name]
+		scripted, hasScript := byScript[script]
+		switch {
+		case hasName && normScript(named.Script) != script:
+			report.add(Finding{Kind: NameMismatch, Name: p.Name, Path: script,
+				Message: fmt.Sprintf("compose entry %s uses %s, registry expects %s", p.Name, script, normScript(named.Script))})
+		case !hasName && hasScript:
+			report.add(Finding{Kind: NameMismatch, Name: p.Name, Path: script,
+				Message: fmt.Sprintf("compose entry %s runs %s, which the registry names %s", p.Name, script, scripted.Name)})
+		case !hasName:
+			report.add(Finding{Kind: ComposeUnregistered, Name: p.Name, Path: script,
+				Message: fmt.Sprintf("compose entry %s has no capability definition", p.Name)})
+		}
+	}
+
+	return report
+}
+
+func (r *Report) add(f Finding) {
+	r.Findings = append(r.Findings, f)
+}
+
+// pluginScripts lists executable regular files in agentDir/plugins as
+// normalized relative paths (e.g. "plugins/agora-inbox.sh").
+func pluginScripts(agentDir string) []string {
+	entries, err := os.ReadDir(filepath.Join(agentDir, "plugins"))
+	if err != nil {
+		return nil
+	}
+	var scripts []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		scripts = append(scripts, filepath.ToSlash(filepath.Join("plugins", e.Name())))
+	}
+	sort.Strings(scripts)
+	return scripts
+}
+
+// normScript turns "./plugins/x.sh" into "plugins/x.sh" so registry and
+// compose paths compare equal. Absolute paths are only cleaned.
+func normScript(script string) string {
+	return filepath.ToSlash(filepath.Clean(script))
+}
+
+func fileExists(agentDir, script string) bool {
+	path := script
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(agentDir, path)
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func relPath(agentDir, path string) string {
+	if rel, err := filepath.Rel(agentDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
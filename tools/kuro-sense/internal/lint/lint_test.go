@@ -0,0 +1,122 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+func TestRun(t *testing.T) {
+	caps := []registry.Capability{
+		{Name: "github-issues", Script: "./plugins/github-issues.sh"},
+		{Name: "state-changes", Script: "./plugins/state-watcher.sh"},
+	}
+	scripts := []string{"github-issues.sh", "state-watcher.sh"}
+	const composeOK = `agents:
+  kuro:
+    perception:
+      custom:
+        - name: github-issues
+          script: ./plugins/github-issues.sh
+`
+	for _, tt := range []struct {
+		name    string
+		scripts []string // executables in plugins/
+		compose string   // agent-compose.yaml; "" = none
+		agent   string
+		want    []FindingKind
+		skipped bool
+	}{
+		{name: "consistent", scripts: scripts, compose: composeOK},
+		{
+			name:    "unregistered script",
+			scripts: append([]string{"agora-inbox.sh"}, scripts...),
+			compose: composeOK,
+			want:    []FindingKind{UnregisteredScript},
+		},
+		{
+			name:    "missing script",
+			scripts: []string{"github-issues.sh"},
+			compose: composeOK,
+			want:    []FindingKind{MissingScript},
+		},
+		{
+			name:    "compose entry points at a missing file",
+			scripts: scripts,
+			compose: composeOK + `        - name: email-check
+          script: ./plugins/email-check.sh
+`,
+			want: []FindingKind{ComposeMissingScript, ComposeUnregistered},
+		},
+		{
+			name:    "compose entry with another script",
+			scripts: scripts,
+			compose: composeOK + `        - name: state-changes
+          script: ./plugins/github-issues.sh
+`,
+			want: []FindingKind{NameMismatch},
+		},
+		{
+			name:    "compose entry under another name",
+			scripts: scripts,
+			compose: composeOK + `        - name: watcher
+          script: ./plugins/state-watcher.sh
+`,
+			want: []FindingKind{NameMismatch},
+		},
+		{name: "no compose file", scripts: scripts, skipped: true},
+		{
+			name:    "unparsable compose file",
+			scripts: scripts,
+			compose: "agents: [\n",
+			want:    []FindingKind{InvalidCompose},
+		},
+		{
+			name:    "unknown agent",
+			scripts: scripts,
+			compose: composeOK,
+			agent:   "nobody",
+			want:    []FindingKind{InvalidCompose},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.Mkdir(filepath.Join(dir, "plugins"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.scripts {
+				if err := os.WriteFile(filepath.Join(dir, "plugins", s), []byte("#!/bin/sh\n"), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.compose != "" {
+				if err := os.WriteFile(filepath.Join(dir, "agent-compose.yaml"), []byte(tt.compose), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			agent := tt.agent
+			if agent == "" {
+				agent = compose.AllAgents
+			}
+
+			report := Run(dir, agent, caps, nil)
+			var got []FindingKind
+			for _, f := range report.Findings {
+				got = append(got, f.Kind)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findings = %v, want %v (%+v)", got, tt.want, report.Findings)
+			}
+			if report.OK() != (len(tt.want) == 0) {
+				t.Errorf("OK() = %v with findings %v", report.OK(), got)
+			}
+			if (report.ComposeSkipped != "") != tt.skipped {
+				t.Errorf("ComposeSkipped = %q", report.ComposeSkipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package pack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Pack configuration files (relative to the agent directory).
+const (
+	ConfigFile = ".kuro-sense-pack.yaml"
+	IgnoreFile = ".kuro-sense-packignore"
+)
+
+// defaultInclude is what pack archives without a config.
+var defaultInclude = []string{
+	"/memory/",
+	"/plugins/",
+	"/skills/",
+	"/scripts/",
+	"/agent-compose.yaml",
+}
+
+// builtinExclude is always applied first; a config can re-include with
+// "!pattern".
+var builtinExclude = []string{
+	"node_modules",
+	"dist",
+	".env",
+	"*.log",
+	"memory-index.db",
+	"server.log",
+	"/" + StateDir + "/",
+	stagingPrefix + "*",
+}
+
+// Config selects what Pack archives. Paths are relative to the agent
+// directory (or the home directory for instance data) and use gitignore
+// pattern syntax.
+//
+//	# .kuro-sense-pack.yaml
+//	include: [/memory/, /plugins/, /agent-compose.yaml]
+//	exclude: [memory/cache/*.json, "*.tmp"]
+//	max_size:
+//	  "memory/**": 20MB
+//	  "*.db": 5MB
+//	instance: true   # also pack ~/.mini-agent
+//
+// A pattern without a slash matches at any depth, so "/memory/" (not
+// "memory/") includes only the top-level memory directory. Lines of
+// .kuro-sense-packignore are added to exclude.
+type Config struct {
+	Include  []string        `yaml:"include" json:"include"`
+	Exclude  []string        `yaml:"exclude" json:"exclude"`
+	MaxSize  map[string]Size `yaml:"max_size" json:"maxSize,omitempty"`
+	Instance bool            `yaml:"instance" json:"instance"`
+}
+
+// Size is a byte count written as 512, 64KB, 20MB or 1GB.
+type Size int64
+
+func (s *Size) UnmarshalYAML(n *yaml.Node) error {
+	v, err := ParseSize(n.Value)
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// ParseSize parses a byte count with an optional B, KB, MB or GB suffix
+// (powers of 1024).
+func ParseSize(s string) (Size, error) {
+	t := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if rest, ok := strings.CutSuffix(t, u.suffix); ok {
+			t, mult = strings.TrimSpace(rest), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(t, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("size %q: want a number with an optional KB, MB or GB suffix", s)
+	}
+	return Size(n * float64(mult)), nil
+}
+
+// LoadConfig reads the pack config and ignore file in agentDir. Without
+// a config the default include list is used.
+func LoadConfig(agentDir string) (Config, error) {
+	var cfg Config
+	path := filepath.Join(agentDir, ConfigFile)
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	case !os.IsNotExist(err):
+		return Config{}, err
+	}
+	if len(cfg.Include) == 0 {
+		cfg.Include = append([]string(nil), defaultInclude...)
+	}
+
+	ignore := filepath.Join(agentDir, IgnoreFile)
+	data, err = os.ReadFile(ignore)
+	switch {
+	case err == nil:
+		cfg.Exclude = append(cfg.Exclude, strings.Split(string(data), "\n")...)
+	case !os.IsNotExist(err):
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// compiled is a Config ready for matching.
+type compiled struct {
+	include matcher
+	exclude matcher
+	roots   []string // where to walk for include; "" is the whole tree
+	limits  []sizeLimit
+}
+
+type sizeLimit struct {
+	pattern pattern
+	max     int64
+}
+
+func (c Config) compile() (*compiled, error) {
+	if len(c.Include) == 0 {
+		c.Include = defaultInclude
+	}
+	include, err := compilePatterns(c.Include)
+	if err != nil {
+		return nil, fmt.Errorf("include: %w", err)
+	}
+	exclude, err := compilePatterns(append(append([]string(nil), builtinExclude...), c.Exclude...))
+	if err != nil {
+		return nil, fmt.Errorf("exclude: %w", err)
+	}
+	out := &compiled{include: include, exclude: exclude}
+	for _, p := range include {
+		if !p.negate {
+			out.roots = append(out.roots, p.prefix)
+		}
+	}
+	for text, max := range c.MaxSize {
+		p, ok, err := compilePattern(text)
+		if err != nil {
+			return nil, fmt.Errorf("max_size: %w", err)
+		}
+		if ok {
+			out.limits = append(out.limits, sizeLimit{pattern: p, max: int64(max)})
+		}
+	}
+	return out, nil
+}
+
+// limitFor returns the smallest size limit whose pattern matches rel or
+// one of its parent directories, or -1 for none.
+func (c *compiled) limitFor(rel string) (int64, string) {
+	limit, by := int64(-1), ""
+	for _, l := range c.limits {
+		if (matcher{l.pattern}).matchPath(rel, false) && (limit < 0 || l.max < limit) {
+			limit, by = l.max, l.pattern.text
+		}
+	}
+	return limit, by
+}
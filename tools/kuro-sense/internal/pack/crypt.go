@@ -0,0 +1,409 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Encrypted archives wrap the tar.gz in an age-style envelope:
+//
+//	kuro-sense-encrypted/v1
+//	-> x25519 <ephemeral public key> <wrapped file key>
+//	-> passphrase <salt> <iterations> <wrapped file key>
+//	--- <payload nonce> <header MAC>
+//	<payload>
+//
+// A random 32-byte file key is wrapped once per recipient (X25519 + HKDF)
+// or once with a passphrase (PBKDF2-SHA256), each with AES-256-GCM. The
+// header is authenticated with HMAC-SHA256 under a key derived from the
+// file key, and the payload is AES-256-GCM in 64 KiB chunks whose nonces
+// count up and mark the last chunk, so truncation is detected.
+const (
+	encMagic       = "kuro-sense-encrypted/v1"
+	stanzaPrefix   = "-> "
+	footerPrefix   = "--- "
+	fileKeySize    = 32
+	payloadChunk   = 64 << 10
+	pbkdf2Iter     = 600_000
+	maxHeaderLines = 64
+)
+
+// ErrNoKey means an encrypted archive was found but nothing given can
+// decrypt it.
+var ErrNoKey = errors.New("no key can decrypt this archive")
+
+// Encryption selects how Pack encrypts an archive. Use either a
+// passphrase or recipients, not both.
+type Encryption struct {
+	Passphrase string
+	Recipients []Recipient
+}
+
+func (e Encryption) enabled() bool {
+	return e.Passphrase != "" || len(e.Recipients) > 0
+}
+
+// Decryption is what reading an encrypted archive may use. Passphrase is
+// only called for passphrase-encrypted archives.
+type Decryption struct {
+	Identity   *Identity
+	Passphrase func() (string, error)
+}
+
+// IsEncrypted reports whether the file at path is an encrypted archive.
+func IsEncrypted(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	buf := make([]byte, len(encMagic))
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return false, nil
+	}
+	return string(buf) == encMagic, nil
+}
+
+// encryptWriter returns a writer that encrypts everything written to it
+// into w. Close flushes the last chunk; it does not close w.
+func encryptWriter(w io.Writer, enc Encryption) (io.WriteCloser, error) {
+	if enc.Passphrase != "" && len(enc.Recipients) > 0 {
+		return nil, fmt.Errorf("encrypt with a passphrase or recipients, not both")
+	}
+	fileKey := make([]byte, fileKeySize)
+	rand.Read(fileKey)
+
+	var hdr bytes.Buffer
+	hdr.WriteString(encMagic + "\n")
+	for _, r := range enc.Recipients {
+		eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := wrapX25519(eph, r.pub, fileKey)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&hdr, "%sx25519 %s %s\n", stanzaPrefix, b64.EncodeToString(eph.PublicKey().Bytes()), b64.EncodeToString(wrapped))
+	}
+	if enc.Passphrase != "" {
+		salt := make([]byte, 16)
+		rand.Read(salt)
+		kek, err := pbkdf2.Key(sha256.New, enc.Passphrase, salt, pbkdf2Iter, 32)
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := seal(kek, fileKey)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&hdr, "%spassphrase %s %d %s\n", stanzaPrefix, b64.EncodeToString(salt), pbkdf2Iter, b64.EncodeToString(wrapped))
+	}
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	fmt.Fprintf(&hdr, "%s%s", footerPrefix, b64.EncodeToString(nonce))
+	mac, err := headerMAC(fileKey, hdr.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&hdr, " %s\n", b64.EncodeToString(mac))
+	if _, err := w.Write(hdr.Bytes()); err != nil {
+		return nil, err
+	}
+
+	aead, err := payloadAEAD(fileKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &streamWriter{w: w, aead: aead}, nil
+}
+
+// decryptReader reads an encrypted archive from r.
+func decryptReader(r io.Reader, dec Decryption) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.ReadString('\n')
+	if err != nil || strings.TrimSuffix(magic, "\n") != encMagic {
+		return nil, fmt.Errorf("not an encrypted archive")
+	}
+	hdr := []byte(magic)
+
+	var stanzas [][]string
+	var footer string
+	for i := 0; ; i++ {
+		if i == maxHeaderLines {
+			return nil, fmt.Errorf("encryption header too long")
+		}
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("read encryption header: %w", err)
+		}
+		if rest, ok := strings.CutPrefix(line, footerPrefix); ok {
+			footer = strings.TrimSuffix(rest, "\n")
+			break
+		}
+		rest, ok := strings.CutPrefix(line, stanzaPrefix)
+		if !ok {
+			return nil, fmt.Errorf("malformed encryption header")
+		}
+		stanzas = append(stanzas, strings.Fields(rest))
+		hdr = append(hdr, line...)
+	}
+	nonceB64, macB64, ok := strings.Cut(footer, " ")
+	if !ok {
+		return nil, fmt.Errorf("malformed encryption header")
+	}
+	hdr = append(hdr, footerPrefix+nonceB64...)
+	nonce, err1 := b64.DecodeString(nonceB64)
+	mac, err2 := b64.DecodeString(macB64)
+	if err1 != nil || err2 != nil || len(nonce) != 16 {
+		return nil, fmt.Errorf("malformed encryption header")
+	}
+
+	fileKey, err := unwrapFileKey(stanzas, dec)
+	if err != nil {
+		return nil, err
+	}
+	want, err := headerMAC(fileKey, hdr)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, want) {
+		return nil, fmt.Errorf("encryption header has been modified")
+	}
+	aead, err := payloadAEAD(fileKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &streamReader{r: br, aead: aead}, nil
+}
+
+// unwrapFileKey tries each stanza the caller has a key for.
+func unwrapFileKey(stanzas [][]string, dec Decryption) ([]byte, error) {
+	var errs []error
+	for _, s := range stanzas {
+		switch {
+		case len(s) == 3 && s[0] == "x25519":
+			if dec.Identity == nil {
+				continue
+			}
+			ephRaw, err1 := b64.DecodeString(s[1])
+			wrapped, err2 := b64.DecodeString(s[2])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("malformed x25519 stanza")
+			}
+			eph, err := ecdh.X25519().NewPublicKey(ephRaw)
+			if err != nil {
+				return nil, fmt.Errorf("malformed x25519 stanza: %w", err)
+			}
+			if key, err := unwrapX25519(dec.Identity.x, eph, wrapped); err == nil {
+				return key, nil
+			}
+			// Encrypted to someone else; try the next stanza.
+
+		case len(s) == 4 && s[0] == "passphrase":
+			if dec.Passphrase == nil {
+				errs = append(errs, fmt.Errorf("archive is passphrase-encrypted"))
+				continue
+			}
+			salt, err1 := b64.DecodeString(s[1])
+			iter, err2 := strconv.Atoi(s[2])
+			wrapped, err3 := b64.DecodeString(s[3])
+			if err1 != nil || err2 != nil || err3 != nil || iter < 1 || iter > 100*pbkdf2Iter {
+				return nil, fmt.Errorf("malformed passphrase stanza")
+			}
+			pass, err := dec.Passphrase()
+			if err != nil {
+				return nil, err
+			}
+			kek, err := pbkdf2.Key(sha256.New, pass, salt, iter, 32)
+			if err != nil {
+				return nil, err
+			}
+			if key, err := open(kek, wrapped); err == nil {
+				return key, nil
+			}
+			return nil, fmt.Errorf("wrong passphrase")
+		}
+	}
+	return nil, errors.Join(append([]error{ErrNoKey}, errs...)...)
+}
+
+func wrapX25519(eph *ecdh.PrivateKey, to *ecdh.PublicKey, fileKey []byte) ([]byte, error) {
+	kek, err := x25519KEK(eph, to, eph.PublicKey(), to)
+	if err != nil {
+		return nil, err
+	}
+	return seal(kek, fileKey)
+}
+
+func unwrapX25519(id *ecdh.PrivateKey, eph *ecdh.PublicKey, wrapped []byte) ([]byte, error) {
+	kek, err := x25519KEK(id, eph, eph, id.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	return open(kek, wrapped)
+}
+
+// x25519KEK derives the key that wraps the file key for one recipient.
+func x25519KEK(priv *ecdh.PrivateKey, pub, eph, recipient *ecdh.PublicKey) ([]byte, error) {
+	shared, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	salt := append(eph.Bytes(), recipient.Bytes()...)
+	return hkdf.Key(sha256.New, shared, salt, "kuro-sense x25519", 32)
+}
+
+func headerMAC(fileKey, hdr []byte) ([]byte, error) {
+	key, err := hkdf.Key(sha256.New, fileKey, nil, "kuro-sense header", 32)
+	if err != nil {
+		return nil, err
+	}
+	m := hmac.New(sha256.New, key)
+	m.Write(hdr)
+	return m.Sum(nil), nil
+}
+
+func payloadAEAD(fileKey, nonce []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, fileKey, nonce, "kuro-sense payload", 32)
+	if err != nil {
+		return nil, err
+	}
+	return newGCM(key)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal and open wrap a file key under a single-use key, so a zero nonce
+// is safe.
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, make([]byte, aead.NonceSize()), plaintext, nil), nil
+}
+
+func open(key, ciphertext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext, nil)
+}
+
+// chunkNonce is an 11-byte big-endian counter followed by 1 on the last
+// chunk and 0 otherwise.
+func chunkNonce(counter uint64, last bool) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n[3:11], counter)
+	if last {
+		n[11] = 1
+	}
+	return n
+}
+
+// streamWriter encrypts in payloadChunk pieces. A full chunk is held back
+// until more data arrives, so Close always has a last chunk to mark.
+type streamWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if len(s.buf) == payloadChunk {
+			if err := s.flush(false); err != nil {
+				return 0, err
+			}
+		}
+		k := min(payloadChunk-len(s.buf), len(p))
+		s.buf = append(s.buf, p[:k]...)
+		p = p[k:]
+	}
+	return n, nil
+}
+
+func (s *streamWriter) flush(last bool) error {
+	out := s.aead.Seal(nil, chunkNonce(s.counter, last), s.buf, nil)
+	s.counter++
+	s.buf = s.buf[:0]
+	_, err := s.w.Write(out)
+	return err
+}
+
+func (s *streamWriter) Close() error {
+	return s.flush(true)
+}
+
+// streamReader decrypts what streamWriter wrote.
+type streamReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+	done    bool
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func (s *streamReader) next() error {
+	chunk := make([]byte, payloadChunk+s.aead.Overhead())
+	n, err := io.ReadFull(s.r, chunk)
+	last := false
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, perr := s.r.Peek(1); perr == io.EOF {
+			last = true
+		}
+	}
+	plain, err := s.aead.Open(nil, chunkNonce(s.counter, last), chunk[:n], nil)
+	if err != nil {
+		return fmt.Errorf("archive is corrupted or truncated")
+	}
+	s.counter++
+	s.buf = plain
+	s.done = last
+	return nil
+}
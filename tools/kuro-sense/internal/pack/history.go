@@ -0,0 +1,176 @@
+package pack
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/fsutil"
+)
+
+// StateDir is where kuro-sense keeps its own state inside an agent
+// directory. Pack never archives it.
+const StateDir = ".kuro-sense"
+
+// stagingPrefix names the temporary directories unpack stages files in.
+const stagingPrefix = ".kuro-sense-staging-"
+
+const (
+	historyFile  = "pack-history.jsonl" // one HistoryEntry per pack
+	lastPackFile = "last-pack.json"     // manifest of the latest pack
+	unpackedFile = "unpacked.json"      // the pack a destination is at
+)
+
+// HistoryEntry records one pack made from an agent directory.
+type HistoryEntry struct {
+	ID        string    `json:"id"`
+	Base      string    `json:"base,omitempty"` // set for incremental archives
+	CreatedAt time.Time `json:"createdAt"`
+	Archive   string    `json:"archive"`
+	Files     int       `json:"files"` // files in the archive
+	Unchanged int       `json:"unchanged,omitempty"`
+	Deleted   int       `json:"deleted,omitempty"`
+	Size      int64     `json:"size"` // archive size in bytes
+}
+
+// LastPackPath is the manifest of the latest pack of agentDir, usable as
+// PackOptions.Since for the next incremental archive.
+func LastPackPath(agentDir string) string {
+	return filepath.Join(agentDir, StateDir, lastPackFile)
+}
+
+// History returns the packs made from agentDir, oldest first.
+func History(agentDir string) ([]HistoryEntry, error) {
+	f, err := os.Open(filepath.Join(agentDir, StateDir, historyFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e HistoryEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", historyFile, n, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
+
+// recordPack appends the pack to agentDir's history and keeps its
+// manifest as the base for the next incremental archive.
+func recordPack(agentDir, archive string, m *PackManifest, manifestData []byte) error {
+	dir := filepath.Join(agentDir, StateDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(archive)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(HistoryEntry{
+		ID:        m.ID,
+		Base:      m.Base,
+		CreatedAt: m.CreatedAt,
+		Archive:   abs,
+		Files:     len(m.Files),
+		Unchanged: len(m.Unchanged),
+		Deleted:   len(m.Deleted),
+		Size:      info.Size(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := fsutil.WriteFileAtomic(filepath.Join(dir, lastPackFile), manifestData, 0o644); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, historyFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// unpackedRecord notes which pack a destination was last brought up to,
+// so a later incremental archive can be checked against it, and the
+// files as that pack had them: the base of the next three-way merge.
+type unpackedRecord struct {
+	ID         string         `json:"id"`
+	Archive    string         `json:"archive"`
+	UnpackedAt time.Time      `json:"unpackedAt"`
+	Files      []ManifestFile `json:"files,omitempty"`
+}
+
+// readUnpacked returns what dest was last unpacked from, or nil.
+func readUnpacked(dest string) (*unpackedRecord, error) {
+	path := filepath.Join(dest, StateDir, unpackedFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rec := &unpackedRecord{}
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, mf := range rec.Files {
+		if !validHash(mf.SHA256) {
+			return nil, fmt.Errorf("%s: %s: invalid sha256 %q", path, mf.Path, mf.SHA256)
+		}
+	}
+	return rec, nil
+}
+
+func recordUnpacked(rt *os.Root, rec unpackedRecord) error {
+	abs, err := filepath.Abs(rec.Archive)
+	if err != nil {
+		return err
+	}
+	rec.Archive = abs
+	rec.UnpackedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeRootFile(rt, filepath.Join(StateDir, unpackedFile), append(data, '\n'), 0o644)
+}
+
+// writeRootFile writes data to rel inside rt, creating its directory.
+func writeRootFile(rt *os.Root, rel string, data []byte, perm os.FileMode) error {
+	if err := mkdirAll(rt, filepath.Dir(rel)); err != nil {
+		return err
+	}
+	f, err := rt.OpenFile(rel, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,119 @@
+package pack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckChain(t *testing.T) {
+	full := func(id string) *VerifyReport { return &VerifyReport{Archive: id + ".tar.gz", ID: id} }
+	incr := func(id, base string) *VerifyReport { return &VerifyReport{Archive: id + ".tar.gz", ID: id, Base: base} }
+
+	for _, tt := range []struct {
+		name    string
+		at      string // pack dest was last unpacked from; "" = none
+		chain   []*VerifyReport
+		wantErr string
+	}{
+		{name: "full archive", chain: []*VerifyReport{full("p1")}},
+		{name: "full archive over another pack", at: "p9", chain: []*VerifyReport{full("p1")}},
+		{name: "full then increments", chain: []*VerifyReport{full("p1"), incr("p2", "p1"), incr("p3", "p2")}},
+		{name: "increment on the recorded pack", at: "p1", chain: []*VerifyReport{incr("p2", "p1")}},
+		{name: "increment with nothing unpacked", chain: []*VerifyReport{incr("p2", "p1")}, wantErr: "is at no recorded pack"},
+		{name: "increment on another pack", at: "p7", chain: []*VerifyReport{incr("p2", "p1")}, wantErr: "is at pack p7"},
+		{name: "gap", chain: []*VerifyReport{full("p1"), incr("p3", "p2")}, wantErr: "the chain has a gap"},
+		{name: "out of order", chain: []*VerifyReport{full("p1"), incr("p3", "p2"), incr("p2", "p1")}, wantErr: "the chain has a gap"},
+		{name: "second full archive", chain: []*VerifyReport{full("p1"), full("p2")}, wantErr: "only the first archive of a chain"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := t.TempDir()
+			if tt.at != "" {
+				rt, err := os.OpenRoot(dest)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = recordUnpacked(rt, unpackedRecord{ID: tt.at, Archive: tt.at + ".tar.gz"})
+				rt.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := checkChain(tt.chain, dest)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("checkChain: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("checkChain error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadUnpackedRejectsBadHashes(t *testing.T) {
+	dest := t.TempDir()
+	path := filepath.Join(dest, StateDir, unpackedFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data := `{"id":"p1","files":[{"path":"memory/a.md","size":1,"sha256":"../../../etc/passwd"}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readUnpacked(dest); err == nil || !strings.Contains(err.Error(), "invalid sha256") {
+		t.Errorf("readUnpacked error = %v, want an invalid sha256", err)
+	}
+}
+
+func TestUnpackIncrementalChain(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "dest")
+	opts := UnpackOptions{Dest: dest, HomeDir: filepath.Join(dir, "home")}
+
+	a, b := file("memory/a.md", "a1\n"), file("memory/b.md", "b1\n")
+	p1 := buildArchive(t, filepath.Join(dir, "p1.tar.gz"), manifestFor("p1", "", a, b), a, b)
+
+	a2 := file("memory/a.md", "a2\n")
+	m2 := manifestFor("p2", "p1", a2)
+	m2.Unchanged = manifestFor("", "", b).Files
+	p2 := buildArchive(t, filepath.Join(dir, "p2.tar.gz"), m2, a2)
+
+	m3 := manifestFor("p3", "p2")
+	m3.Unchanged = manifestFor("", "", a2).Files
+	m3.Deleted = []string{"memory/b.md"}
+	p3 := buildArchive(t, filepath.Join(dir, "p3.tar.gz"), m3)
+
+	if err := Unpack([]string{p2}, opts); err == nil {
+		t.Fatal("unpacked an increment onto an empty destination")
+	}
+	if err := Unpack([]string{p1}, opts); err != nil {
+		t.Fatalf("Unpack p1: %v", err)
+	}
+	if err := Unpack([]string{p3}, opts); err == nil || !strings.Contains(err.Error(), "unpack the archives before it first") {
+		t.Fatalf("Unpack p3 on p1 error = %v, want a missing-base error", err)
+	}
+	if err := Unpack([]string{p2, p3}, opts); err != nil {
+		t.Fatalf("Unpack p2 p3: %v", err)
+	}
+
+	if got := readFile(t, filepath.Join(dest, "memory", "a.md")); got != "a2\n" {
+		t.Errorf("a.md = %q, want the p2 copy", got)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "memory", "b.md")); !os.IsNotExist(err) {
+		t.Errorf("b.md survived its tombstone: %v", err)
+	}
+	rec, err := readUnpacked(dest)
+	if err != nil || rec == nil || rec.ID != "p3" {
+		t.Fatalf("readUnpacked = %+v, %v; want p3", rec, err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
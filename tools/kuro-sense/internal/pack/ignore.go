@@ -0,0 +1,167 @@
+package pack
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// pattern is one gitignore-style pattern.
+type pattern struct {
+	text    string
+	re      *regexp.Regexp
+	negate  bool   // "!pat" re-includes what an earlier pattern excluded
+	dirOnly bool   // "pat/" matches directories only
+	prefix  string // leading literal directories of an anchored pattern
+}
+
+// compilePattern parses a pattern with gitignore semantics:
+//
+//	*.log          any file or directory named *.log, at any depth
+//	/build         build at the top level only
+//	memory/cache/  a directory (anything with a slash is anchored)
+//	**/cache       cache at any depth; a/**/b matches a/b, a/x/b, ...
+//	memory/**      everything inside memory
+//	!keep.log      re-include a path an earlier pattern excluded
+//
+// It returns false for blank lines and comments.
+func compilePattern(line string) (pattern, bool, error) {
+	text := strings.TrimRight(line, " \t\r")
+	if text == "" || strings.HasPrefix(text, "#") {
+		return pattern{}, false, nil
+	}
+	p := pattern{text: text}
+	if strings.HasPrefix(text, `\#`) || strings.HasPrefix(text, `\!`) {
+		text = text[1:]
+	} else if rest, ok := strings.CutPrefix(text, "!"); ok {
+		p.negate = true
+		text = rest
+	}
+	if rest, ok := strings.CutSuffix(text, "/"); ok {
+		p.dirOnly = true
+		text = rest
+	}
+	// A slash anywhere but the end anchors the pattern to the root.
+	anchored := strings.Contains(text, "/")
+	text = strings.TrimPrefix(text, "/")
+	if text == "" {
+		return pattern{}, false, fmt.Errorf("pattern %q matches nothing", line)
+	}
+	if anchored {
+		var lit []string
+		for _, seg := range strings.Split(text, "/") {
+			if strings.ContainsAny(seg, `*?[\`) {
+				break
+			}
+			lit = append(lit, seg)
+		}
+		p.prefix = strings.Join(lit, "/")
+	}
+
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case strings.HasPrefix(text[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(text[i:], "/**") && i+3 == len(text):
+			re.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(text[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(text[i+1:], ']')
+			if end < 0 {
+				return pattern{}, false, fmt.Errorf("pattern %q: unclosed [", line)
+			}
+			class := text[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(text):
+			i++
+			re.WriteString(regexp.QuoteMeta(string(text[i])))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	var err error
+	if p.re, err = regexp.Compile(re.String()); err != nil {
+		return pattern{}, false, fmt.Errorf("pattern %q: %w", line, err)
+	}
+	return p, true, nil
+}
+
+func (p pattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return p.re.MatchString(rel)
+}
+
+// matcher is an ordered list of patterns; the last one that matches a
+// path decides it.
+type matcher []pattern
+
+func compilePatterns(lines []string) (matcher, error) {
+	var m matcher
+	for _, line := range lines {
+		p, ok, err := compilePattern(line)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			m = append(m, p)
+		}
+	}
+	return m, nil
+}
+
+// match reports whether rel (slash-separated, relative to the root) is
+// matched. As in git, a path inside a matched directory is matched too,
+// so callers walking a tree should check directories and skip them.
+func (m matcher) match(rel string, isDir bool) bool {
+	_, matched := m.matchBy(rel, isDir)
+	return matched
+}
+
+// matchBy is match that also returns the text of the deciding pattern.
+func (m matcher) matchBy(rel string, isDir bool) (string, bool) {
+	by, matched := "", false
+	for _, p := range m {
+		if p.match(rel, isDir) {
+			by, matched = p.text, !p.negate
+		}
+	}
+	return by, matched
+}
+
+// matchPath is match for a path whose parent directories have not been
+// checked: it is matched if it or any parent directory is.
+func (m matcher) matchPath(rel string, isDir bool) bool {
+	_, matched := m.matchPathBy(rel, isDir)
+	return matched
+}
+
+func (m matcher) matchPathBy(rel string, isDir bool) (string, bool) {
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if by, ok := m.matchBy(strings.Join(parts[:i], "/"), true); ok {
+			return by, true
+		}
+	}
+	return m.matchBy(rel, isDir)
+}
@@ -0,0 +1,239 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/fsutil"
+)
+
+// Key string prefixes. Public keys are shared as "x25519:<base64>"
+// (encryption recipient) and "ed25519:<base64>" (signer).
+const (
+	recipientPrefix = "x25519:"
+	signerPrefix    = "ed25519:"
+	x25519Secret    = "x25519-secret:"
+	ed25519Secret   = "ed25519-secret:"
+)
+
+var b64 = base64.RawStdEncoding
+
+// Identity is a key pair for receiving encrypted archives and signing
+// archives.
+type Identity struct {
+	x    *ecdh.PrivateKey
+	sign ed25519.PrivateKey
+}
+
+// GenerateIdentity creates a new identity.
+func GenerateIdentity() (*Identity, error) {
+	x, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	_, sign, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{x: x, sign: sign}, nil
+}
+
+// Recipient is the public key others encrypt archives to.
+func (id *Identity) Recipient() string {
+	return recipientPrefix + b64.EncodeToString(id.x.PublicKey().Bytes())
+}
+
+// Signer is the public key that checks this identity's signatures.
+func (id *Identity) Signer() string {
+	return formatSigner(id.sign.Public().(ed25519.PublicKey))
+}
+
+func formatSigner(k ed25519.PublicKey) string {
+	return signerPrefix + b64.EncodeToString(k)
+}
+
+// DefaultIdentityPath is where the identity lives unless
+// $KURO_SENSE_IDENTITY says otherwise.
+func DefaultIdentityPath() string {
+	if p := os.Getenv("KURO_SENSE_IDENTITY"); p != "" {
+		return p
+	}
+	return filepath.Join(configDir(), "identity")
+}
+
+// DefaultTrustedKeysPath is the trusted signers file unless
+// $KURO_SENSE_TRUSTED_KEYS says otherwise.
+func DefaultTrustedKeysPath() string {
+	if p := os.Getenv("KURO_SENSE_TRUSTED_KEYS"); p != "" {
+		return p
+	}
+	return filepath.Join(configDir(), "trusted_keys")
+}
+
+func configDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".kuro-sense"
+	}
+	return filepath.Join(dir, "kuro-sense")
+}
+
+// Save writes the identity to path with mode 0600. An existing identity
+// is never overwritten.
+func (id *Identity) Save(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# kuro-sense identity, created %s\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&buf, "# recipient: %s\n", id.Recipient())
+	fmt.Fprintf(&buf, "# signer: %s\n", id.Signer())
+	fmt.Fprintf(&buf, "%s%s\n", x25519Secret, b64.EncodeToString(id.x.Bytes()))
+	fmt.Fprintf(&buf, "%s%s\n", ed25519Secret, b64.EncodeToString(id.sign.Seed()))
+	if err := fsutil.WriteFileAtomic(path, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0o600)
+}
+
+// LoadIdentity reads an identity written by Save.
+func LoadIdentity(path string) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	id := &Identity{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, x25519Secret):
+			raw, err := b64.DecodeString(strings.TrimPrefix(line, x25519Secret))
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad x25519 key", path)
+			}
+			if id.x, err = ecdh.X25519().NewPrivateKey(raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		case strings.HasPrefix(line, ed25519Secret):
+			seed, err := b64.DecodeString(strings.TrimPrefix(line, ed25519Secret))
+			if err != nil || len(seed) != ed25519.SeedSize {
+				return nil, fmt.Errorf("%s: bad ed25519 key", path)
+			}
+			id.sign = ed25519.NewKeyFromSeed(seed)
+		}
+	}
+	if id.x == nil || id.sign == nil {
+		return nil, fmt.Errorf("%s: not a kuro-sense identity", path)
+	}
+	return id, nil
+}
+
+// Recipient is a public key an archive can be encrypted to.
+type Recipient struct {
+	pub *ecdh.PublicKey
+}
+
+// ParseRecipient parses an "x25519:<base64>" public key.
+func ParseRecipient(s string) (Recipient, error) {
+	raw, ok := strings.CutPrefix(strings.TrimSpace(s), recipientPrefix)
+	if !ok {
+		return Recipient{}, fmt.Errorf("recipient %q: want %s<key>", s, recipientPrefix)
+	}
+	b, err := b64.DecodeString(raw)
+	if err != nil {
+		return Recipient{}, fmt.Errorf("recipient %q: %w", s, err)
+	}
+	pub, err := ecdh.X25519().NewPublicKey(b)
+	if err != nil {
+		return Recipient{}, fmt.Errorf("recipient %q: %w", s, err)
+	}
+	return Recipient{pub: pub}, nil
+}
+
+// ParseSigner parses an "ed25519:<base64>" public key.
+func ParseSigner(s string) (ed25519.PublicKey, error) {
+	raw, ok := strings.CutPrefix(strings.TrimSpace(s), signerPrefix)
+	if !ok {
+		return nil, fmt.Errorf("key %q: want %s<key>", s, signerPrefix)
+	}
+	b, err := b64.DecodeString(raw)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("key %q: not an ed25519 public key", s)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// TrustedKey is a signer whose archives verify accepts.
+type TrustedKey struct {
+	Key     ed25519.PublicKey
+	Comment string
+}
+
+// LoadTrustedKeys reads a trusted keys file: one "ed25519:<base64>
+// [comment]" per line, # comments allowed. A missing file has no keys.
+func LoadTrustedKeys(path string) ([]TrustedKey, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []TrustedKey
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		field, comment, _ := strings.Cut(line, " ")
+		k, err := ParseSigner(field)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		keys = append(keys, TrustedKey{Key: k, Comment: strings.TrimSpace(comment)})
+	}
+	return keys, nil
+}
+
+// AddTrustedKey appends key to the trusted keys file unless it is
+// already there.
+func AddTrustedKey(path string, key ed25519.PublicKey, comment string) (bool, error) {
+	keys, err := LoadTrustedKeys(path)
+	if err != nil {
+		return false, err
+	}
+	for _, k := range keys {
+		if k.Key.Equal(key) {
+			return false, nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	line := formatSigner(key)
+	if comment != "" {
+		line += " " + comment
+	}
+	data = append(data, line+"\n"...)
+	return true, fsutil.WriteFileAtomic(path, data, 0o644)
+}
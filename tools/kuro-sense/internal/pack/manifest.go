@@ -1,18 +1,127 @@
 package pack
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"time"
 )
 
+// FormatVersion is the archive format Pack writes. Version 3 adds pack
+// IDs and incremental archives; version 2 archives start with
+// manifest.json; version 1 archives (no version field) end with it.
+const FormatVersion = 3
+
+// manifestName is the archive entry holding the PackManifest.
+const manifestName = "manifest.json"
+
 // PackManifest describes a packed archive.
 type PackManifest struct {
+	Version   int            `json:"version"`
 	CreatedAt time.Time      `json:"createdAt"`
 	AgentDir  string         `json:"agentDir"`
 	Files     []ManifestFile `json:"files"`
+
+	// ID identifies this pack so later incremental archives can name it
+	// as their base.
+	ID string `json:"id,omitempty"`
+	// An incremental archive holds only the files that changed since the
+	// pack Base. Unchanged lists the files it carries over from Base and
+	// Deleted those removed since (tombstones).
+	Base      string         `json:"base,omitempty"`
+	Unchanged []ManifestFile `json:"unchanged,omitempty"`
+	Deleted   []string       `json:"deleted,omitempty"`
+}
+
+// Incremental reports whether m only holds changes since another pack.
+func (m *PackManifest) Incremental() bool {
+	return m.Base != ""
+}
+
+// State is every file of the agent data as of this pack: the archived
+// files plus, for an incremental archive, those carried over from its
+// base.
+func (m *PackManifest) State() map[string]ManifestFile {
+	state := make(map[string]ManifestFile, len(m.Files)+len(m.Unchanged))
+	for _, list := range [][]ManifestFile{m.Unchanged, m.Files} {
+		for _, mf := range list {
+			state[mf.Path] = mf
+		}
+	}
+	return state
+}
+
+// validHash reports whether s is a SHA-256 as manifests write it: 64
+// lowercase hex digits. Hashes name base copies on disk, so nothing else
+// may be used as one.
+func validHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// newPackID returns a random pack ID.
+func newPackID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ReadManifest reads the manifest of a pack archive (decrypting it with
+// dec if needed) or a manifest.json file on its own.
+func ReadManifest(path string, dec Decryption) (*PackManifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, 1)
+	_, err = io.ReadFull(f, head)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if head[0] == '{' {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		m := &PackManifest{}
+		if err := json.Unmarshal(data, m); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		return m, nil
+	}
+
+	tr, closeArchive, err := openArchive(path, dec)
+	if err != nil {
+		return nil, err
+	}
+	defer closeArchive()
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s has no manifest", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar: %w", err)
+		}
+		if hdr.Name == manifestName {
+			m, _, err := readManifest(tr, hdr)
+			return m, err
+		}
+	}
 }
 
 // ManifestFile is one file entry in the manifest.
@@ -0,0 +1,553 @@
+package pack
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/textdiff"
+)
+
+// Class says which side changed a file since the destination was last
+// unpacked (the base).
+type Class string
+
+const (
+	Unchanged   Class = "unchanged"    // the same on both sides
+	OnlyLocal   Class = "only-local"   // changed here, not in the archive
+	OnlyArchive Class = "only-archive" // changed in the archive, not here
+	BothChanged Class = "both-changed"
+)
+
+// Action is what Unpack does with a file.
+type Action string
+
+const (
+	ActNone     Action = "none"     // nothing to do
+	ActKeep     Action = "keep"     // the local copy stays
+	ActWrite    Action = "write"    // the archive copy replaces it
+	ActMerge    Action = "merge"    // a clean three-way merge replaces it
+	ActConflict Action = "conflict" // the local copy stays; see ConflictFile
+	ActDelete   Action = "delete"   // the archive deleted it
+)
+
+// conflictSuffix is appended to a path for its conflict file.
+const conflictSuffix = ".conflict"
+
+// mergeLimit is the largest file Unpack tries to merge line by line.
+const mergeLimit = 1 << 20
+
+// objectsDir keeps a copy of each text file as last unpacked, by SHA-256,
+// so the next unpack has the base of a three-way merge.
+const objectsDir = "objects"
+
+// FileChange is the plan for one file of the archive chain.
+type FileChange struct {
+	Path         string `json:"path"`
+	Class        Class  `json:"class"`
+	Action       Action `json:"action"`
+	Note         string `json:"note,omitempty"`
+	ConflictFile string `json:"conflictFile,omitempty"`
+
+	source  int    // archive in the chain holding the new copy; -1 = none
+	content []byte // merged text to write instead of the archive copy
+	remote  []byte // the archive copy, when it was read for a merge
+}
+
+// UnpackPlan is everything Unpack would do, worked out before anything
+// is written.
+type UnpackPlan struct {
+	Archives []string     `json:"archives"`
+	Dest     string       `json:"dest"`
+	Base     string       `json:"base,omitempty"` // pack dest was last unpacked from
+	Changes  []FileChange `json:"changes"`
+
+	reports []*VerifyReport
+	byPath  map[string]int
+	state   map[string]ManifestFile // the files as of the last archive
+	base    map[string]ManifestFile // the files as last unpacked
+	home    string
+
+	// unverified holds files whose archive copy is missing or does not
+	// match the manifest; their manifest entry never becomes the base.
+	unverified map[string]bool
+
+	// writeAll is set for --force with archives that have no manifest to
+	// classify files by: every file is written.
+	writeAll bool
+}
+
+// Count is the number of files the plan handles with action a.
+func (p *UnpackPlan) Count(a Action) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == a {
+			n++
+		}
+	}
+	return n
+}
+
+// change returns the plan for an entry of archive idx, or nil when the
+// entry is only read.
+func (p *UnpackPlan) change(name string, idx int) *FileChange {
+	if p.writeAll {
+		return &FileChange{Path: name, Class: OnlyArchive, Action: ActWrite, source: idx}
+	}
+	i, ok := p.byPath[name]
+	if !ok || p.Changes[i].source != idx {
+		return nil
+	}
+	return &p.Changes[i]
+}
+
+// PlanUnpack checks a chain of archives (see Unpack) and classifies every
+// file in it by comparing the SHA-256 of the archive copy, the local copy,
+// and the copy dest was last unpacked with. Text files changed on both
+// sides are merged line by line in memory; nothing is written.
+func PlanUnpack(archives []string, opts UnpackOptions) (*UnpackPlan, error) {
+	reports, err := verifyChain(archives, opts)
+	if err != nil {
+		return nil, err
+	}
+	home := opts.HomeDir
+	if home == "" {
+		if home, err = os.UserHomeDir(); err != nil {
+			return nil, fmt.Errorf("home directory: %w", err)
+		}
+	}
+	rec, err := readUnpacked(opts.Dest)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &UnpackPlan{
+		Archives:   archives,
+		Dest:       opts.Dest,
+		reports:    reports,
+		byPath:     make(map[string]int),
+		base:       make(map[string]ManifestFile),
+		home:       home,
+		unverified: make(map[string]bool),
+	}
+	if rec != nil {
+		p.Base = rec.ID
+		for _, mf := range rec.Files {
+			p.base[mf.Path] = mf
+		}
+	}
+
+	// The chain ends at the state of its last archive; each file comes
+	// from the last archive that holds it.
+	last := reports[len(reports)-1]
+	for _, r := range reports {
+		if r.manifest != nil {
+			continue
+		}
+		if !opts.Force {
+			return nil, fmt.Errorf("%s has no manifest to compare files with; use --force to restore it as is", r.Archive)
+		}
+		p.writeAll = true
+		return p, nil
+	}
+	p.state = last.manifest.State()
+	source := make(map[string]int)
+	deleted := make(map[string]bool)
+	for i, r := range reports {
+		for _, mf := range r.manifest.Files {
+			source[mf.Path] = i
+		}
+		for _, d := range r.manifest.Deleted {
+			deleted[d] = true
+		}
+	}
+	// Only the copy in the archive a file comes from counts; a later
+	// archive may replace a bad copy in an earlier one.
+	missing := make(map[string]bool)
+	for i, r := range reports {
+		for _, path := range r.Missing {
+			if source[path] == i {
+				missing[path], p.unverified[path] = true, true
+			}
+		}
+		for _, c := range r.Corrupted {
+			if source[c.Path] == i {
+				p.unverified[c.Path] = true
+			}
+		}
+	}
+	var paths []string
+	for path := range p.state {
+		paths = append(paths, path)
+	}
+	for path := range deleted {
+		if _, ok := p.state[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	local, err := openReadRoots(opts.Dest, home)
+	if err != nil {
+		return nil, err
+	}
+	defer local.close()
+
+	merges := make(map[int][]int) // archive -> changes to merge from it
+	for _, path := range paths {
+		e, reason := checkPath(path)
+		if reason != "" {
+			return nil, fmt.Errorf("%s: %s", path, reason)
+		}
+		src, ok := source[path]
+		if !ok {
+			src = -1
+		}
+		c := FileChange{Path: path, source: src}
+		l, isDir, err := local.hash(e)
+		if err != nil {
+			return nil, err
+		}
+		r, b := p.state[path].SHA256, p.base[path].SHA256
+		c.Class, c.Action = classify(l, r, b)
+		switch {
+		case isDir:
+			c.Class, c.Action, c.Note = BothChanged, ActKeep, "a directory is in the way"
+		case opts.Force && (c.Class == BothChanged || c.Class == OnlyLocal):
+			c.Action, c.Note = ActWrite, "--force"
+			if r == "" {
+				c.Action = ActDelete
+			}
+		case c.Class == BothChanged && r == "":
+			c.Action, c.Note = ActKeep, "deleted in the archive, changed here"
+		case c.Class == BothChanged && l == "":
+			c.Action, c.Note = ActConflict, "deleted here, changed in the archive"
+		case c.Class == BothChanged:
+			c.Action, c.Note = ActConflict, mergeObstacle(p.base[path], p.state[path], local, e, opts.Dest)
+			if c.Note == "" {
+				c.Action = ActMerge
+			}
+		}
+		takesArchiveCopy := c.Action == ActWrite || c.Action == ActConflict || c.Action == ActMerge
+		switch {
+		case takesArchiveCopy && src < 0:
+			// The base is older than the chain: the archive copy is in
+			// an archive before it.
+			c.Action, c.Note = ActKeep, "the archive chain does not hold this file"
+		case takesArchiveCopy && missing[path]:
+			c.Action, c.Note = ActKeep, "missing from the archive"
+		case takesArchiveCopy && p.unverified[path]:
+			// The local copy stays; the archive copy is kept aside for
+			// review rather than trusted.
+			c.Action, c.Note = ActConflict, "archive copy does not match the manifest"
+		}
+		if c.Action == ActMerge {
+			merges[src] = append(merges[src], len(p.Changes))
+		}
+		if c.Action == ActConflict {
+			c.ConflictFile = path + conflictSuffix
+		}
+		p.byPath[path] = len(p.Changes)
+		p.Changes = append(p.Changes, c)
+	}
+
+	for idx, changes := range merges {
+		if err := p.merge(idx, changes, opts, local); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// classify compares the local, archive and base SHA-256 of a file; ""
+// means the file is absent on that side.
+func classify(l, r, b string) (Class, Action) {
+	switch {
+	case l == r:
+		return Unchanged, ActNone
+	case l == b && r == "":
+		return OnlyArchive, ActDelete
+	case l == b:
+		return OnlyArchive, ActWrite
+	case r == b:
+		return OnlyLocal, ActKeep
+	default:
+		return BothChanged, ActConflict
+	}
+}
+
+// mergeObstacle says why a file changed on both sides cannot be merged
+// line by line, or "" if it can be tried.
+func mergeObstacle(base, remote ManifestFile, local *readRoots, e entry, dest string) string {
+	if base.SHA256 == "" {
+		return "no base to merge against"
+	}
+	if base.Size > mergeLimit || remote.Size > mergeLimit {
+		return "too large to merge"
+	}
+	if _, err := os.Stat(objectPath(dest, base.SHA256)); err != nil {
+		return "no text copy of the base to merge against"
+	}
+	if info, err := local.stat(e); err != nil || info.Size() > mergeLimit {
+		return "too large to merge"
+	}
+	return ""
+}
+
+// merge reads the archive copies of changes from archive idx and merges
+// each with the local copy and the base.
+func (p *UnpackPlan) merge(idx int, changes []int, opts UnpackOptions, local *readRoots) error {
+	want := make(map[string]*FileChange, len(changes))
+	for _, i := range changes {
+		want[p.Changes[i].Path] = &p.Changes[i]
+	}
+	r := p.reports[idx]
+	err := eachEntry(r.Archive, opts.Decryption, opts.Limits.orDefault(), func(hdr *tar.Header, e entry, rd io.Reader) error {
+		c := want[hdr.Name]
+		if c == nil || e.dir {
+			return nil
+		}
+		data, err := io.ReadAll(io.LimitReader(rd, mergeLimit+1))
+		if err != nil {
+			return fmt.Errorf("read %s: %w", hdr.Name, err)
+		}
+		if got := hashBytes(data); got != p.state[c.Path].SHA256 {
+			return fmt.Errorf("%s: does not match the manifest", hdr.Name)
+		}
+		c.remote = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range want {
+		if c.remote == nil {
+			return fmt.Errorf("%s: missing from %s", c.Path, r.Archive)
+		}
+		e, _ := checkPath(c.Path)
+		mine, err := local.read(e)
+		if err != nil {
+			return err
+		}
+		base, err := os.ReadFile(objectPath(p.Dest, p.base[c.Path].SHA256))
+		if err != nil {
+			return err
+		}
+		if !isText(mine) || !isText(c.remote) || !isText(base) {
+			c.Action, c.Note, c.ConflictFile = ActConflict, "binary", c.Path+conflictSuffix
+			continue
+		}
+		merged, conflicts := textdiff.Merge3(base, mine, c.remote, "local", "archive")
+		if conflicts > 0 {
+			c.Action, c.ConflictFile = ActConflict, c.Path+conflictSuffix
+			c.Note = fmt.Sprintf("%d conflicting hunk(s)", conflicts)
+		}
+		c.content = merged
+	}
+	return nil
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func isText(data []byte) bool {
+	return !bytes.ContainsRune(data, 0) && utf8.Valid(data)
+}
+
+func objectPath(dest, hash string) string {
+	return filepath.Join(dest, StateDir, objectsDir, hash[:2], hash)
+}
+
+// readRoots reads local files through os.Root, like roots writes them,
+// without creating anything.
+type readRoots struct {
+	dest, home *os.Root // nil when the directory does not exist
+}
+
+func openReadRoots(dest, home string) (*readRoots, error) {
+	r := &readRoots{}
+	var err error
+	if r.dest, err = openExisting(dest); err != nil {
+		return nil, err
+	}
+	if r.home, err = openExisting(home); err != nil {
+		r.close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func openExisting(dir string) (*os.Root, error) {
+	rt, err := os.OpenRoot(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return rt, err
+}
+
+func (r *readRoots) close() {
+	if r.dest != nil {
+		r.dest.Close()
+	}
+	if r.home != nil {
+		r.home.Close()
+	}
+}
+
+func (r *readRoots) root(e entry) *os.Root {
+	if e.home {
+		return r.home
+	}
+	return r.dest
+}
+
+func (r *readRoots) stat(e entry) (fs.FileInfo, error) {
+	rt := r.root(e)
+	if rt == nil {
+		return nil, fs.ErrNotExist
+	}
+	return rt.Stat(e.rel)
+}
+
+// hash returns the SHA-256 of the local copy of e, "" if there is none,
+// and whether a directory is there instead.
+func (r *readRoots) hash(e entry) (string, bool, error) {
+	info, err := r.stat(e)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if info.IsDir() {
+		return "", true, nil
+	}
+	f, err := r.root(e).Open(e.rel)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+	h, err := hashReader(f)
+	return h, false, err
+}
+
+func (r *readRoots) read(e entry) ([]byte, error) {
+	rt := r.root(e)
+	if rt == nil {
+		return nil, fs.ErrNotExist
+	}
+	f, err := rt.Open(e.rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// saveBase records the state the chain ended at as the base for the next
+// unpack, keeping a copy of each text file and dropping copies no longer
+// needed.
+func (p *UnpackPlan) saveBase(rts *roots) error {
+	rt, err := rts.get(false)
+	if err != nil {
+		return err
+	}
+	local, err := openReadRoots(p.Dest, p.home)
+	if err != nil {
+		return err
+	}
+	defer local.close()
+
+	keep := make(map[string]bool)
+	files := make([]ManifestFile, 0, len(p.state))
+	for path, mf := range p.state {
+		if p.unverified[path] {
+			// What was written is not what the manifest describes, so
+			// the previous base (if any) is still the best known one.
+			if prev, ok := p.base[path]; ok {
+				files = append(files, prev)
+				keep[prev.SHA256] = true
+			}
+			continue
+		}
+		files = append(files, mf)
+		if mf.Size > mergeLimit {
+			continue
+		}
+		rel := filepath.Join(StateDir, objectsDir, mf.SHA256[:2], mf.SHA256)
+		if _, err := rt.Stat(rel); err == nil {
+			keep[mf.SHA256] = true
+			continue
+		}
+		// The archive copy: read for a merge, or now on disk.
+		var data []byte
+		if i, ok := p.byPath[path]; ok && p.Changes[i].remote != nil {
+			data = p.Changes[i].remote
+		} else {
+			e, _ := checkPath(path)
+			if data, err = local.read(e); err != nil || hashBytes(data) != mf.SHA256 {
+				continue
+			}
+		}
+		if !isText(data) {
+			continue
+		}
+		if err := writeRootFile(rt, rel, data, 0o644); err != nil {
+			return fmt.Errorf("save base copy of %s: %w", path, err)
+		}
+		keep[mf.SHA256] = true
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	last := p.reports[len(p.reports)-1]
+	if err := recordUnpacked(rt, unpackedRecord{ID: last.ID, Archive: last.Archive, Files: files}); err != nil {
+		return err
+	}
+	return pruneObjects(filepath.Join(p.Dest, StateDir, objectsDir), keep)
+}
+
+// pruneObjects removes base copies not in keep.
+func pruneObjects(dir string, keep map[string]bool) error {
+	fans, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, fan := range fans {
+		if !fan.IsDir() {
+			continue
+		}
+		sub := filepath.Join(dir, fan.Name())
+		objs, err := os.ReadDir(sub)
+		if err != nil {
+			return err
+		}
+		left := len(objs)
+		for _, o := range objs {
+			if !keep[o.Name()] {
+				if err := os.Remove(filepath.Join(sub, o.Name())); err != nil {
+					return err
+				}
+				left--
+			}
+		}
+		if left == 0 {
+			os.Remove(sub)
+		}
+	}
+	return nil
+}
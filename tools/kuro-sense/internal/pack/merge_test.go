@@ -0,0 +1,178 @@
+package pack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	for _, tt := range []struct {
+		l, r, b string
+		class   Class
+		action  Action
+	}{
+		{"x", "x", "x", Unchanged, ActNone},
+		{"x", "x", "", Unchanged, ActNone},
+		{"", "", "x", Unchanged, ActNone},
+		{"x", "y", "x", OnlyArchive, ActWrite},
+		{"", "y", "", OnlyArchive, ActWrite},
+		{"x", "", "x", OnlyArchive, ActDelete},
+		{"y", "x", "x", OnlyLocal, ActKeep},
+		{"", "x", "x", OnlyLocal, ActKeep},
+		{"y", "z", "x", BothChanged, ActConflict},
+		{"y", "z", "", BothChanged, ActConflict},
+		{"y", "", "x", BothChanged, ActConflict},
+	} {
+		class, action := classify(tt.l, tt.r, tt.b)
+		if class != tt.class || action != tt.action {
+			t.Errorf("classify(%q, %q, %q) = %s, %s; want %s, %s", tt.l, tt.r, tt.b, class, action, tt.class, tt.action)
+		}
+	}
+}
+
+func TestUnpackMerges(t *testing.T) {
+	const base = "one\ntwo\nthree\n"
+	for _, tt := range []struct {
+		name     string
+		local    string
+		archive  string
+		action   Action
+		want     string // the file after unpacking
+		conflict string // the .conflict file; "" = none
+	}{
+		{
+			name:    "clean merge",
+			local:   "ONE\ntwo\nthree\n",
+			archive: "one\ntwo\nTHREE\n",
+			action:  ActMerge,
+			want:    "ONE\ntwo\nTHREE\n",
+		},
+		{
+			name:     "conflicting edits",
+			local:    "ONE\ntwo\nthree\n",
+			archive:  "1\ntwo\nthree\n",
+			action:   ActConflict,
+			want:     "ONE\ntwo\nthree\n",
+			conflict: "<<<<<<< local\nONE\n||||||| base\none\n=======\n1\n>>>>>>> archive\ntwo\nthree\n",
+		},
+		{
+			name:    "only the archive changed",
+			local:   base,
+			archive: "one\n2\nthree\n",
+			action:  ActWrite,
+			want:    "one\n2\nthree\n",
+		},
+		{
+			name:    "only the local copy changed",
+			local:   "one\n2\nthree\n",
+			archive: base,
+			action:  ActKeep,
+			want:    "one\n2\nthree\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			dest := filepath.Join(dir, "dest")
+			opts := UnpackOptions{Dest: dest, HomeDir: filepath.Join(dir, "home")}
+			path := filepath.Join(dest, "memory", "notes.md")
+
+			a := file("memory/notes.md", base)
+			p1 := buildArchive(t, filepath.Join(dir, "p1.tar.gz"), manifestFor("p1", "", a), a)
+			if err := Unpack([]string{p1}, opts); err != nil {
+				t.Fatalf("Unpack p1: %v", err)
+			}
+			writeFile(t, path, tt.local)
+
+			a2 := file("memory/notes.md", tt.archive)
+			p2 := buildArchive(t, filepath.Join(dir, "p2.tar.gz"), manifestFor("p2", "", a2), a2)
+			plan, err := PlanUnpack([]string{p2}, opts)
+			if err != nil {
+				t.Fatalf("PlanUnpack: %v", err)
+			}
+			if got := plan.Changes[plan.byPath["memory/notes.md"]].Action; got != tt.action {
+				t.Errorf("action = %s, want %s", got, tt.action)
+			}
+			if err := Unpack([]string{p2}, opts); err != nil {
+				t.Fatalf("Unpack p2: %v", err)
+			}
+			if got := readFile(t, path); got != tt.want {
+				t.Errorf("notes.md = %q, want %q", got, tt.want)
+			}
+			if tt.conflict == "" {
+				return
+			}
+			if got := readFile(t, path+conflictSuffix); got != tt.conflict {
+				t.Errorf("notes.md.conflict:\n%s\nwant:\n%s", got, tt.conflict)
+			}
+		})
+	}
+}
+
+func TestUnpackCorruptedCopyIsNotTheBase(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "dest")
+	opts := UnpackOptions{Dest: dest, HomeDir: filepath.Join(dir, "home")}
+	path := filepath.Join(dest, "memory", "notes.md")
+
+	a := file("memory/notes.md", "one\n")
+	p1 := buildArchive(t, filepath.Join(dir, "p1.tar.gz"), manifestFor("p1", "", a), a)
+	if err := Unpack([]string{p1}, opts); err != nil {
+		t.Fatalf("Unpack p1: %v", err)
+	}
+
+	m2 := manifestFor("p2", "", file("memory/notes.md", "two\n"))
+	bad := file("memory/notes.md", "tampered\n")
+	p2 := buildArchive(t, filepath.Join(dir, "p2.tar.gz"), m2, bad)
+
+	plan, err := PlanUnpack([]string{p2}, opts)
+	if err != nil {
+		t.Fatalf("PlanUnpack: %v", err)
+	}
+	c := plan.Changes[plan.byPath["memory/notes.md"]]
+	if c.Action != ActConflict || !strings.Contains(c.Note, "does not match the manifest") {
+		t.Errorf("change = %s (%s), want a conflict for the bad copy", c.Action, c.Note)
+	}
+
+	if err := Unpack([]string{p2}, opts); err != nil {
+		t.Fatalf("Unpack p2: %v", err)
+	}
+	if got := readFile(t, path); got != "one\n" {
+		t.Errorf("notes.md = %q, want the local copy kept", got)
+	}
+	if got := readFile(t, path+conflictSuffix); got != "tampered\n" {
+		t.Errorf("notes.md.conflict = %q, want the archive copy", got)
+	}
+	rec, err := readUnpacked(dest)
+	if err != nil || rec == nil {
+		t.Fatalf("readUnpacked = %+v, %v", rec, err)
+	}
+	var baseHash string
+	for _, f := range rec.Files {
+		if f.Path == "memory/notes.md" {
+			baseHash = f.SHA256
+		}
+	}
+	if baseHash != hashBytes([]byte("one\n")) {
+		t.Errorf("base for notes.md = %q, want the p1 copy", baseHash)
+	}
+
+	// With p1 still the base, the next good archive merges as usual.
+	writeFile(t, path, "one\nlocal\n")
+	good := file("memory/notes.md", "archive\none\n")
+	p3 := buildArchive(t, filepath.Join(dir, "p3.tar.gz"), manifestFor("p3", "", good), good)
+	if err := Unpack([]string{p3}, opts); err != nil {
+		t.Fatalf("Unpack p3: %v", err)
+	}
+	if got := readFile(t, path); got != "archive\none\nlocal\n" {
+		t.Errorf("notes.md = %q, want the merged copy", got)
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
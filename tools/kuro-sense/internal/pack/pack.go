@@ -3,179 +3,211 @@ package pack
 import (
 	"archive/tar"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
-	"strings"
+	"sort"
 	"time"
 )
 
-// Directories to include in pack (relative to agentDir).
-var includeDirs = []string{
-	"memory",
-	"plugins",
-	"skills",
-	"scripts",
+// PackOptions configures Pack.
+type PackOptions struct {
+	Output  string     // archive path; "" = kuro-sense-pack-YYYY-MM-DD.tar.gz (.enc when encrypted)
+	Encrypt Encryption // zero = plain tar.gz
+	Sign    *Identity  // signs the manifest when set
+	Config  Config     // what to archive; zero = the default includes
+	HomeDir string     // instance data comes from HomeDir/.mini-agent; "" = the user's home
+
+	// Since makes an incremental archive holding only the files whose
+	// SHA-256 differs from this earlier pack, plus tombstones for the
+	// files removed since.
+	Since *PackManifest
 }
 
-// Files to include in pack (relative to agentDir).
-var includeFiles = []string{
-	"agent-compose.yaml",
-}
-
-// Patterns to exclude.
-var excludePatterns = []string{
-	"node_modules",
-	"dist",
-	".env",
-	"*.log",
-	"memory-index.db",
-	"server.log",
-}
-
-// Pack creates a tar.gz archive of agent data.
-func Pack(agentDir, output string) (string, error) {
+// Pack creates a tar.gz archive of agent data and records it in the
+// agent directory's pack history.
+func Pack(agentDir string, opts PackOptions) (string, error) {
+	output := opts.Output
 	if output == "" {
 		output = fmt.Sprintf("kuro-sense-pack-%s.tar.gz", time.Now().Format("2006-01-02"))
+		if opts.Encrypt.enabled() {
+			output += ".enc"
+		}
 	}
 
-	f, err := os.Create(output)
-	if err != nil {
-		return "", fmt.Errorf("create archive: %w", err)
-	}
-	defer f.Close()
-
-	gw := gzip.NewWriter(f)
-	defer gw.Close()
-	tw := tar.NewWriter(gw)
-	defer tw.Close()
-
 	manifest := PackManifest{
+		Version:   FormatVersion,
 		CreatedAt: time.Now().UTC(),
 		AgentDir:  agentDir,
 	}
+	if opts.Since != nil && opts.Since.ID == "" {
+		return "", fmt.Errorf("the base pack has no pack ID (made by an older kuro-sense); make a full pack first")
+	}
+	id, err := newPackID()
+	if err != nil {
+		return "", err
+	}
+	manifest.ID = id
+	var base map[string]ManifestFile
+	if opts.Since != nil {
+		manifest.Base = opts.Since.ID
+		base = opts.Since.State()
+	}
 
-	// Add directories
-	for _, dir := range includeDirs {
-		fullDir := filepath.Join(agentDir, dir)
-		if _, err := os.Stat(fullDir); os.IsNotExist(err) {
-			continue
+	plan, err := Plan(agentDir, opts.HomeDir, opts.Config)
+	if err != nil {
+		return "", err
+	}
+	var files []PlanFile
+	current := make(map[string]bool, len(plan.Files))
+
+	// Hash everything up front: the manifest is the first entry, so
+	// readers can check each file as it streams past.
+	for _, pf := range plan.Files {
+		path := pf.diskPath()
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", fmt.Errorf("add %s: %w", pf.Path, err)
 		}
-		if err := addDir(tw, agentDir, dir, &manifest); err != nil {
-			return "", fmt.Errorf("add %s: %w", dir, err)
+		hash, err := hashFile(path)
+		if err != nil {
+			return "", fmt.Errorf("add %s: %w", pf.Path, err)
 		}
-	}
-
-	// Add individual files
-	for _, file := range includeFiles {
-		fullPath := filepath.Join(agentDir, file)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		mf := ManifestFile{
+			Path:   pf.Path,
+			Size:   info.Size(),
+			SHA256: hash,
+		}
+		current[pf.Path] = true
+		if old, ok := base[pf.Path]; ok && old.Size == mf.Size && old.SHA256 == mf.SHA256 {
+			manifest.Unchanged = append(manifest.Unchanged, mf)
 			continue
 		}
-		if err := addFile(tw, agentDir, file, &manifest); err != nil {
-			return "", fmt.Errorf("add %s: %w", file, err)
+		files = append(files, pf)
+		manifest.Files = append(manifest.Files, mf)
+	}
+	for p := range base {
+		if !current[p] {
+			manifest.Deleted = append(manifest.Deleted, p)
 		}
 	}
+	sort.Strings(manifest.Deleted)
 
-	// Add instance data from ~/.mini-agent/
-	homeDir, _ := os.UserHomeDir()
-	instanceDir := filepath.Join(homeDir, ".mini-agent")
-	if _, err := os.Stat(instanceDir); err == nil {
-		if err := addDir(tw, homeDir, ".mini-agent", &manifest); err != nil {
-			// Non-fatal: instance data is optional
-			fmt.Fprintf(os.Stderr, "warning: could not add instance data: %v\n", err)
+	manifestData, _ := json.MarshalIndent(manifest, "", "  ")
+	var sigData []byte
+	if opts.Sign != nil {
+		if sigData, err = signManifest(opts.Sign, manifestData); err != nil {
+			return "", fmt.Errorf("sign manifest: %w", err)
 		}
 	}
 
-	// Write manifest as last entry
-	manifestData, _ := json.MarshalIndent(manifest, "", "  ")
-	hdr := &tar.Header{
-		Name:    "manifest.json",
-		Mode:    0644,
-		Size:    int64(len(manifestData)),
-		ModTime: time.Now(),
+	f, err := os.Create(output)
+	if err != nil {
+		return "", fmt.Errorf("create archive: %w", err)
 	}
-	if err := tw.WriteHeader(hdr); err != nil {
-		return "", err
+	err = writeArchive(f, opts.Encrypt, manifestData, sigData, files, manifest.Files)
+	if cerr := f.Close(); err == nil {
+		err = cerr
 	}
-	if _, err := tw.Write(manifestData); err != nil {
+	if err != nil {
+		os.Remove(output)
 		return "", err
 	}
-
+	if err := recordPack(agentDir, output, &manifest, manifestData); err != nil {
+		// Non-fatal: the archive is complete without it.
+		fmt.Fprintf(os.Stderr, "warning: could not record pack history: %v\n", err)
+	}
 	return output, nil
 }
 
-func addDir(tw *tar.Writer, baseDir, relDir string, manifest *PackManifest) error {
-	fullDir := filepath.Join(baseDir, relDir)
-	return filepath.Walk(fullDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// writeArchive writes the manifest, its signature if any, and files to w,
+// through encryption when enc is enabled.
+func writeArchive(w io.Writer, enc Encryption, manifestData, sigData []byte, files []PlanFile, mfs []ManifestFile) error {
+	var ew io.WriteCloser
+	if enc.enabled() {
+		var err error
+		if ew, err = encryptWriter(w, enc); err != nil {
+			return fmt.Errorf("encrypt: %w", err)
 		}
-		if shouldExclude(path) {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
+		w = ew
+	}
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	// Write manifest as first entry, then its signature
+	if err := writeEntry(tw, manifestName, manifestData); err != nil {
+		return err
+	}
+	if sigData != nil {
+		if err := writeEntry(tw, signatureName, sigData); err != nil {
+			return err
 		}
-		if info.IsDir() {
-			return nil
+	}
+	for i, pf := range files {
+		if err := addFile(tw, pf, mfs[i]); err != nil {
+			return fmt.Errorf("add %s: %w", pf.Path, err)
 		}
+	}
 
-		rel, _ := filepath.Rel(baseDir, path)
-		return addFile(tw, baseDir, rel, manifest)
-	})
-}
-
-func addFile(tw *tar.Writer, baseDir, relPath string, manifest *PackManifest) error {
-	fullPath := filepath.Join(baseDir, relPath)
-	info, err := os.Stat(fullPath)
-	if err != nil {
+	if err := tw.Close(); err != nil {
 		return err
 	}
-
-	hdr, err := tar.FileInfoHeader(info, "")
-	if err != nil {
+	if err := gw.Close(); err != nil {
 		return err
 	}
-	hdr.Name = relPath
+	if ew != nil {
+		return ew.Close()
+	}
+	return nil
+}
 
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
 	if err := tw.WriteHeader(hdr); err != nil {
 		return err
 	}
+	_, err := tw.Write(data)
+	return err
+}
 
-	f, err := os.Open(fullPath)
+// addFile writes pf to the archive, failing if it no longer matches its
+// manifest entry mf.
+func addFile(tw *tar.Writer, pf PlanFile, mf ManifestFile) error {
+	f, err := os.Open(pf.diskPath())
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-
-	if _, err := io.Copy(tw, f); err != nil {
+	info, err := f.Stat()
+	if err != nil {
 		return err
 	}
 
-	// Add to manifest
-	hash, _ := hashFile(fullPath)
-	manifest.Files = append(manifest.Files, ManifestFile{
-		Path:   relPath,
-		Size:   info.Size(),
-		SHA256: hash,
-	})
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = mf.Path
+	hdr.Size = mf.Size
 
-	return nil
-}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
 
-func shouldExclude(path string) bool {
-	base := filepath.Base(path)
-	for _, pattern := range excludePatterns {
-		if strings.Contains(pattern, "*") {
-			if matched, _ := filepath.Match(pattern, base); matched {
-				return true
-			}
-		} else if base == pattern {
-			return true
-		}
+	h := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(tw, h), f, mf.Size); err != nil {
+		return fmt.Errorf("changed while packing: %w", err)
 	}
-	return false
+	if hex.EncodeToString(h.Sum(nil)) != mf.SHA256 {
+		return fmt.Errorf("changed while packing")
+	}
+	return nil
 }
@@ -0,0 +1,190 @@
+package pack
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PackPlan is what Pack would archive under a Config.
+type PackPlan struct {
+	Files   []PlanFile `json:"files"`
+	Skipped []Skipped  `json:"skipped,omitempty"`
+}
+
+// PlanFile is a file Pack would archive.
+type PlanFile struct {
+	Path string `json:"path"` // archive path, slash-separated
+	Size int64  `json:"size"`
+
+	base string // directory Path is relative to on disk
+}
+
+// Skipped is a path inside an included directory that Pack leaves out.
+type Skipped struct {
+	Path   string `json:"path"` // directories end in "/"
+	Reason string `json:"reason"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// Part is the files of a plan under one top-level directory or file.
+type Part struct {
+	Name  string `json:"name"`
+	Files int    `json:"files"`
+	Size  int64  `json:"size"`
+}
+
+// Size is the total size of the planned files.
+func (p *PackPlan) Size() int64 {
+	var n int64
+	for _, f := range p.Files {
+		n += f.Size
+	}
+	return n
+}
+
+// Parts groups the planned files by their first path element.
+func (p *PackPlan) Parts() []Part {
+	var parts []Part
+	index := make(map[string]int)
+	for _, f := range p.Files {
+		name, _, _ := strings.Cut(f.Path, "/")
+		i, ok := index[name]
+		if !ok {
+			i = len(parts)
+			index[name] = i
+			parts = append(parts, Part{Name: name})
+		}
+		parts[i].Files++
+		parts[i].Size += f.Size
+	}
+	return parts
+}
+
+// Plan lists what Pack archives from agentDir under cfg, and what it
+// skips inside the included directories and why. Instance data comes
+// from homeDir/.mini-agent ("" = the user's home directory).
+func Plan(agentDir, homeDir string, cfg Config) (*PackPlan, error) {
+	c, err := cfg.compile()
+	if err != nil {
+		return nil, err
+	}
+	p := &PackPlan{}
+	seen := make(map[string]bool)
+
+	roots := append([]string(nil), c.roots...)
+	sort.Strings(roots)
+	for i, root := range roots {
+		// Skip roots inside one already walked.
+		if i > 0 && within(root, roots[:i]) {
+			continue
+		}
+		if err := p.walk(c, agentDir, root, true, seen); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Instance {
+		if homeDir == "" {
+			if homeDir, err = os.UserHomeDir(); err != nil {
+				return nil, fmt.Errorf("instance data: %w", err)
+			}
+		}
+		if err := p.walk(c, homeDir, instancePrefix, false, seen); err != nil {
+			return nil, fmt.Errorf("instance data: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// within reports whether dir is one of roots or inside one.
+func within(dir string, roots []string) bool {
+	for _, r := range roots {
+		if r == "" || dir == r || strings.HasPrefix(dir, r+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// walk adds the files under base/root. Files must match the include
+// patterns when include is set; excluded directories are not entered.
+func (p *PackPlan) walk(c *compiled, base, root string, include bool, seen map[string]bool) error {
+	start := filepath.Join(base, filepath.FromSlash(root))
+	if _, err := os.Lstat(start); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(start, func(full string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, full)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if seen[rel] {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		seen[rel] = true
+
+		// The walk checks each directory on the way down, except those
+		// above the root.
+		by, excluded := c.exclude.matchBy(rel, d.IsDir())
+		if full == start {
+			by, excluded = c.exclude.matchPathBy(rel, d.IsDir())
+		}
+		if d.IsDir() {
+			if excluded {
+				p.skip(rel+"/", "excluded by "+by, 0)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if include && !c.include.matchPath(rel, false) {
+			return nil
+		}
+		if excluded {
+			p.skip(rel, "excluded by "+by, 0)
+			return nil
+		}
+
+		info, err := os.Stat(full)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			reason := "not a regular file"
+			if info.IsDir() {
+				reason = "symlink to a directory"
+			}
+			p.skip(rel, reason, 0)
+			return nil
+		}
+		if limit, by := c.limitFor(rel); limit >= 0 && info.Size() > limit {
+			p.skip(rel, fmt.Sprintf("%d bytes, over the %d byte limit for %s", info.Size(), limit, by), info.Size())
+			return nil
+		}
+		p.Files = append(p.Files, PlanFile{Path: rel, Size: info.Size(), base: base})
+		return nil
+	})
+}
+
+func (p *PackPlan) skip(rel, reason string, size int64) {
+	p.Skipped = append(p.Skipped, Skipped{Path: rel, Reason: reason, Size: size})
+}
+
+// diskPath is where f is on disk.
+func (f PlanFile) diskPath() string {
+	return filepath.Join(f.base, filepath.FromSlash(path.Clean(f.Path)))
+}
@@ -0,0 +1,52 @@
+package pack
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+)
+
+// signatureName is the archive entry holding the manifest signature. It
+// comes right after manifest.json.
+const signatureName = "manifest.sig"
+
+// signatureContext separates manifest signatures from anything else the
+// same key might sign.
+const signatureContext = "kuro-sense manifest signature v1\n"
+
+// manifestSignature is the content of manifest.sig: an Ed25519 signature
+// over the exact bytes of manifest.json. The manifest holds the SHA-256
+// of every file, so the signature covers the whole archive.
+type manifestSignature struct {
+	Key       string `json:"key"`       // ed25519:<base64>
+	Signature string `json:"signature"` // base64
+}
+
+func signManifest(id *Identity, manifest []byte) ([]byte, error) {
+	sig := ed25519.Sign(id.sign, append([]byte(signatureContext), manifest...))
+	return json.MarshalIndent(manifestSignature{
+		Key:       id.Signer(),
+		Signature: b64.EncodeToString(sig),
+	}, "", "  ")
+}
+
+// checkSignature verifies data (manifest.sig) over manifest and returns
+// the signer's key.
+func checkSignature(data, manifest []byte) (ed25519.PublicKey, error) {
+	var ms manifestSignature
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return nil, fmt.Errorf("parse signature: %w", err)
+	}
+	key, err := ParseSigner(ms.Key)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := b64.DecodeString(ms.Signature)
+	if err != nil {
+		return key, fmt.Errorf("signature is not base64")
+	}
+	if !ed25519.Verify(key, append([]byte(signatureContext), manifest...), sig) {
+		return key, fmt.Errorf("signature by %s does not match the manifest", formatSigner(key))
+	}
+	return key, nil
+}
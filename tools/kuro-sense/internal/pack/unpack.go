@@ -3,95 +3,719 @@ package pack
 import (
 	"archive/tar"
 	"compress/gzip"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
-// Unpack extracts a kuro-sense archive to the destination directory.
-func Unpack(archive, dest string, force bool) error {
+// instancePrefix marks archive entries that belong in the home directory
+// (see Pack) rather than the agent directory.
+const instancePrefix = ".mini-agent"
+
+// maxManifestSize bounds how much of manifest.json is read.
+const maxManifestSize = 16 << 20
+
+// Limits bound what Unpack extracts, so a small archive cannot fill the
+// disk. Zero fields take the DefaultLimits value.
+type Limits struct {
+	MaxFileSize  int64 // largest single file
+	MaxTotalSize int64 // all files together
+	MaxEntries   int
+}
+
+// DefaultLimits are far above what an agent directory holds.
+var DefaultLimits = Limits{
+	MaxFileSize:  512 << 20,
+	MaxTotalSize: 4 << 30,
+	MaxEntries:   100_000,
+}
+
+func (l Limits) orDefault() Limits {
+	if l.MaxFileSize <= 0 {
+		l.MaxFileSize = DefaultLimits.MaxFileSize
+	}
+	if l.MaxTotalSize <= 0 {
+		l.MaxTotalSize = DefaultLimits.MaxTotalSize
+	}
+	if l.MaxEntries <= 0 {
+		l.MaxEntries = DefaultLimits.MaxEntries
+	}
+	return l
+}
+
+// UnpackOptions configures Unpack.
+type UnpackOptions struct {
+	Dest    string // agent directory to restore into
+	HomeDir string // where .mini-agent instance data goes; "" = the user's home directory
+	Force   bool   // archive copies replace local changes instead of merging
+	Strict  bool   // stage everything and move it into place only if the whole archive verifies
+	VerifyOptions
+}
+
+// Rejection is an archive entry Unpack refused to extract.
+type Rejection struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// RejectedError lists every entry that made Unpack refuse an archive.
+// Nothing is extracted from a rejected archive.
+type RejectedError struct {
+	Archive string
+	Entries []Rejection
+}
+
+func (e *RejectedError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "refusing to unpack %s: %d unsafe entries", e.Archive, len(e.Entries))
+	for _, r := range e.Entries {
+		fmt.Fprintf(&b, "\n  %s: %s", r.Name, r.Reason)
+	}
+	return b.String()
+}
+
+// entry is a checked archive entry: rel is a local path under the
+// agent directory, or under the home directory when home is set.
+type entry struct {
+	rel  string
+	home bool
+	dir  bool
+	mode os.FileMode
+}
+
+// checkPath decides where an archive path belongs, or why it must not
+// be touched.
+func checkPath(name string) (entry, string) {
+	if name == "" {
+		return entry{}, "empty name"
+	}
+	if path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return entry{}, "absolute path"
+	}
+	rel := path.Clean(strings.TrimSuffix(name, "/"))
+	if !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return entry{}, "path escapes the destination"
+	}
+	// kuro-sense's own state (the merge base, unpack records, compose
+	// backups) is never taken from an archive.
+	top, _, _ := strings.Cut(rel, "/")
+	if top == StateDir || strings.HasPrefix(top, stagingPrefix) {
+		return entry{}, "kuro-sense state directory"
+	}
+
+	e := entry{rel: filepath.FromSlash(rel)}
+	if rel == instancePrefix || strings.HasPrefix(rel, instancePrefix+"/") {
+		e.home = true
+	}
+	return e, ""
+}
+
+// checkEntry decides where hdr extracts to, or why it must not.
+func checkEntry(hdr *tar.Header) (entry, string) {
+	e, reason := checkPath(hdr.Name)
+	if reason != "" {
+		return entry{}, reason
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		e.dir = true
+		e.mode = 0o755
+	case tar.TypeReg:
+		// Archive modes are not trusted: only the executable bit is kept.
+		e.mode = 0o644
+		if hdr.Mode&0o111 != 0 {
+			e.mode = 0o755
+		}
+	case tar.TypeSymlink:
+		return entry{}, fmt.Sprintf("symlink to %q is not allowed", hdr.Linkname)
+	case tar.TypeLink:
+		return entry{}, fmt.Sprintf("hard link to %q is not allowed", hdr.Linkname)
+	case tar.TypeChar, tar.TypeBlock:
+		return entry{}, "device files are not allowed"
+	case tar.TypeFifo:
+		return entry{}, "named pipes are not allowed"
+	default:
+		return entry{}, fmt.Sprintf("unsupported entry type %q", hdr.Typeflag)
+	}
+	return e, ""
+}
+
+// openArchive returns a tar reader over the archive at path, decrypting
+// it first if it is encrypted.
+func openArchive(archive string, dec Decryption) (*tar.Reader, func(), error) {
+	encrypted, err := IsEncrypted(archive)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open archive: %w", err)
+	}
 	f, err := os.Open(archive)
 	if err != nil {
-		return fmt.Errorf("open archive: %w", err)
+		return nil, nil, fmt.Errorf("open archive: %w", err)
 	}
-	defer f.Close()
-
-	gr, err := gzip.NewReader(f)
+	var r io.Reader = f
+	if encrypted {
+		if r, err = decryptReader(f, dec); err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("decrypt %s: %w", archive, err)
+		}
+	}
+	gr, err := gzip.NewReader(r)
 	if err != nil {
-		return fmt.Errorf("gzip reader: %w", err)
+		f.Close()
+		return nil, nil, fmt.Errorf("gzip reader: %w", err)
 	}
-	defer gr.Close()
+	return tar.NewReader(gr), func() { gr.Close(); f.Close() }, nil
+}
 
-	tr := tar.NewReader(gr)
-	var manifest *PackManifest
+// roots opens the extraction roots lazily. Every write goes through an
+// os.Root, so a symlink already on disk cannot redirect it outside.
+type roots struct {
+	dest, home     string
+	destRt, homeRt *os.Root
+}
 
-	for {
-		hdr, err := tr.Next()
-		if err == io.EOF {
-			break
+func (r *roots) get(home bool) (*os.Root, error) {
+	if home {
+		if r.homeRt == nil {
+			rt, err := openRoot(r.home)
+			if err != nil {
+				return nil, err
+			}
+			r.homeRt = rt
 		}
+		return r.homeRt, nil
+	}
+	if r.destRt == nil {
+		rt, err := openRoot(r.dest)
 		if err != nil {
-			return fmt.Errorf("read tar: %w", err)
+			return nil, err
+		}
+		r.destRt = rt
+	}
+	return r.destRt, nil
+}
+
+func (r *roots) close() {
+	if r.destRt != nil {
+		r.destRt.Close()
+	}
+	if r.homeRt != nil {
+		r.homeRt.Close()
+	}
+}
+
+func openRoot(dir string) (*os.Root, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create %s: %w", dir, err)
+	}
+	rt, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", dir, err)
+	}
+	return rt, nil
+}
+
+// mkdirAll creates dir and its parents inside rt.
+func mkdirAll(rt *os.Root, dir string) error {
+	if dir == "." || dir == "" {
+		return nil
+	}
+	if err := mkdirAll(rt, filepath.Dir(dir)); err != nil {
+		return err
+	}
+	if err := rt.Mkdir(dir, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+// Unpack extracts a chain of kuro-sense archives in order: agent files
+// into opts.Dest and .mini-agent instance data into the home directory.
+// Every archive is checked first; an archive with any entry that is
+// absolute, escapes its destination, is a link or device, or passes
+// opts.Limits is refused with a RejectedError listing every such entry,
+// and nothing is written.
+//
+// The chain must be contiguous: each incremental archive must build on
+// the archive before it, and the first one on the pack opts.Dest was last
+// brought up to.
+//
+// Files are handled as PlanUnpack classifies them: archive copies replace
+// files changed only in the archive, files changed only locally stay,
+// and text files changed on both sides are merged line by line against
+// the copy from the last unpack. When that is not possible the local copy
+// stays and the archive's version goes to a .conflict file next to it.
+// With opts.Force the archive copy always wins.
+//
+// Without opts.Strict, an archive copy that does not match the manifest
+// is still extracted, to a .conflict file beside the local copy, and
+// reported. With it, each archive must verify completely
+// (see Verify); files are staged and only moved into place once every one
+// has matched its manifest entry.
+func Unpack(archives []string, opts UnpackOptions) error {
+	plan, err := PlanUnpack(archives, opts)
+	if err != nil {
+		return err
+	}
+	return plan.apply(opts)
+}
+
+// verifyChain verifies each archive and checks that they form a chain.
+func verifyChain(archives []string, opts UnpackOptions) ([]*VerifyReport, error) {
+	if len(archives) == 0 {
+		return nil, fmt.Errorf("no archive to unpack")
+	}
+	reports := make([]*VerifyReport, len(archives))
+	for i, archive := range archives {
+		report, err := Verify(archive, opts.VerifyOptions)
+		if err != nil {
+			return nil, err
 		}
+		if len(report.Rejected) > 0 {
+			return nil, &RejectedError{Archive: archive, Entries: report.Rejected}
+		}
+		// A signature vouches for the manifest, so a signed archive must
+		// match it exactly.
+		strict := opts.Strict || report.Signer != ""
+		if report.SignatureProblem != "" || (strict && !report.OK()) {
+			return nil, &VerifyError{Report: report}
+		}
+		reports[i] = report
+	}
+	if err := checkChain(reports, opts.Dest); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
 
-		// Read manifest but don't extract it
-		if hdr.Name == "manifest.json" {
-			data, err := io.ReadAll(tr)
+// checkChain makes sure each incremental archive builds on the archive
+// before it, and the first one on the pack dest is at.
+func checkChain(reports []*VerifyReport, dest string) error {
+	for i, r := range reports {
+		if i == 0 {
+			if r.Base == "" {
+				continue
+			}
+			rec, err := readUnpacked(dest)
 			if err != nil {
-				return fmt.Errorf("read manifest: %w", err)
+				return err
+			}
+			if rec == nil || rec.ID != r.Base {
+				at := "no recorded pack"
+				if rec != nil {
+					at = "pack " + shortID(rec.ID)
+				}
+				return fmt.Errorf("%s is incremental on pack %s, but %s is at %s; unpack the archives before it first",
+					r.Archive, shortID(r.Base), dest, at)
 			}
-			manifest = &PackManifest{}
-			json.Unmarshal(data, manifest)
 			continue
 		}
+		prev := reports[i-1]
+		if r.Base == "" {
+			return fmt.Errorf("%s is a full archive; only the first archive of a chain can be", r.Archive)
+		}
+		if r.Base != prev.ID {
+			return fmt.Errorf("%s is incremental on pack %s, not on %s (pack %s): the chain has a gap",
+				r.Archive, shortID(r.Base), prev.Archive, shortID(prev.ID))
+		}
+	}
+	return nil
+}
 
-		target := filepath.Join(dest, hdr.Name)
+func shortID(id string) string {
+	if id == "" {
+		return "(none)"
+	}
+	return id[:min(len(id), 12)]
+}
 
-		// Security: prevent path traversal
-		if !filepath.IsAbs(target) {
-			target = filepath.Join(dest, filepath.Clean(hdr.Name))
+// apply carries out the plan: archive copies from each archive of the
+// chain in turn, then merges, conflict files and deletions, and finally
+// the new base.
+func (p *UnpackPlan) apply(opts UnpackOptions) error {
+	limits := opts.Limits.orDefault()
+	rts := &roots{dest: opts.Dest, home: p.home}
+	defer rts.close()
+
+	for i, report := range p.reports {
+		if len(p.reports) > 1 {
+			fmt.Printf("%s\n", report.Archive)
 		}
+		if err := p.applyArchive(i, opts, rts, limits); err != nil {
+			if len(p.reports) > 1 {
+				return fmt.Errorf("%s: %w", report.Archive, err)
+			}
+			return err
+		}
+	}
+	if p.writeAll {
+		return nil
+	}
 
-		switch hdr.Typeflag {
-		case tar.TypeDir:
-			os.MkdirAll(target, 0755)
-		case tar.TypeReg:
-			// Check if target is newer (skip unless --force)
-			if !force {
-				if existing, err := os.Stat(target); err == nil {
-					if existing.ModTime().After(hdr.ModTime) {
-						continue // skip: existing file is newer
-					}
-				}
+	for i := range p.Changes {
+		c := &p.Changes[i]
+		if err := c.finish(rts); err != nil {
+			return err
+		}
+	}
+	for _, a := range []struct {
+		action Action
+		label  string
+	}{
+		{ActWrite, "Written"},
+		{ActMerge, "Merged"},
+		{ActKeep, "Kept local"},
+		{ActDelete, "Deleted"},
+		{ActConflict, "Conflicts"},
+	} {
+		if n := p.Count(a.action); n > 0 {
+			fmt.Printf("  %s: %d file(s)\n", a.label, n)
+		}
+	}
+	if n := p.Count(ActConflict); n > 0 {
+		fmt.Printf("  Review the %s files next to the local copies\n", conflictSuffix)
+	}
+	if err := p.saveBase(rts); err != nil {
+		return fmt.Errorf("record unpacked pack: %w", err)
+	}
+	return nil
+}
+
+// applyArchive writes the archive copies the plan takes from archive idx.
+func (p *UnpackPlan) applyArchive(idx int, opts UnpackOptions, rts *roots, limits Limits) error {
+	report := p.reports[idx]
+	if opts.Strict || report.Signer != "" {
+		return p.unpackStaged(idx, opts.Decryption, rts, limits)
+	}
+	if err := p.extract(idx, opts.Decryption, rts, limits); err != nil {
+		return err
+	}
+	if report.manifest != nil {
+		fmt.Printf("  Verified: %d/%d files\n", report.Verified, len(report.manifest.Files))
+	}
+	for _, c := range report.Corrupted {
+		fmt.Printf("  warning: %s does not match the manifest\n", c.Path)
+	}
+	for _, p := range report.Problems {
+		fmt.Printf("  warning: %s\n", p)
+	}
+	return nil
+}
+
+// target is where the archive copy of an entry goes under the plan, if
+// anywhere: the file itself, or its conflict file.
+func (p *UnpackPlan) target(hdr *tar.Header, e entry, idx int) (entry, bool) {
+	c := p.change(hdr.Name, idx)
+	if c == nil {
+		return e, false
+	}
+	switch {
+	case c.Action == ActWrite:
+		return e, true
+	case c.Action == ActConflict && c.content == nil:
+		e.rel += conflictSuffix
+		return e, true
+	}
+	return e, false
+}
+
+// finish does what is left of c once the archive copies are in place.
+func (c *FileChange) finish(rts *roots) error {
+	e, reason := checkPath(c.Path)
+	if reason != "" {
+		return fmt.Errorf("%s: %s", c.Path, reason)
+	}
+	rt, err := rts.get(e.home)
+	if err != nil {
+		return err
+	}
+	switch {
+	case c.Action == ActDelete:
+		if info, err := rt.Lstat(e.rel); err == nil && info.IsDir() {
+			return fmt.Errorf("delete %s: is a directory", c.Path)
+		}
+		if err := rt.Remove(e.rel); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("delete %s: %w", c.Path, err)
+		}
+	case c.Action == ActMerge:
+		// Keep the local file's mode.
+		mode := os.FileMode(0o644)
+		if info, err := rt.Stat(e.rel); err == nil && info.Mode()&0o111 != 0 {
+			mode = 0o755
+		}
+		if err := writeRootFile(rt, e.rel, c.content, mode); err != nil {
+			return fmt.Errorf("merge %s: %w", c.Path, err)
+		}
+	case c.Action == ActConflict && c.content != nil:
+		if err := writeRootFile(rt, e.rel+conflictSuffix, c.content, 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", c.ConflictFile, err)
+		}
+	}
+	return nil
+}
+
+// eachEntry reads archive again and calls fn for every entry except the
+// manifest, re-checking each one in case the file changed since Verify.
+func eachEntry(archive string, dec Decryption, limits Limits, fn func(hdr *tar.Header, e entry, r io.Reader) error) error {
+	tr, closeArchive, err := openArchive(archive, dec)
+	if err != nil {
+		return err
+	}
+	defer closeArchive()
+
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read tar: %w", err)
+		}
+		if hdr.Name == manifestName || hdr.Name == signatureName {
+			continue
+		}
+		e, reason := checkEntry(hdr)
+		if reason != "" {
+			return fmt.Errorf("%s: %s", hdr.Name, reason)
+		}
+		if !e.dir {
+			total += hdr.Size
+			if hdr.Size > limits.MaxFileSize || total > limits.MaxTotalSize {
+				return fmt.Errorf("%s: size limit exceeded", hdr.Name)
 			}
+		}
+		if err := fn(hdr, e, tr); err != nil {
+			return err
+		}
+	}
+}
 
-			os.MkdirAll(filepath.Dir(target), 0755)
-			out, err := os.Create(target)
-			if err != nil {
+// extract writes the archive copies the plan takes from archive idx
+// straight to their destinations.
+func (p *UnpackPlan) extract(idx int, dec Decryption, rts *roots, limits Limits) error {
+	return eachEntry(p.reports[idx].Archive, dec, limits, func(hdr *tar.Header, e entry, r io.Reader) error {
+		rt, err := rts.get(e.home)
+		if err != nil {
+			return err
+		}
+		if e.dir {
+			if err := mkdirAll(rt, e.rel); err != nil {
 				return fmt.Errorf("create %s: %w", hdr.Name, err)
 			}
-			if _, err := io.Copy(out, tr); err != nil {
-				out.Close()
-				return fmt.Errorf("extract %s: %w", hdr.Name, err)
-			}
-			out.Close()
-			os.Chmod(target, hdr.FileInfo().Mode())
+			return nil
+		}
+		dst, ok := p.target(hdr, e, idx)
+		if !ok {
+			return nil
 		}
+		if _, err := extractFile(rt, dst, r, hdr.Size); err != nil {
+			return fmt.Errorf("extract %s: %w", hdr.Name, err)
+		}
+		return nil
+	})
+}
+
+// staged is a verified file waiting in a staging directory.
+type staged struct {
+	e    entry
+	path string // absolute path of the staged copy
+}
+
+// unpackStaged extracts the archive copies the plan takes from archive
+// idx into staging directories next to the destinations, checking every
+// file against the manifest as it is read, and moves them into place
+// only when all of them match.
+func (p *UnpackPlan) unpackStaged(idx int, dec Decryption, rts *roots, limits Limits) error {
+	archive, manifest := p.reports[idx].Archive, p.reports[idx].manifest
+	want := make(map[string]ManifestFile, len(manifest.Files))
+	for _, mf := range manifest.Files {
+		want[mf.Path] = mf
 	}
 
-	// Verify hashes if manifest present
-	if manifest != nil {
-		verified := 0
-		for _, mf := range manifest.Files {
-			target := filepath.Join(dest, mf.Path)
-			if hash, err := hashFile(target); err == nil && hash == mf.SHA256 {
-				verified++
+	stages := map[bool]*stage{}
+	defer func() {
+		for _, st := range stages {
+			st.remove()
+		}
+	}()
+	stageFor := func(home bool) (*stage, error) {
+		if st := stages[home]; st != nil {
+			return st, nil
+		}
+		dir := rts.dest
+		if home {
+			dir = rts.home
+		}
+		st, err := newStage(dir)
+		if err != nil {
+			return nil, err
+		}
+		stages[home] = st
+		return st, nil
+	}
+
+	var files []staged
+	var dirs []entry
+	seen := make(map[string]bool)
+	err := eachEntry(archive, dec, limits, func(hdr *tar.Header, e entry, r io.Reader) error {
+		if e.dir {
+			dirs = append(dirs, e)
+			return nil
+		}
+		mf, ok := want[hdr.Name]
+		if !ok {
+			return fmt.Errorf("%s: not in the manifest", hdr.Name)
+		}
+		seen[hdr.Name] = true
+
+		dst, write := p.target(hdr, e, idx)
+		if !write {
+			// Not written, but still read so its hash is checked.
+			if got, err := hashReader(r); err != nil || hdr.Size != mf.Size || got != mf.SHA256 {
+				return fmt.Errorf("%s: does not match the manifest", hdr.Name)
 			}
+			return nil
+		}
+		rt, err := rts.get(dst.home)
+		if err != nil {
+			return err
+		}
+		if info, err := rt.Lstat(dst.rel); err == nil && info.IsDir() {
+			return fmt.Errorf("%s: a directory is in the way", hdr.Name)
+		}
+		st, err := stageFor(dst.home)
+		if err != nil {
+			return err
+		}
+		got, err := extractFile(st.rt, dst, r, hdr.Size)
+		if err != nil {
+			return fmt.Errorf("stage %s: %w", hdr.Name, err)
+		}
+		if hdr.Size != mf.Size || got != mf.SHA256 {
+			return fmt.Errorf("%s: does not match the manifest", hdr.Name)
+		}
+		files = append(files, staged{e: dst, path: filepath.Join(st.dir, dst.rel)})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("nothing unpacked: %w", err)
+	}
+	for _, mf := range manifest.Files {
+		if !seen[mf.Path] {
+			return fmt.Errorf("nothing unpacked: %s is missing from the archive", mf.Path)
 		}
-		fmt.Printf("  Verified: %d/%d files\n", verified, len(manifest.Files))
 	}
 
+	for _, e := range dirs {
+		rt, err := rts.get(e.home)
+		if err != nil {
+			return err
+		}
+		if err := mkdirAll(rt, e.rel); err != nil {
+			return fmt.Errorf("create %s: %w", e.rel, err)
+		}
+	}
+	for _, f := range files {
+		dir := rts.dest
+		if f.e.home {
+			dir = rts.home
+		}
+		rt, err := rts.get(f.e.home)
+		if err != nil {
+			return err
+		}
+		if err := moveInto(dir, rt, f.e.rel, f.path); err != nil {
+			return fmt.Errorf("move %s into place: %w", f.e.rel, err)
+		}
+	}
+	fmt.Printf("  Verified: %d/%d files\n", len(seen), len(manifest.Files))
 	return nil
 }
+
+// stage is a temporary directory inside a destination.
+type stage struct {
+	dir string
+	rt  *os.Root
+}
+
+func newStage(dest string) (*stage, error) {
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return nil, fmt.Errorf("create %s: %w", dest, err)
+	}
+	dir, err := os.MkdirTemp(dest, stagingPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("create staging directory: %w", err)
+	}
+	rt, err := os.OpenRoot(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &stage{dir: dir, rt: rt}, nil
+}
+
+func (s *stage) remove() {
+	s.rt.Close()
+	os.RemoveAll(s.dir)
+}
+
+// moveInto renames staged to rel inside dir. The parent directory is
+// created through rt and resolved before the rename, so a symlink on disk
+// cannot send the file outside dir.
+func moveInto(dir string, rt *os.Root, rel, staged string) error {
+	parent := filepath.Dir(rel)
+	if err := mkdirAll(rt, parent); err != nil {
+		return err
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	realParent, err := filepath.EvalSymlinks(filepath.Join(dir, parent))
+	if err != nil {
+		return err
+	}
+	if r, err := filepath.Rel(realDir, realParent); err != nil || !filepath.IsLocal(r) {
+		return fmt.Errorf("%s resolves outside %s", parent, dir)
+	}
+	return os.Rename(staged, filepath.Join(realParent, filepath.Base(rel)))
+}
+
+// extractFile writes size bytes from r to e.rel inside rt with e.mode and
+// returns their SHA-256.
+func extractFile(rt *os.Root, e entry, r io.Reader, size int64) (string, error) {
+	if err := mkdirAll(rt, filepath.Dir(e.rel)); err != nil {
+		return "", err
+	}
+	out, err := rt.OpenFile(e.rel, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, e.mode)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.CopyN(io.MultiWriter(out, h), r, size); err != nil {
+		out.Close()
+		return "", err
+	}
+	// OpenFile leaves an existing file's mode alone.
+	if err := out.Chmod(e.mode); err != nil {
+		out.Close()
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), out.Close()
+}
+
+func hashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
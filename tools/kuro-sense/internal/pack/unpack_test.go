@@ -0,0 +1,181 @@
+package pack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckEntry(t *testing.T) {
+	for _, tt := range []struct {
+		hdr    tar.Header
+		reason string // "" = accepted
+		want   entry
+	}{
+		{hdr: tar.Header{Name: "memory/notes.md", Typeflag: tar.TypeReg, Mode: 0o600}, want: entry{rel: filepath.FromSlash("memory/notes.md"), mode: 0o644}},
+		{hdr: tar.Header{Name: "scripts/run.sh", Typeflag: tar.TypeReg, Mode: 0o4755}, want: entry{rel: filepath.FromSlash("scripts/run.sh"), mode: 0o755}},
+		{hdr: tar.Header{Name: "memory/", Typeflag: tar.TypeDir}, want: entry{rel: "memory", dir: true, mode: 0o755}},
+		{hdr: tar.Header{Name: "./memory/../skills/x.md", Typeflag: tar.TypeReg}, want: entry{rel: filepath.FromSlash("skills/x.md"), mode: 0o644}},
+		{hdr: tar.Header{Name: ".mini-agent/config.json", Typeflag: tar.TypeReg}, want: entry{rel: filepath.FromSlash(".mini-agent/config.json"), home: true, mode: 0o644}},
+		{hdr: tar.Header{Name: "", Typeflag: tar.TypeReg}, reason: "empty name"},
+		{hdr: tar.Header{Name: "/etc/passwd", Typeflag: tar.TypeReg}, reason: "absolute path"},
+		{hdr: tar.Header{Name: "../outside", Typeflag: tar.TypeReg}, reason: "escapes"},
+		{hdr: tar.Header{Name: "memory/../../outside", Typeflag: tar.TypeReg}, reason: "escapes"},
+		{hdr: tar.Header{Name: "..", Typeflag: tar.TypeDir}, reason: "escapes"},
+		{hdr: tar.Header{Name: ".kuro-sense/unpacked.json", Typeflag: tar.TypeReg}, reason: "state directory"},
+		{hdr: tar.Header{Name: ".kuro-sense", Typeflag: tar.TypeDir}, reason: "state directory"},
+		{hdr: tar.Header{Name: "memory/../.kuro-sense/backups/x.yaml", Typeflag: tar.TypeReg}, reason: "state directory"},
+		{hdr: tar.Header{Name: ".kuro-sense-staging-123/x", Typeflag: tar.TypeReg}, reason: "state directory"},
+		{hdr: tar.Header{Name: "memory/link", Typeflag: tar.TypeSymlink, Linkname: "/etc"}, reason: "symlink"},
+		{hdr: tar.Header{Name: "memory/hard", Typeflag: tar.TypeLink, Linkname: "memory/notes.md"}, reason: "hard link"},
+		{hdr: tar.Header{Name: "dev/null", Typeflag: tar.TypeChar}, reason: "device"},
+		{hdr: tar.Header{Name: "pipe", Typeflag: tar.TypeFifo}, reason: "named pipe"},
+	} {
+		e, reason := checkEntry(&tt.hdr)
+		switch {
+		case tt.reason == "" && reason != "":
+			t.Errorf("checkEntry(%q) rejected: %s", tt.hdr.Name, reason)
+		case tt.reason == "" && e != tt.want:
+			t.Errorf("checkEntry(%q) = %+v, want %+v", tt.hdr.Name, e, tt.want)
+		case tt.reason != "" && !strings.Contains(reason, tt.reason):
+			t.Errorf("checkEntry(%q) reason = %q, want %q", tt.hdr.Name, reason, tt.reason)
+		}
+	}
+}
+
+func TestUnpackRejectsUnsafeArchives(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		manifest *PackManifest
+		entries  []tarEntry
+		reason   string
+	}{
+		{
+			name:    "path traversal",
+			entries: []tarEntry{file("memory/ok.md", "ok"), file("../evil", "x")},
+			reason:  "escapes",
+		},
+		{
+			name:    "symlink",
+			entries: []tarEntry{{hdr: tar.Header{Name: "memory/link", Typeflag: tar.TypeSymlink, Linkname: "../../etc"}}},
+			reason:  "symlink",
+		},
+		{
+			name:    "state directory",
+			entries: []tarEntry{file(".kuro-sense/unpacked.json", `{"id":"forged"}`)},
+			reason:  "state directory",
+		},
+		{
+			name:     "tombstone in the state directory",
+			manifest: &PackManifest{Version: FormatVersion, ID: "p2", Base: "p1", Deleted: []string{".kuro-sense/backups"}},
+			reason:   "tombstone",
+		},
+		{
+			name:     "tombstone escaping",
+			manifest: &PackManifest{Version: FormatVersion, ID: "p2", Base: "p1", Deleted: []string{"../../home"}},
+			reason:   "tombstone",
+		},
+		{
+			name: "malformed hash",
+			manifest: &PackManifest{Version: FormatVersion, ID: "p1", Files: []ManifestFile{
+				{Path: "memory/a.md", Size: 1, SHA256: "../../x"},
+			}},
+			entries: []tarEntry{file("memory/a.md", "a")},
+			reason:  "invalid sha256",
+		},
+		{
+			name: "malformed unchanged hash",
+			manifest: &PackManifest{Version: FormatVersion, ID: "p2", Base: "p1", Unchanged: []ManifestFile{
+				{Path: "memory/a.md", Size: 1, SHA256: "ab"},
+			}},
+			reason: "invalid sha256",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			dest := filepath.Join(dir, "dest")
+			m := tt.manifest
+			if m == nil {
+				m = manifestFor("p1", "", tt.entries...)
+			}
+			archive := buildArchive(t, filepath.Join(dir, "a.tar.gz"), m, tt.entries...)
+
+			err := Unpack([]string{archive}, UnpackOptions{Dest: dest, HomeDir: filepath.Join(dir, "home")})
+			var rerr *RejectedError
+			if !errors.As(err, &rerr) {
+				t.Fatalf("Unpack error = %v, want a RejectedError", err)
+			}
+			if !strings.Contains(err.Error(), tt.reason) {
+				t.Errorf("Unpack error = %v, want %q", err, tt.reason)
+			}
+			if _, err := os.Stat(dest); !os.IsNotExist(err) {
+				t.Errorf("rejected archive created %s", dest)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "evil")); !os.IsNotExist(err) {
+				t.Errorf("rejected archive wrote outside the destination")
+			}
+		})
+	}
+}
+
+// tarEntry is an archive entry for buildArchive.
+type tarEntry struct {
+	hdr  tar.Header
+	body string
+}
+
+func file(name, body string) tarEntry {
+	return tarEntry{hdr: tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(body))}, body: body}
+}
+
+// manifestFor describes the regular files among entries.
+func manifestFor(id, base string, entries ...tarEntry) *PackManifest {
+	m := &PackManifest{Version: FormatVersion, CreatedAt: time.Now().UTC(), ID: id, Base: base, Files: []ManifestFile{}}
+	for _, e := range entries {
+		if e.hdr.Typeflag == tar.TypeReg {
+			m.Files = append(m.Files, ManifestFile{Path: e.hdr.Name, Size: int64(len(e.body)), SHA256: hashBytes([]byte(e.body))})
+		}
+	}
+	return m
+}
+
+// buildArchive writes a pack archive holding m (unless nil) and entries.
+func buildArchive(t *testing.T, path string, m *PackManifest, entries ...tarEntry) string {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	if m != nil {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append([]tarEntry{file(manifestName, string(data))}, entries...)
+	}
+	for _, e := range entries {
+		hdr := e.hdr
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
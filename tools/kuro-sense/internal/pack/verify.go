@@ -0,0 +1,268 @@
+package pack
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// VerifyOptions configures Verify.
+type VerifyOptions struct {
+	Limits           Limits
+	Decryption       Decryption   // keys for encrypted archives
+	Trusted          []TrustedKey // signers whose archives are accepted
+	RequireSignature bool         // an unsigned archive does not verify
+}
+
+// VerifyReport is the result of checking an archive against its manifest.
+type VerifyReport struct {
+	Archive   string       `json:"archive"`
+	Version   int          `json:"version"` // manifest format version; 0 when there is no manifest
+	Files     int          `json:"files"`   // file entries in the archive
+	Verified  int          `json:"verified"`
+	Missing   []string     `json:"missing,omitempty"` // in the manifest, not in the archive
+	Extra     []string     `json:"extra,omitempty"`   // in the archive, not in the manifest
+	Corrupted []Corruption `json:"corrupted,omitempty"`
+	Rejected  []Rejection  `json:"rejected,omitempty"` // unsafe entries Unpack refuses
+	Problems  []string     `json:"problems,omitempty"` // problems with the manifest itself
+
+	ID      string `json:"id,omitempty"`
+	Base    string `json:"base,omitempty"`    // pack an incremental archive builds on
+	Deleted int    `json:"deleted,omitempty"` // tombstones in an incremental archive
+
+	Encrypted        bool   `json:"encrypted"`
+	Signer           string `json:"signer,omitempty"` // ed25519 key that signed the manifest
+	SignerTrusted    bool   `json:"signerTrusted,omitempty"`
+	SignatureProblem string `json:"signatureProblem,omitempty"`
+
+	manifest *PackManifest
+}
+
+// Corruption is a file whose contents do not match the manifest.
+type Corruption struct {
+	Path       string `json:"path"`
+	Size       int64  `json:"size"`
+	WantSize   int64  `json:"wantSize"`
+	SHA256     string `json:"sha256"`
+	WantSHA256 string `json:"wantSha256"`
+}
+
+// OK reports whether every file verified and nothing else is wrong.
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Corrupted) == 0 &&
+		len(r.Rejected) == 0 && len(r.Problems) == 0 && r.SignatureProblem == ""
+}
+
+// Count is the number of problems in the report.
+func (r *VerifyReport) Count() int {
+	n := len(r.Missing) + len(r.Extra) + len(r.Corrupted) + len(r.Rejected) + len(r.Problems)
+	if r.SignatureProblem != "" {
+		n++
+	}
+	return n
+}
+
+// VerifyError is returned by a strict Unpack of an archive that does not
+// verify.
+type VerifyError struct {
+	Report *VerifyReport
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("%s does not verify: %d problem(s)", e.Report.Archive, e.Report.Count())
+}
+
+// Verify checks every entry of archive without extracting anything: each
+// file's size and SHA-256 against the manifest, files missing from or not
+// listed in the manifest, and entries, tombstones and manifest entries
+// Unpack would reject. Encrypted archives are decrypted with
+// opts.Decryption; a signature is checked against opts.Trusted.
+func Verify(archive string, opts VerifyOptions) (*VerifyReport, error) {
+	limits := opts.Limits.orDefault()
+	encrypted, err := IsEncrypted(archive)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	tr, closeArchive, err := openArchive(archive, opts.Decryption)
+	if err != nil {
+		return nil, err
+	}
+	defer closeArchive()
+
+	r := &VerifyReport{Archive: archive, Encrypted: encrypted}
+	var manifestData, sigData []byte
+	type seenFile struct {
+		size int64
+		hash string
+	}
+	seen := make(map[string]seenFile)
+	var order []string
+	var total int64
+	entries := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar: %w", err)
+		}
+		entries++
+		if entries > limits.MaxEntries {
+			r.Rejected = append(r.Rejected, Rejection{Name: hdr.Name, Reason: fmt.Sprintf("archive has more than %d entries", limits.MaxEntries)})
+			break
+		}
+
+		if hdr.Name == manifestName {
+			if r.manifest != nil {
+				r.Problems = append(r.Problems, "more than one manifest")
+				continue
+			}
+			m, data, err := readManifest(tr, hdr)
+			if err != nil {
+				r.Problems = append(r.Problems, err.Error())
+				continue
+			}
+			r.manifest, manifestData = m, data
+			r.Version = max(m.Version, 1)
+			if m.Version >= 2 && entries != 1 {
+				r.Problems = append(r.Problems, "manifest is not the first entry")
+			}
+			if m.Version > FormatVersion {
+				r.Problems = append(r.Problems, fmt.Sprintf("format version %d is newer than this kuro-sense understands (%d)", m.Version, FormatVersion))
+			}
+			continue
+		}
+		if hdr.Name == signatureName {
+			if sigData != nil || entries != 2 || r.manifest == nil {
+				r.SignatureProblem = "signature is not right after the manifest"
+				continue
+			}
+			if sigData, err = io.ReadAll(io.LimitReader(tr, 4096)); err != nil {
+				return nil, fmt.Errorf("read signature: %w", err)
+			}
+			continue
+		}
+
+		if _, reason := checkEntry(hdr); reason != "" {
+			r.Rejected = append(r.Rejected, Rejection{Name: hdr.Name, Reason: reason})
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if hdr.Size > limits.MaxFileSize {
+			r.Rejected = append(r.Rejected, Rejection{Name: hdr.Name, Reason: fmt.Sprintf("file is %d bytes, limit %d", hdr.Size, limits.MaxFileSize)})
+			continue
+		}
+		total += hdr.Size
+		if total > limits.MaxTotalSize {
+			r.Rejected = append(r.Rejected, Rejection{Name: hdr.Name, Reason: fmt.Sprintf("total size passes the %d byte limit", limits.MaxTotalSize)})
+			continue
+		}
+
+		h := sha256.New()
+		n, err := io.Copy(h, tr)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", hdr.Name, err)
+		}
+		r.Files++
+		if _, dup := seen[hdr.Name]; dup {
+			r.Problems = append(r.Problems, fmt.Sprintf("%s appears more than once", hdr.Name))
+		} else {
+			order = append(order, hdr.Name)
+		}
+		seen[hdr.Name] = seenFile{size: n, hash: hex.EncodeToString(h.Sum(nil))}
+	}
+
+	if r.manifest == nil {
+		r.Problems = append(r.Problems, "no manifest")
+		return r, nil
+	}
+	if r.SignatureProblem == "" {
+		r.checkSigner(sigData, manifestData, opts)
+	}
+	r.ID, r.Base, r.Deleted = r.manifest.ID, r.manifest.Base, len(r.manifest.Deleted)
+	for _, d := range r.manifest.Deleted {
+		if _, reason := checkPath(d); reason != "" {
+			r.Rejected = append(r.Rejected, Rejection{Name: d, Reason: "tombstone: " + reason})
+		}
+	}
+	for _, list := range [][]ManifestFile{r.manifest.Files, r.manifest.Unchanged} {
+		for _, mf := range list {
+			if _, reason := checkPath(mf.Path); reason != "" {
+				r.Rejected = append(r.Rejected, Rejection{Name: mf.Path, Reason: "manifest: " + reason})
+			} else if !validHash(mf.SHA256) {
+				r.Rejected = append(r.Rejected, Rejection{Name: mf.Path, Reason: fmt.Sprintf("manifest: invalid sha256 %q", mf.SHA256)})
+			}
+		}
+	}
+
+	listed := make(map[string]bool)
+	for _, mf := range r.manifest.Files {
+		listed[mf.Path] = true
+		got, ok := seen[mf.Path]
+		switch {
+		case !ok:
+			r.Missing = append(r.Missing, mf.Path)
+		case got.size != mf.Size || got.hash != mf.SHA256:
+			r.Corrupted = append(r.Corrupted, Corruption{
+				Path: mf.Path, Size: got.size, WantSize: mf.Size,
+				SHA256: got.hash, WantSHA256: mf.SHA256,
+			})
+		default:
+			r.Verified++
+		}
+	}
+	for _, name := range order {
+		if !listed[name] {
+			r.Extra = append(r.Extra, name)
+		}
+	}
+	sort.Strings(r.Missing)
+	return r, nil
+}
+
+// checkSigner fills in the signature fields from manifest.sig.
+func (r *VerifyReport) checkSigner(sigData, manifestData []byte, opts VerifyOptions) {
+	if sigData == nil {
+		if opts.RequireSignature {
+			r.SignatureProblem = "archive is not signed"
+		}
+		return
+	}
+	key, err := checkSignature(sigData, manifestData)
+	if key != nil {
+		r.Signer = formatSigner(key)
+	}
+	if err != nil {
+		r.SignatureProblem = err.Error()
+		return
+	}
+	for _, t := range opts.Trusted {
+		if t.Key.Equal(key) {
+			r.SignerTrusted = true
+			return
+		}
+	}
+	r.SignatureProblem = fmt.Sprintf("signed by %s, which is not a trusted key", r.Signer)
+}
+
+func readManifest(r io.Reader, hdr *tar.Header) (*PackManifest, []byte, error) {
+	if hdr.Size > maxManifestSize {
+		return nil, nil, fmt.Errorf("manifest is %d bytes, limit %d", hdr.Size, maxManifestSize)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxManifestSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read manifest: %w", err)
+	}
+	m := &PackManifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	return m, data, nil
+}
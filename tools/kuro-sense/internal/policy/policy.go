@@ -0,0 +1,283 @@
+// Package policy decides which plugins apply --auto enables or disables,
+// from detection results and an optional rules file, and records why.
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/detect"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+	"gopkg.in/yaml.v3"
+)
+
+// Rules adjust the default auto-apply policy, which enables available
+// DefaultOn capabilities and disables unavailable ones.
+type Rules struct {
+	PinOn          []string `yaml:"pin_on" json:"pinOn,omitempty"`           // always enabled
+	PinOff         []string `yaml:"pin_off" json:"pinOff,omitempty"`         // always disabled; wins over pin_on
+	RequireAllDeps bool     `yaml:"require_all_deps" json:"requireAllDeps"`  // optional deps must be present too
+	SkipDegraded   bool     `yaml:"skip_degraded" json:"skipDegraded"`       // leave degraded capabilities as they are
+	EnableTags     []string `yaml:"enable_tags" json:"enableTags,omitempty"` // also enable available capabilities with these tags
+}
+
+// RulesPath is the default rules file.
+func RulesPath(agentDir string) string {
+	return filepath.Join(agentDir, ".kuro-sense", "rules.yaml")
+}
+
+// LoadRules reads a rules file. A missing file yields the default rules.
+func LoadRules(path string) (Rules, error) {
+	var r Rules
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return r, fmt.Errorf("read rules: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&r); err != nil && !errors.Is(err, io.EOF) {
+		return r, fmt.Errorf("parse rules %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Action is what auto-apply does with one plugin.
+type Action string
+
+const (
+	Enable  Action = "enable"
+	Disable Action = "disable"
+	Keep    Action = "keep" // leave the compose entry as it is
+)
+
+// Decision is the action for one plugin and the reason for it.
+type Decision struct {
+	Name   string `json:"name"`
+	Action Action `json:"action"`
+	Reason string `json:"reason"`
+}
+
+var pastTense = map[Action]string{Enable: "enabled", Disable: "disabled", Keep: "kept"}
+
+// String reads like "disabled x-feed: missing XAI_API_KEY".
+func (d Decision) String() string {
+	return fmt.Sprintf("%s %s: %s", pastTense[d.Action], d.Name, d.Reason)
+}
+
+// Decide applies the rules to detection results, one decision per
+// capability in result order. Pinned names the registry does not know
+// are decided too, at the end.
+func Decide(rules Rules, results []registry.DetectionResult) []Decision {
+	var out []Decision
+	seen := make(map[string]bool, len(results))
+	for _, r := range results {
+		seen[r.Capability.Name] = true
+		if d, ok := pinned(rules, r.Capability.Name); ok {
+			out = append(out, d)
+			continue
+		}
+		out = append(out, decide(rules, r))
+	}
+	for _, names := range [][]string{rules.PinOff, rules.PinOn} {
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				d, _ := pinned(rules, name)
+				d.Reason += " (not in the registry)"
+				out = append(out, d)
+			}
+		}
+	}
+	return out
+}
+
+// Pin overrides decisions made elsewhere (such as by a profile) with the
+// rules' pin_on and pin_off lists, adding pinned names not yet decided.
+func Pin(rules Rules, decisions []Decision) []Decision {
+	out := make([]Decision, 0, len(decisions))
+	seen := make(map[string]bool, len(decisions))
+	for _, d := range decisions {
+		seen[d.Name] = true
+		if p, ok := pinned(rules, d.Name); ok {
+			d = p
+		}
+		out = append(out, d)
+	}
+	for _, names := range [][]string{rules.PinOff, rules.PinOn} {
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				d, _ := pinned(rules, name)
+				out = append(out, d)
+			}
+		}
+	}
+	return out
+}
+
+func pinned(rules Rules, name string) (Decision, bool) {
+	if slices.Contains(rules.PinOff, name) {
+		return Decision{Name: name, Action: Disable, Reason: "pinned off"}, true
+	}
+	if slices.Contains(rules.PinOn, name) {
+		return Decision{Name: name, Action: Enable, Reason: "pinned on"}, true
+	}
+	return Decision{}, false
+}
+
+func decide(rules Rules, r registry.DetectionResult) Decision {
+	name := r.Capability.Name
+	if !r.Available {
+		return Decision{Name: name, Action: Disable, Reason: unavailableReason(r)}
+	}
+	if r.Degraded || len(r.UnknownDeps) > 0 {
+		if rules.RequireAllDeps {
+			return Decision{Name: name, Action: Disable, Reason: degradedReason(r) + " (require_all_deps)"}
+		}
+		if rules.SkipDegraded {
+			return Decision{Name: name, Action: Keep, Reason: degradedReason(r) + " (skip_degraded)"}
+		}
+	}
+	if r.Capability.DefaultOn {
+		return Decision{Name: name, Action: Enable, Reason: "available, on by default"}
+	}
+	for _, tag := range rules.EnableTags {
+		if slices.Contains(r.Capability.Tags, tag) {
+			return Decision{Name: name, Action: Enable, Reason: fmt.Sprintf("available, tagged %s", tag)}
+		}
+	}
+	return Decision{Name: name, Action: Keep, Reason: "available, not on by default"}
+}
+
+// unavailableReason names what makes r unavailable: required deps that are
+// missing, too old or uncheckable, or the platform.
+func unavailableReason(r registry.DetectionResult) string {
+	var parts []string
+	labels := detect.MissingLabels(r)
+	var missing []string
+	for i, d := range r.MissingDeps {
+		if d.Required {
+			missing = append(missing, labels[i])
+		}
+	}
+	if len(missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(missing, ", "))
+	}
+	if outdated := requiredOnly(r.OutdatedDeps, detect.OutdatedLabels(r)); len(outdated) > 0 {
+		parts = append(parts, "too old: "+strings.Join(outdated, "; "))
+	}
+	if unknown := requiredOnly(r.UnknownDeps, detect.UnknownLabels(r.UnknownDeps)); len(unknown) > 0 {
+		parts = append(parts, "cannot check "+strings.Join(unknown, ", "))
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// degradedReason names the optional deps an available capability lacks.
+func degradedReason(r registry.DetectionResult) string {
+	var parts []string
+	if labels := detect.MissingLabels(r); len(labels) > 0 {
+		parts = append(parts, "missing optional "+strings.Join(labels, ", "))
+	}
+	if labels := detect.OutdatedLabels(r); len(labels) > 0 {
+		parts = append(parts, "too old: "+strings.Join(labels, "; "))
+	}
+	if labels := detect.UnknownLabels(r.UnknownDeps); len(labels) > 0 {
+		parts = append(parts, "cannot check "+strings.Join(labels, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func requiredOnly(deps []registry.Dependency, labels []string) []string {
+	var out []string
+	for i, d := range deps {
+		if d.Required {
+			out = append(out, labels[i])
+		}
+	}
+	return out
+}
+
+// Link makes decisions consistent with the capability graph. A plugin
+// whose requirement is disabled is disabled too; a requirement of an
+// enabled plugin that would otherwise be left alone is enabled; and of two
+// conflicting enabled plugins, the later one is disabled.
+func Link(caps []registry.Capability, decisions []Decision) []Decision {
+	out := slices.Clone(decisions)
+	index := make(map[string]int, len(out))
+	for i, d := range out {
+		index[d.Name] = i
+	}
+	byName := make(map[string]registry.Capability, len(caps))
+	for _, c := range caps {
+		byName[c.Name] = c
+	}
+
+	// Actions only move from keep to enable and from either to disable,
+	// so this settles.
+	for changed := true; changed; {
+		changed = false
+		for i := range out {
+			d := &out[i]
+			if d.Action == Disable {
+				continue
+			}
+			for _, r := range byName[d.Name].Requires {
+				j, ok := index[r]
+				if ok && out[j].Action == Disable {
+					*d = Decision{Name: d.Name, Action: Disable, Reason: fmt.Sprintf("requires %s, which is disabled", r)}
+					changed = true
+					break
+				}
+				if d.Action != Enable || ok && out[j].Action == Enable {
+					continue
+				}
+				pull := Decision{Name: r, Action: Enable, Reason: "required by " + d.Name}
+				if ok {
+					out[j] = pull
+				} else {
+					index[r] = len(out)
+					out = append(out, pull)
+					d = &out[i] // out may have moved
+				}
+				changed = true
+			}
+			if d.Action != Enable {
+				continue
+			}
+			for _, x := range registry.Conflicting(caps, d.Name) {
+				if j, ok := index[x]; ok && j < i && out[j].Action == Enable {
+					*d = Decision{Name: d.Name, Action: Disable, Reason: "conflicts with " + x}
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return out
+}
+
+// Split returns the names to enable and to disable.
+func Split(decisions []Decision) (enable, disable []string) {
+	for _, d := range decisions {
+		switch d.Action {
+		case Enable:
+			enable = append(enable, d.Name)
+		case Disable:
+			disable = append(disable, d.Name)
+		}
+	}
+	return enable, disable
+}
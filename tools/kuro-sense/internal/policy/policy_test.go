@@ -0,0 +1,136 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+func TestLink(t *testing.T) {
+	caps := []registry.Capability{
+		{Name: "a", Requires: []string{"b"}},
+		{Name: "b"},
+		{Name: "c", ConflictsWith: []string{"d"}},
+		{Name: "d"},
+		{Name: "e", Requires: []string{"a"}},
+	}
+	on := func(name, reason string) Decision { return Decision{Name: name, Action: Enable, Reason: reason} }
+	off := func(name, reason string) Decision { return Decision{Name: name, Action: Disable, Reason: reason} }
+	keep := func(name, reason string) Decision { return Decision{Name: name, Action: Keep, Reason: reason} }
+
+	for _, tt := range []struct {
+		name string
+		in   []Decision
+		want []Decision
+	}{
+		{
+			name: "consistent decisions are unchanged",
+			in:   []Decision{on("a", "x"), on("b", "x"), off("c", "x"), on("d", "x")},
+			want: []Decision{on("a", "x"), on("b", "x"), off("c", "x"), on("d", "x")},
+		},
+		{
+			name: "disabled requirement disables the plugin",
+			in:   []Decision{on("a", "x"), off("b", "missing jq")},
+			want: []Decision{off("a", "requires b, which is disabled"), off("b", "missing jq")},
+		},
+		{
+			name: "disabling cascades",
+			in:   []Decision{on("e", "x"), on("a", "x"), off("b", "x")},
+			want: []Decision{off("e", "requires a, which is disabled"), off("a", "requires b, which is disabled"), off("b", "x")},
+		},
+		{
+			name: "kept plugin with a disabled requirement",
+			in:   []Decision{keep("a", "x"), off("b", "x")},
+			want: []Decision{off("a", "requires b, which is disabled"), off("b", "x")},
+		},
+		{
+			name: "kept requirement is enabled",
+			in:   []Decision{on("a", "x"), keep("b", "x")},
+			want: []Decision{on("a", "x"), on("b", "required by a")},
+		},
+		{
+			name: "undecided requirements are appended",
+			in:   []Decision{on("e", "x")},
+			want: []Decision{on("e", "x"), on("a", "required by e"), on("b", "required by a")},
+		},
+		{
+			name: "later of two conflicting plugins is disabled",
+			in:   []Decision{on("d", "x"), on("c", "x")},
+			want: []Decision{on("d", "x"), off("c", "conflicts with d")},
+		},
+		{
+			name: "unknown names pass through",
+			in:   []Decision{on("custom", "x"), off("other", "x")},
+			want: []Decision{on("custom", "x"), off("other", "x")},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Link(caps, tt.in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Link:\n got %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkLeavesInputAlone(t *testing.T) {
+	caps := []registry.Capability{{Name: "a", Requires: []string{"b"}}, {Name: "b"}}
+	in := []Decision{{Name: "a", Action: Enable}, {Name: "b", Action: Disable}}
+	Link(caps, in)
+	if in[0].Action != Enable {
+		t.Errorf("Link modified its input: %v", in)
+	}
+}
+
+func TestPinWins(t *testing.T) {
+	rules := Rules{PinOn: []string{"a", "c"}, PinOff: []string{"b", "c"}}
+	got := Pin(rules, []Decision{
+		{Name: "a", Action: Disable, Reason: "missing jq"},
+		{Name: "b", Action: Enable, Reason: "available, on by default"},
+	})
+	want := []Decision{
+		{Name: "a", Action: Enable, Reason: "pinned on"},
+		{Name: "b", Action: Disable, Reason: "pinned off"},
+		{Name: "c", Action: Disable, Reason: "pinned off"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Pin:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestDecide(t *testing.T) {
+	result := func(name string, available, degraded, defaultOn bool, tags ...string) registry.DetectionResult {
+		return registry.DetectionResult{
+			Capability: registry.Capability{Name: name, DefaultOn: defaultOn, Tags: tags},
+			Available:  available,
+			Degraded:   degraded,
+		}
+	}
+	results := []registry.DetectionResult{
+		result("on", true, false, true),
+		result("off-by-default", true, false, false),
+		result("tagged", true, false, false, "git"),
+		result("degraded", true, true, true),
+	}
+	for _, tt := range []struct {
+		name  string
+		rules Rules
+		want  []Action
+	}{
+		{"defaults", Rules{}, []Action{Enable, Keep, Keep, Enable}},
+		{"enable_tags", Rules{EnableTags: []string{"git"}}, []Action{Enable, Keep, Enable, Enable}},
+		{"skip_degraded", Rules{SkipDegraded: true}, []Action{Enable, Keep, Keep, Keep}},
+		{"require_all_deps", Rules{RequireAllDeps: true}, []Action{Enable, Keep, Keep, Disable}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Action
+			for _, d := range Decide(tt.rules, results) {
+				got = append(got, d.Action)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("actions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package profile
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/detect"
+)
+
+// Condition describes the environment a profile is meant for. Every field
+// that is set must hold; an empty Condition matches nothing, so profiles
+// without one are never chosen automatically.
+type Condition struct {
+	OS         []string `yaml:"os,omitempty" json:"os,omitempty"`             // darwin, linux, ...
+	Hostname   []string `yaml:"hostname,omitempty" json:"hostname,omitempty"` // glob patterns
+	Display    *bool    `yaml:"display,omitempty" json:"display,omitempty"`
+	Camera     *bool    `yaml:"camera,omitempty" json:"camera,omitempty"`
+	Microphone *bool    `yaml:"microphone,omitempty" json:"microphone,omitempty"`
+	Internet   *bool    `yaml:"internet,omitempty" json:"internet,omitempty"`
+	Available  []string `yaml:"available,omitempty" json:"available,omitempty"` // capabilities that must be available
+}
+
+// specificity counts the conditions that are set.
+func (c *Condition) specificity() int {
+	n := len(c.Available)
+	for _, set := range []bool{len(c.OS) > 0, len(c.Hostname) > 0,
+		c.Display != nil, c.Camera != nil, c.Microphone != nil, c.Internet != nil} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// Matches reports whether the detected environment satisfies c, and if
+// not, the first condition that failed.
+func (c *Condition) Matches(r detect.Results) (bool, string) {
+	if c == nil || c.specificity() == 0 {
+		return false, "no conditions"
+	}
+	if len(c.OS) > 0 && !slices.Contains(c.OS, r.OS.OS) {
+		return false, fmt.Sprintf("os is %s", r.OS.OS)
+	}
+	if len(c.Hostname) > 0 && !slices.ContainsFunc(c.Hostname, func(p string) bool {
+		ok, _ := path.Match(p, r.OS.Hostname)
+		return ok
+	}) {
+		return false, fmt.Sprintf("hostname is %s", r.OS.Hostname)
+	}
+	for _, b := range []struct {
+		name string
+		want *bool
+		have bool
+	}{
+		{"display", c.Display, len(r.Hardware.Displays) > 0},
+		{"camera", c.Camera, len(r.Hardware.Cameras) > 0},
+		{"microphone", c.Microphone, len(r.Hardware.Microphones) > 0},
+		{"internet", c.Internet, r.Network.Internet.Connected},
+	} {
+		if b.want != nil && *b.want != b.have {
+			return false, fmt.Sprintf("%s: want %t, have %t", b.name, *b.want, b.have)
+		}
+	}
+	for _, name := range c.Available {
+		if !available(r, name) {
+			return false, fmt.Sprintf("%s is not available", name)
+		}
+	}
+	return true, ""
+}
+
+// String summarizes the conditions, e.g. "os=linux display=false".
+func (c *Condition) String() string {
+	if c == nil {
+		return ""
+	}
+	var parts []string
+	if len(c.OS) > 0 {
+		parts = append(parts, "os="+strings.Join(c.OS, ","))
+	}
+	if len(c.Hostname) > 0 {
+		parts = append(parts, "hostname="+strings.Join(c.Hostname, ","))
+	}
+	for _, b := range []struct {
+		name string
+		v    *bool
+	}{{"display", c.Display}, {"camera", c.Camera}, {"microphone", c.Microphone}, {"internet", c.Internet}} {
+		if b.v != nil {
+			parts = append(parts, fmt.Sprintf("%s=%t", b.name, *b.v))
+		}
+	}
+	if len(c.Available) > 0 {
+		parts = append(parts, "available="+strings.Join(c.Available, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func available(r detect.Results, name string) bool {
+	for _, c := range r.Capabilities {
+		if c.Capability.Name == name {
+			return c.Available
+		}
+	}
+	return false
+}
+
+// Select picks the profile for the detected environment: among profiles
+// whose conditions all hold, the one with the most conditions wins, ties
+// going to the first name. It returns nil if none match.
+func Select(profiles []*Profile, r detect.Results) *Profile {
+	var best *Profile
+	for _, p := range profiles {
+		if ok, _ := p.When.Matches(r); !ok {
+			continue
+		}
+		if best == nil || p.When.specificity() > best.When.specificity() {
+			best = p
+		}
+	}
+	return best
+}
@@ -0,0 +1,105 @@
+package profile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/detect"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
+)
+
+func boolp(b bool) *bool { return &b }
+
+// desk is a linux workstation with a display and a microphone but no
+// camera, online, where github-prs is available and git-detail is not.
+var desk = detect.Results{
+	OS: detect.OSInfo{OS: "linux", Hostname: "desk-01"},
+	Hardware: detect.HardwareInfo{
+		Displays:    []detect.Display{{}},
+		Microphones: []detect.HWDevice{{}},
+	},
+	Network: detect.NetworkInfo{Internet: detect.InternetStatus{Connected: true}},
+	Capabilities: []registry.DetectionResult{
+		{Capability: registry.Capability{Name: "github-prs"}, Available: true},
+		{Capability: registry.Capability{Name: "git-detail"}, Available: false},
+	},
+}
+
+func TestConditionMatches(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		when   *Condition
+		want   bool
+		reason string // part of the failure reason
+	}{
+		{name: "nil", when: nil, reason: "no conditions"},
+		{name: "empty", when: &Condition{}, reason: "no conditions"},
+		{name: "os", when: &Condition{OS: []string{"darwin", "linux"}}, want: true},
+		{name: "other os", when: &Condition{OS: []string{"darwin"}}, reason: "os is linux"},
+		{name: "hostname glob", when: &Condition{Hostname: []string{"laptop-*", "desk-*"}}, want: true},
+		{name: "hostname exact", when: &Condition{Hostname: []string{"desk-01"}}, want: true},
+		{name: "hostname mismatch", when: &Condition{Hostname: []string{"desk-0?2", "server"}}, reason: "hostname is desk-01"},
+		{name: "display", when: &Condition{Display: boolp(true)}, want: true},
+		{name: "no display", when: &Condition{Display: boolp(false)}, reason: "display: want false, have true"},
+		{name: "no camera", when: &Condition{Camera: boolp(false)}, want: true},
+		{name: "camera", when: &Condition{Camera: boolp(true)}, reason: "camera: want true, have false"},
+		{name: "microphone", when: &Condition{Microphone: boolp(true)}, want: true},
+		{name: "no microphone", when: &Condition{Microphone: boolp(false)}, reason: "microphone: want false, have true"},
+		{name: "internet", when: &Condition{Internet: boolp(true)}, want: true},
+		{name: "offline", when: &Condition{Internet: boolp(false)}, reason: "internet: want false, have true"},
+		{name: "available", when: &Condition{Available: []string{"github-prs"}}, want: true},
+		{name: "unavailable", when: &Condition{Available: []string{"github-prs", "git-detail"}}, reason: "git-detail is not available"},
+		{name: "unknown capability", when: &Condition{Available: []string{"nope"}}, reason: "nope is not available"},
+		{
+			name: "every condition",
+			when: &Condition{OS: []string{"linux"}, Hostname: []string{"desk-*"}, Display: boolp(true),
+				Camera: boolp(false), Microphone: boolp(true), Internet: boolp(true), Available: []string{"github-prs"}},
+			want: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := tt.when.Matches(desk)
+			if got != tt.want {
+				t.Errorf("Matches = %v (%s), want %v", got, reason, tt.want)
+			}
+			if !strings.Contains(reason, tt.reason) || (tt.want && reason != "") {
+				t.Errorf("reason = %q, want %q", reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	headless := &Profile{Name: "headless", When: &Condition{Display: boolp(false), Camera: boolp(false)}}
+	linux := &Profile{Name: "linux", When: &Condition{OS: []string{"linux"}}}
+	online := &Profile{Name: "online", When: &Condition{Internet: boolp(true)}}
+	desktop := &Profile{Name: "desktop", When: &Condition{Display: boolp(true), Available: []string{"github-prs"}}}
+	manual := &Profile{Name: "manual"}
+
+	for _, tt := range []struct {
+		name     string
+		profiles []*Profile
+		want     *Profile
+	}{
+		{name: "none", profiles: nil},
+		{name: "no conditions never match", profiles: []*Profile{manual}},
+		{name: "failing conditions", profiles: []*Profile{headless, manual}},
+		{name: "single match", profiles: []*Profile{headless, linux}, want: linux},
+		{name: "most conditions wins", profiles: []*Profile{desktop, linux}, want: desktop},
+		{name: "most conditions wins from later", profiles: []*Profile{linux, desktop}, want: desktop},
+		{name: "tie goes to the first name", profiles: []*Profile{linux, online}, want: linux},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Select(tt.profiles, desk); got != tt.want {
+				t.Errorf("Select = %v, want %v", name(got), name(tt.want))
+			}
+		})
+	}
+}
+
+func name(p *Profile) string {
+	if p == nil {
+		return "<nil>"
+	}
+	return p.Name
+}
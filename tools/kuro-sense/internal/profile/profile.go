@@ -0,0 +1,193 @@
+// Package profile stores named perception configurations (which plugins
+// are enabled, plus per-plugin overrides) next to agent-compose.yaml, so an
+// agent can switch between setups such as "laptop" and "headless" in one
+// step.
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/fsutil"
+	"gopkg.in/yaml.v3"
+)
+
+var nameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Profile is a named set of enabled plugins with overrides.
+type Profile struct {
+	Name        string            `yaml:"-" json:"name"`
+	Description string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Enabled     []string          `yaml:"enabled" json:"enabled"`
+	Set         map[string]string `yaml:"set,omitempty" json:"set,omitempty"` // "plugin.field" → value, see compose.ParseSetting
+	When        *Condition        `yaml:"when,omitempty" json:"when,omitempty"`
+}
+
+// Dir is where profiles are stored.
+func Dir(agentDir string) string {
+	return filepath.Join(agentDir, ".kuro-sense", "profiles")
+}
+
+func file(agentDir, name string) string {
+	return filepath.Join(Dir(agentDir), name+".yaml")
+}
+
+// ValidName reports whether name can be used as a profile name.
+func ValidName(name string) error {
+	if !nameRe.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q (lowercase letters, digits, '.', '_' and '-')", name)
+	}
+	return nil
+}
+
+// Load reads one profile.
+func Load(agentDir, name string) (*Profile, error) {
+	if err := ValidName(name); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file(agentDir, name))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no profile %q in %s", name, Dir(agentDir))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read profile: %w", err)
+	}
+	var p Profile
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse profile %s: %w", name, err)
+	}
+	p.Name = name
+	if _, err := p.Settings(); err != nil {
+		return nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+	return &p, nil
+}
+
+// List loads every profile, sorted by name. Files that fail to load are
+// returned as errors alongside the rest.
+func List(agentDir string) ([]*Profile, []error) {
+	entries, err := os.ReadDir(Dir(agentDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, []error{fmt.Errorf("read profiles: %w", err)}
+	}
+	var out []*Profile
+	var errs []error
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".yaml")
+		if e.IsDir() || !ok {
+			continue
+		}
+		p, err := Load(agentDir, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, errs
+}
+
+// Save writes p, replacing any profile of the same name.
+func Save(agentDir string, p *Profile) error {
+	if err := ValidName(p.Name); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(p); err != nil {
+		return fmt.Errorf("encode profile: %w", err)
+	}
+	enc.Close()
+	data := buf.Bytes()
+	if err := os.MkdirAll(Dir(agentDir), 0o755); err != nil {
+		return fmt.Errorf("create profile dir: %w", err)
+	}
+	return fsutil.WriteFileAtomic(file(agentDir, p.Name), data, 0o644)
+}
+
+// Snapshot captures the enabled plugins of the selected agent and their
+// interval, timeout and output_cap as a profile.
+func Snapshot(cf *compose.ComposeFile, agent, name string) (*Profile, error) {
+	perceptions, err := compose.GetCustomPerceptions(cf, agent)
+	if err != nil {
+		return nil, err
+	}
+	p := &Profile{Name: name, Enabled: []string{}}
+	for _, c := range perceptions {
+		if c.Enabled != nil && !*c.Enabled {
+			continue
+		}
+		p.Enabled = append(p.Enabled, c.Name)
+		if c.Interval != "" {
+			p.set(c.Name, "interval", c.Interval)
+		}
+		if c.Timeout != nil && *c.Timeout > 0 {
+			p.set(c.Name, "timeout", fmt.Sprint(*c.Timeout))
+		}
+		if c.OutputCap != nil && *c.OutputCap > 0 {
+			p.set(c.Name, "output_cap", fmt.Sprint(*c.OutputCap))
+		}
+	}
+	return p, nil
+}
+
+func (p *Profile) set(plugin, field, value string) {
+	if p.Set == nil {
+		p.Set = make(map[string]string)
+	}
+	p.Set[plugin+"."+field] = value
+}
+
+// Settings parses the overrides in a stable order.
+func (p *Profile) Settings() ([]compose.Setting, error) {
+	keys := make([]string, 0, len(p.Set))
+	for k := range p.Set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var out []compose.Setting
+	for _, k := range keys {
+		s, err := compose.ParseSetting(k + "=" + p.Set[k])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Changes returns the edit that makes the selected agent match p: the
+// profile's plugins are enabled, every other plugin in the compose file is
+// disabled, and the overrides are applied.
+func (p *Profile) Changes(cf *compose.ComposeFile, agent string) (compose.Changes, error) {
+	settings, err := p.Settings()
+	if err != nil {
+		return compose.Changes{}, err
+	}
+	current, err := compose.GetCustomPerceptions(cf, agent)
+	if err != nil {
+		return compose.Changes{}, err
+	}
+	keep := make(map[string]bool, len(p.Enabled))
+	for _, name := range p.Enabled {
+		keep[name] = true
+	}
+	ch := compose.Changes{Agent: agent, Enable: p.Enabled, Set: settings}
+	for _, c := range current {
+		if !keep[c.Name] {
+			ch.Disable = append(ch.Disable, c.Name)
+		}
+	}
+	return ch, nil
+}
@@ -1,13 +1,32 @@
 package registry
 
-// Category represents a perception plugin category.
+import "slices"
+
+// Category represents a perception plugin category. Display names and
+// order come from the category table (see Categories).
 type Category string
 
 const (
-	CategoryWorkspace Category = "workspace"
-	CategoryChrome    Category = "chrome"
-	CategoryTelegram  Category = "telegram"
-	CategoryHeartbeat Category = "heartbeat"
+	CategoryWorkspace     Category = "workspace"
+	CategoryChrome        Category = "chrome"
+	CategoryTelegram      Category = "telegram"
+	CategoryHeartbeat     Category = "heartbeat"
+	CategoryCommunication Category = "communication"
+	CategoryMonitoring    Category = "monitoring"
+	CategoryVision        Category = "vision"
+)
+
+// Well-known capability tags. Manifests may use any tag.
+const (
+	TagNetwork          = "network"           // talks to a remote host or API
+	TagMacOS            = "macos"             // works only on macOS
+	TagLLMCost          = "llm-cost"          // calls a paid model API on each run
+	TagPrivacySensitive = "privacy-sensitive" // reads the screen, camera, location, browser or app focus
+	TagInbox            = "inbox"             // delivers messages to the agent
+	TagGit              = "git"
+	TagGitHub           = "github"
+	TagDocker           = "docker"
+	TagSystem           = "system" // host health and maintenance
 )
 
 // DependencyKind represents what kind of dependency to check.
@@ -21,6 +40,13 @@ const (
 	KindPython   DependencyKind = "python"
 	KindHardware DependencyKind = "hardware" // Check: "camera" / "microphone" / "display" / "speaker"
 	KindNetwork  DependencyKind = "network"  // Check: "internet" / "host:port" endpoint reachability
+
+	KindNpm             DependencyKind = "npm"              // Check: globally installed npm package
+	KindDockerImage     DependencyKind = "docker-image"     // Check: local image reference, e.g. "redis:7"
+	KindHTTP            DependencyKind = "http"             // Check: URL that must return 2xx
+	KindMacOSPermission DependencyKind = "macos-permission" // Check: "screen-recording" / "accessibility" / "full-disk-access"
+	KindProcess         DependencyKind = "process"          // Check: running process name
+	KindPortFree        DependencyKind = "port-free"        // Check: "port" or "host:port" that must be free
 )
 
 // InstallMethod represents how to install a dependency.
@@ -29,8 +55,14 @@ type InstallMethod string
 const (
 	InstallBrew   InstallMethod = "brew"
 	InstallApt    InstallMethod = "apt"
-	InstallCurl   InstallMethod = "curl"
+	InstallDnf    InstallMethod = "dnf"
+	InstallPacman InstallMethod = "pacman"
+	InstallApk    InstallMethod = "apk"
+	InstallCurl   InstallMethod = "curl" // download a binary onto PATH, checked against SHA256
 	InstallPip    InstallMethod = "pip"
+	InstallNpm    InstallMethod = "npm"
+	InstallGo     InstallMethod = "go"     // go install
+	InstallDocker InstallMethod = "docker" // docker pull
 	InstallManual InstallMethod = "manual"
 )
 
@@ -40,11 +72,19 @@ type Platform struct {
 	Arch []string // empty = all
 }
 
-// InstallHint tells the installer how to install a dependency.
+// Matches reports whether p allows goos/goarch.
+func (p Platform) Matches(goos, goarch string) bool {
+	return (len(p.OS) == 0 || slices.Contains(p.OS, goos)) &&
+		(len(p.Arch) == 0 || slices.Contains(p.Arch, goarch))
+}
+
+// InstallHint tells the installer one way to install a dependency.
 type InstallHint struct {
-	Method  InstallMethod
-	Package string // brew/apt package name, or URL for curl
-	Command string // fallback manual command
+	Method   InstallMethod
+	Package  string   // package name; module path for go (version defaults to @latest); URL for curl
+	Command  string   // manual command
+	SHA256   string   // curl: hex checksum the download must match
+	Platform Platform // where the hint applies; empty = everywhere
 }
 
 // Dependency represents a prerequisite for a capability.
@@ -52,27 +92,58 @@ type Dependency struct {
 	Name     string
 	Kind     DependencyKind
 	Check    string // binary name / "host:port" / file path / env var / python module
+	Version  string // optional constraint on the installed version, e.g. ">=3.10"
 	Required bool
-	Install  InstallHint
+	Install  []InstallHint // alternatives; the installer picks one that works on the host
+	Format   SecretFormat  // envvar: what a valid value looks like
 }
 
 // Capability is the full definition of a perception plugin.
 type Capability struct {
-	Name         string
-	Script       string   // e.g. "./plugins/docker-status.sh"
-	Description  string
-	Category     Category
-	Dependencies []Dependency
-	Platform     Platform
-	Timeout      int  // ms, 0 = default (10000)
-	DefaultOn    bool
-	Tags         []string
+	Name          string
+	Script        string // e.g. "./plugins/docker-status.sh"
+	Description   string
+	Category      Category
+	Dependencies  []Dependency
+	Platform      Platform
+	Timeout       int // ms, 0 = default (10000)
+	DefaultOn     bool
+	Tags          []string
+	Requires      []string // capabilities that must be enabled alongside this one
+	ConflictsWith []string // capabilities that must not be enabled alongside this one
+}
+
+// CheckStatus is the outcome of probing one dependency.
+type CheckStatus string
+
+const (
+	StatusPresent  CheckStatus = "present"
+	StatusMissing  CheckStatus = "missing"
+	StatusTimeout  CheckStatus = "timeout"  // probe exceeded its deadline
+	StatusFailed   CheckStatus = "failed"   // probe could not run
+	StatusUnknown  CheckStatus = "unknown"  // no checker for the dependency kind
+	StatusOutdated CheckStatus = "outdated" // present but fails the Version constraint
+)
+
+// DependencyCheck records how one dependency probe went.
+type DependencyCheck struct {
+	Name       string
+	Kind       DependencyKind
+	Check      string
+	Status     CheckStatus
+	DurationMs int64
+	Version    string `json:",omitempty"` // installed version, when a constraint was checked
+	Want       string `json:",omitempty"` // the Version constraint
+	Error      string `json:",omitempty"`
 }
 
 // DetectionResult is the result of checking one capability.
 type DetectionResult struct {
-	Capability  Capability
-	Available   bool // all required deps present
-	Degraded    bool // some optional deps missing
-	MissingDeps []Dependency
+	Capability   Capability
+	Available    bool // all required deps present
+	Degraded     bool // some optional deps missing
+	MissingDeps  []Dependency
+	OutdatedDeps []Dependency      // present but too old; required ones make the capability unavailable
+	UnknownDeps  []Dependency      // deps whose kind has no checker; required ones make the capability unavailable
+	Checks       []DependencyCheck // one per dependency, in declaration order
 }
@@ -0,0 +1,109 @@
+package registry
+
+import "sort"
+
+// CategoryInfo describes a category for display.
+type CategoryInfo struct {
+	ID          Category `json:"id"`
+	Name        string   `json:"name"`
+	Order       int      `json:"order"` // groups are listed in ascending order
+	Description string   `json:"description"`
+}
+
+// CategoryOther groups capabilities whose category is not in the table.
+const CategoryOther Category = "other"
+
+// categories is the built-in category table. Manifests may use any
+// category and may describe new ones (see LoadManifests); categories
+// described nowhere are shown under Other.
+var categories = []CategoryInfo{
+	{ID: CategoryWorkspace, Name: "Workspace", Order: 10, Description: "The agent's own workspace: files, tasks, inboxes and internal state"},
+	{ID: CategoryChrome, Name: "Chrome", Order: 20, Description: "Browser, screen and page status"},
+	{ID: CategoryTelegram, Name: "Telegram", Order: 30, Description: "Telegram messaging"},
+	{ID: CategoryHeartbeat, Name: "Heartbeat", Order: 40, Description: "Periodic checks of services, repositories and the host"},
+	{ID: CategoryCommunication, Name: "Communication", Order: 50, Description: "Chat, mail and other message channels"},
+	{ID: CategoryMonitoring, Name: "Monitoring", Order: 60, Description: "Service, system and website health"},
+	{ID: CategoryVision, Name: "Vision", Order: 70, Description: "Camera and screen understanding"},
+}
+
+var otherCategory = CategoryInfo{
+	ID:          CategoryOther,
+	Name:        "Other",
+	Order:       1 << 30,
+	Description: "Capabilities in categories kuro-sense does not describe",
+}
+
+// externalCategories holds categories described by manifests.
+var externalCategories []CategoryInfo
+
+// defaultCategoryOrder places manifest categories without an order after
+// the built-in ones.
+const defaultCategoryOrder = 1000
+
+// Categories returns the known categories in display order.
+func Categories() []CategoryInfo {
+	out := append(append([]CategoryInfo(nil), categories...), externalCategories...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Order < out[j].Order })
+	return out
+}
+
+// LookupCategory returns the description of cat; unknown categories
+// report as Other.
+func LookupCategory(cat Category) CategoryInfo {
+	if c, ok := lookupCategory(cat); ok {
+		return c
+	}
+	return otherCategory
+}
+
+func lookupCategory(cat Category) (CategoryInfo, bool) {
+	if c, ok := lookupBuiltinCategory(cat); ok {
+		return c, true
+	}
+	for _, c := range externalCategories {
+		if c.ID == cat {
+			return c, true
+		}
+	}
+	return CategoryInfo{}, false
+}
+
+func lookupBuiltinCategory(cat Category) (CategoryInfo, bool) {
+	for _, c := range categories {
+		if c.ID == cat {
+			return c, true
+		}
+	}
+	return CategoryInfo{}, false
+}
+
+// Group is one category's share of a list of items.
+type Group[T any] struct {
+	Category CategoryInfo
+	Items    []T
+}
+
+// GroupByCategory groups items by category in display order, keeping the
+// item order within each group. Only categories with items are returned;
+// unknown categories are merged into a trailing Other group.
+func GroupByCategory[T any](items []T, category func(T) Category) []Group[T] {
+	byID := make(map[Category]*Group[T])
+	var groups []*Group[T]
+	for _, it := range items {
+		info := LookupCategory(category(it))
+		g := byID[info.ID]
+		if g == nil {
+			g = &Group[T]{Category: info}
+			byID[info.ID] = g
+			groups = append(groups, g)
+		}
+		g.Items = append(g.Items, it)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Category.Order < groups[j].Category.Order })
+
+	out := make([]Group[T], len(groups))
+	for i, g := range groups {
+		out[i] = *g
+	}
+	return out
+}
@@ -0,0 +1,223 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// GraphError is a problem with one capability's Requires or ConflictsWith.
+type GraphError struct {
+	Name string // the capability the problem is reported against
+	Err  error
+}
+
+func (e GraphError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+// CheckGraph validates the Requires and ConflictsWith references between
+// caps: every reference must name a known capability other than itself,
+// a capability may not both require and conflict with another, and
+// Requires may not form a cycle.
+func CheckGraph(caps []Capability) []GraphError {
+	index := make(map[string]*Capability, len(caps))
+	for i := range caps {
+		index[caps[i].Name] = &caps[i]
+	}
+
+	var errs []GraphError
+	for _, c := range caps {
+		for _, r := range c.Requires {
+			switch {
+			case r == c.Name:
+				errs = append(errs, GraphError{c.Name, fmt.Errorf("requires itself")})
+			case index[r] == nil:
+				errs = append(errs, GraphError{c.Name, fmt.Errorf("requires unknown capability %q", r)})
+			case slices.Contains(c.ConflictsWith, r):
+				errs = append(errs, GraphError{c.Name, fmt.Errorf("both requires and conflicts with %q", r)})
+			}
+		}
+		for _, x := range c.ConflictsWith {
+			switch {
+			case x == c.Name:
+				errs = append(errs, GraphError{c.Name, fmt.Errorf("conflicts with itself")})
+			case index[x] == nil:
+				errs = append(errs, GraphError{c.Name, fmt.Errorf("conflicts with unknown capability %q", x)})
+			}
+		}
+	}
+
+	// Depth-first search for Requires cycles; a cycle is reported against
+	// each capability on it.
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(caps))
+	var stack []string
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, r := range index[name].Requires {
+			if index[r] == nil {
+				continue
+			}
+			switch state[r] {
+			case visiting:
+				members := stack[slices.Index(stack, r):]
+				err := fmt.Errorf("requires cycle: %s → %s", strings.Join(members, " → "), r)
+				for _, m := range members {
+					errs = append(errs, GraphError{m, err})
+				}
+			case unvisited:
+				visit(r)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+	}
+	for _, c := range caps {
+		if state[c.Name] == unvisited {
+			visit(c.Name)
+		}
+	}
+	return errs
+}
+
+// Pull records a capability enabled only because another one requires it.
+type Pull struct {
+	Name string `json:"name"`
+	By   string `json:"by"` // the capability that requires it
+}
+
+func (p Pull) String() string {
+	return fmt.Sprintf("%s (required by %s)", p.Name, p.By)
+}
+
+// Resolution is a change to the enabled set closed over Requires.
+type Resolution struct {
+	Enable []string // the requested names followed by the pulled-in ones
+	Pulled []Pull
+}
+
+// Resolve checks a change against the dependency graph. current is the
+// set enabled now; enable and disable are the requested change. Everything
+// the enabled capabilities require, transitively, is pulled in. It is an
+// error to disable a capability something left enabled requires, or to
+// enable a capability that conflicts with another one left enabled.
+// Names not in caps (custom plugins without a manifest) are passed through.
+func Resolve(caps []Capability, current, enable, disable []string) (Resolution, error) {
+	index := make(map[string]*Capability, len(caps))
+	for i := range caps {
+		index[caps[i].Name] = &caps[i]
+	}
+
+	res := Resolution{Enable: slices.Clone(enable)}
+	on := make(map[string]bool)
+	for _, n := range current {
+		on[n] = true
+	}
+	for _, n := range disable {
+		delete(on, n)
+	}
+	added := make(map[string]bool)
+	queue := slices.Clone(enable)
+	for _, n := range enable {
+		on[n] = true
+		added[n] = true
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		c := index[name]
+		if c == nil {
+			continue
+		}
+		for _, r := range c.Requires {
+			if on[r] || slices.Contains(disable, r) {
+				continue // already enabled, or refused below
+			}
+			res.Enable = append(res.Enable, r)
+			res.Pulled = append(res.Pulled, Pull{Name: r, By: name})
+			on[r] = true
+			added[r] = true
+			queue = append(queue, r)
+		}
+	}
+
+	var errs []error
+	for _, c := range caps {
+		if !on[c.Name] {
+			continue
+		}
+		for _, r := range c.Requires {
+			if !on[r] && slices.Contains(disable, r) {
+				errs = append(errs, fmt.Errorf("%s requires %s; disable %s too or keep %s enabled", c.Name, r, c.Name, r))
+			}
+		}
+	}
+	// Only conflicts involving something newly enabled are refused, so an
+	// existing configuration can still be edited.
+	reported := make(map[[2]string]bool)
+	for _, c := range caps {
+		if !on[c.Name] {
+			continue
+		}
+		for _, x := range c.ConflictsWith {
+			if !on[x] || !added[c.Name] && !added[x] {
+				continue
+			}
+			pair := [2]string{min(c.Name, x), max(c.Name, x)}
+			if reported[pair] {
+				continue
+			}
+			reported[pair] = true
+			errs = append(errs, fmt.Errorf("%s conflicts with %s; enable only one of them", c.Name, x))
+		}
+	}
+	if len(errs) > 0 {
+		return Resolution{}, errors.Join(errs...)
+	}
+	return res, nil
+}
+
+// Conflicting returns the capabilities in caps that conflict with name, in
+// either direction.
+func Conflicting(caps []Capability, name string) []string {
+	var out []string
+	for _, c := range caps {
+		if c.Name == name {
+			for _, x := range c.ConflictsWith {
+				if !slices.Contains(out, x) {
+					out = append(out, x)
+				}
+			}
+		} else if slices.Contains(c.ConflictsWith, name) && !slices.Contains(out, c.Name) {
+			out = append(out, c.Name)
+		}
+	}
+	return out
+}
+
+// Dependents returns the capabilities in caps that require name directly.
+func Dependents(caps []Capability, name string) []string {
+	var out []string
+	for _, c := range caps {
+		if slices.Contains(c.Requires, name) {
+			out = append(out, c.Name)
+		}
+	}
+	return out
+}
+
+func init() {
+	// The built-in graph is fixed at compile time; a bad reference or a
+	// cycle there is a programming error.
+	if errs := CheckGraph(builtin()); len(errs) > 0 {
+		panic(fmt.Sprintf("registry: built-in capabilities: %v", errs[0]))
+	}
+}
@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// graph builds capabilities from "name: requires... / !conflicts..." specs.
+func graph(specs ...string) []Capability {
+	var caps []Capability
+	for _, s := range specs {
+		name, rest, _ := strings.Cut(s, ":")
+		c := Capability{Name: name}
+		for _, f := range strings.Fields(rest) {
+			if x, ok := strings.CutPrefix(f, "!"); ok {
+				c.ConflictsWith = append(c.ConflictsWith, x)
+			} else {
+				c.Requires = append(c.Requires, f)
+			}
+		}
+		caps = append(caps, c)
+	}
+	return caps
+}
+
+func TestCheckGraph(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		caps []Capability
+		want []string // "capability: error" substrings, in order
+	}{
+		{"valid", graph("a: b !c", "b: c", "c:"), nil},
+		{"requires itself", graph("a: a"), []string{"a: requires itself", "a: requires cycle: a → a"}},
+		{"conflicts with itself", graph("a: !a"), []string{"a: conflicts with itself"}},
+		{"unknown references", graph("a: x !y"), []string{`a: requires unknown capability "x"`, `a: conflicts with unknown capability "y"`}},
+		{"requires and conflicts", graph("a: b !b", "b:"), []string{`a: both requires and conflicts with "b"`}},
+		{"cycle", graph("a: b", "b: c", "c: a", "d: a"), []string{
+			"a: requires cycle: a → b → c → a",
+			"b: requires cycle: a → b → c → a",
+			"c: requires cycle: a → b → c → a",
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := CheckGraph(tt.caps)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("CheckGraph = %v, want %d error(s)", errs, len(tt.want))
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), tt.want[i]) {
+					t.Errorf("error %d = %q, want %q", i, err, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBuiltinGraph(t *testing.T) {
+	if errs := CheckGraph(builtin()); len(errs) > 0 {
+		t.Fatalf("built-in capabilities: %v", errs)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	caps := graph("a: b", "b: c", "c:", "d: !a", "e:", "f: e")
+	for _, tt := range []struct {
+		name                     string
+		current, enable, disable []string
+		wantEnable               []string
+		wantPulled               []Pull
+		wantErr                  string
+	}{
+		{
+			name:       "pulls requirements transitively",
+			enable:     []string{"a"},
+			wantEnable: []string{"a", "b", "c"},
+			wantPulled: []Pull{{"b", "a"}, {"c", "b"}},
+		},
+		{
+			name:       "already enabled is not pulled",
+			current:    []string{"c"},
+			enable:     []string{"a"},
+			wantEnable: []string{"a", "b"},
+			wantPulled: []Pull{{"b", "a"}},
+		},
+		{
+			name:       "unknown names pass through",
+			enable:     []string{"custom-plugin"},
+			wantEnable: []string{"custom-plugin"},
+		},
+		{
+			name:    "disabling a requirement",
+			current: []string{"f", "e"},
+			disable: []string{"e"},
+			wantErr: "f requires e",
+		},
+		{
+			name:       "disabling both is fine",
+			current:    []string{"f", "e"},
+			disable:    []string{"e", "f"},
+			wantEnable: []string{},
+		},
+		{
+			name:    "enabling a conflict",
+			current: []string{"a", "b", "c"},
+			enable:  []string{"d"},
+			wantErr: "conflicts with",
+		},
+		{
+			name:       "existing conflict is left alone",
+			current:    []string{"a", "b", "c", "d"},
+			enable:     []string{"e"},
+			wantEnable: []string{"e"},
+		},
+		{
+			name:    "pull refused by disable",
+			enable:  []string{"f"},
+			disable: []string{"e"},
+			wantErr: "f requires e",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Resolve(caps, tt.current, tt.enable, tt.disable)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if len(res.Enable) != 0 || len(tt.wantEnable) != 0 {
+				if !reflect.DeepEqual(res.Enable, tt.wantEnable) {
+					t.Errorf("Enable = %v, want %v", res.Enable, tt.wantEnable)
+				}
+			}
+			if !reflect.DeepEqual(res.Pulled, tt.wantPulled) {
+				t.Errorf("Pulled = %v, want %v", res.Pulled, tt.wantPulled)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package registry
+
+// systemPackages returns hints for the system package managers, given the
+// package name under each; an empty name leaves that manager out.
+func systemPackages(brew, apt, dnf, pacman, apk string) []InstallHint {
+	var hints []InstallHint
+	for _, h := range []InstallHint{
+		{Method: InstallBrew, Package: brew},
+		{Method: InstallApt, Package: apt, Platform: Platform{OS: []string{"linux"}}},
+		{Method: InstallDnf, Package: dnf, Platform: Platform{OS: []string{"linux"}}},
+		{Method: InstallPacman, Package: pacman, Platform: Platform{OS: []string{"linux"}}},
+		{Method: InstallApk, Package: apk, Platform: Platform{OS: []string{"linux"}}},
+	} {
+		if h.Package != "" {
+			hints = append(hints, h)
+		}
+	}
+	return hints
+}
+
+// samePackage returns system package hints for a package with the same
+// name everywhere.
+func samePackage(name string) []InstallHint {
+	return systemPackages(name, name, name, name, name)
+}
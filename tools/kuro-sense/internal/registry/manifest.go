@@ -0,0 +1,516 @@
+package registry
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest locations (relative to the agent directory).
+const (
+	pluginsDir       = "plugins"
+	sidecarSuffix    = ".capability.yaml"
+	frontMatterStart = "--- kuro-sense"
+	frontMatterEnd   = "---"
+	frontMatterLines = 64 // only the leading comment block is scanned
+)
+
+// external holds capabilities discovered by LoadManifests.
+var external []Capability
+
+var nameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ManifestError is a validation error for one manifest file.
+type ManifestError struct {
+	Path string
+	Err  error
+}
+
+func (e ManifestError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// manifest is the declarative form of a Capability.
+// It is read from a sidecar plugins/<name>.capability.yaml file, or from a
+// front-matter block in the leading comments of a plugin script:
+//
+//	# --- kuro-sense
+//	# name: agora-inbox
+//	# description: Agora discussion inbox
+//	# category: workspace
+//	# ---
+//
+// category may also describe a category kuro-sense does not know, so its
+// plugins get their own group instead of Other:
+//
+//	category:
+//	  id: home
+//	  name: Home
+//	  description: Lights, sensors and thermostats
+//	  order: 80
+//
+// Both YAML and JSON bodies are accepted.
+type manifest struct {
+	Name         string               `yaml:"name"`
+	Script       string               `yaml:"script"`
+	Description  string               `yaml:"description"`
+	Category     manifestCategory     `yaml:"category"`
+	Timeout      int                  `yaml:"timeout"`
+	DefaultOn    bool                 `yaml:"default_on"`
+	Tags         []string             `yaml:"tags"`
+	Requires     []string             `yaml:"requires"`
+	Conflicts    []string             `yaml:"conflicts_with"`
+	Platform     manifestPlatform     `yaml:"platform"`
+	Dependencies []manifestDependency `yaml:"dependencies"`
+}
+
+// manifestCategory is a category id, or a mapping that also describes
+// the category.
+type manifestCategory struct {
+	ID          string `yaml:"id"`
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Order       int    `yaml:"order"`
+	described   bool
+}
+
+func (c *manifestCategory) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*c = manifestCategory{ID: n.Value}
+		return nil
+	}
+	type plain manifestCategory
+	var p plain
+	if err := n.Decode(&p); err != nil {
+		return err
+	}
+	*c = manifestCategory(p)
+	c.described = true
+	return nil
+}
+
+// info returns the category description, or false if the manifest only
+// names the category.
+func (c manifestCategory) info() (CategoryInfo, bool, error) {
+	if !c.described {
+		return CategoryInfo{}, false, nil
+	}
+	if c.ID == "" {
+		return CategoryInfo{}, false, fmt.Errorf("category: id is required")
+	}
+	if c.Name == "" {
+		return CategoryInfo{}, false, fmt.Errorf("category %s: name is required", c.ID)
+	}
+	if c.Order < 0 {
+		return CategoryInfo{}, false, fmt.Errorf("category %s: order must not be negative", c.ID)
+	}
+	order := c.Order
+	if order == 0 {
+		order = defaultCategoryOrder
+	}
+	return CategoryInfo{ID: Category(c.ID), Name: c.Name, Order: order, Description: c.Description}, true, nil
+}
+
+type manifestPlatform struct {
+	OS   []string `yaml:"os"`
+	Arch []string `yaml:"arch"`
+}
+
+type manifestDependency struct {
+	Name     string           `yaml:"name"`
+	Kind     string           `yaml:"kind"`
+	Check    string           `yaml:"check"`
+	Version  string           `yaml:"version"`
+	Required bool             `yaml:"required"`
+	Install  manifestInstalls `yaml:"install"`
+	Format   *manifestFormat  `yaml:"format"`
+}
+
+type manifestFormat struct {
+	Prefix    string `yaml:"prefix"`
+	MinLength int    `yaml:"min_length"`
+	MaxLength int    `yaml:"max_length"`
+	Pattern   string `yaml:"pattern"`
+	Hint      string `yaml:"hint"`
+}
+
+type manifestInstall struct {
+	Method  string   `yaml:"method"`
+	Package string   `yaml:"package"`
+	Command string   `yaml:"command"`
+	SHA256  string   `yaml:"sha256"`
+	OS      []string `yaml:"os"`
+	Arch    []string `yaml:"arch"`
+}
+
+// manifestInstalls is a list of install hints; a single mapping is
+// accepted as a one-item list.
+type manifestInstalls []manifestInstall
+
+func (m *manifestInstalls) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.MappingNode {
+		var one manifestInstall
+		if err := n.Decode(&one); err != nil {
+			return err
+		}
+		*m = manifestInstalls{one}
+		return nil
+	}
+	var list []manifestInstall
+	if err := n.Decode(&list); err != nil {
+		return err
+	}
+	*m = list
+	return nil
+}
+
+// LoadManifests discovers capability manifests under agentDir/plugins and
+// makes them part of All(), and the categories they describe part of
+// Categories(). A manifest replaces a built-in capability with the same
+// name, but cannot redescribe a built-in category. Invalid manifests are
+// skipped and reported per file.
+func LoadManifests(agentDir string) []ManifestError {
+	dir := filepath.Join(agentDir, pluginsDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		external, externalCategories = nil, nil
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return []ManifestError{{Path: dir, Err: err}}
+	}
+
+	var caps []Capability
+	var cats []CategoryInfo
+	var errs []ManifestError
+	seen := make(map[string]string)       // capability name → manifest path
+	seenCats := make(map[Category]string) // described category → manifest path
+	sidecars := make(map[string]bool)
+
+	add := func(path string, m *manifest, defaultScript string) {
+		cap, err := m.toCapability(defaultScript)
+		if err != nil {
+			errs = append(errs, ManifestError{Path: path, Err: err})
+			return
+		}
+		if prev, ok := seen[cap.Name]; ok {
+			errs = append(errs, ManifestError{Path: path, Err: fmt.Errorf("capability %q already defined in %s", cap.Name, prev)})
+			return
+		}
+		info, described, err := m.Category.info()
+		if err != nil {
+			errs = append(errs, ManifestError{Path: path, Err: err})
+			return
+		}
+		if described {
+			if _, builtin := lookupBuiltinCategory(info.ID); builtin || info.ID == CategoryOther {
+				errs = append(errs, ManifestError{Path: path, Err: fmt.Errorf("category %q is built in and cannot be redescribed", info.ID)})
+				return
+			}
+			if prev, ok := seenCats[info.ID]; ok {
+				errs = append(errs, ManifestError{Path: path, Err: fmt.Errorf("category %q already described in %s", info.ID, prev)})
+				return
+			}
+			seenCats[info.ID] = path
+			cats = append(cats, info)
+		}
+		seen[cap.Name] = path
+		caps = append(caps, cap)
+	}
+
+	// Sidecar manifests take precedence over front-matter in the script.
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), sidecarSuffix) {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		script := strings.TrimSuffix(e.Name(), sidecarSuffix) + ".sh"
+		sidecars[script] = true
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, ManifestError{Path: path, Err: err})
+			continue
+		}
+		m, err := parseManifest(data)
+		if err != nil {
+			errs = append(errs, ManifestError{Path: path, Err: err})
+			continue
+		}
+		add(path, m, "./"+pluginsDir+"/"+script)
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sh") || sidecars[e.Name()] {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		body, found, err := readFrontMatter(path)
+		if err != nil {
+			errs = append(errs, ManifestError{Path: path, Err: err})
+			continue
+		}
+		if !found {
+			continue
+		}
+		m, err := parseManifest(body)
+		if err != nil {
+			errs = append(errs, ManifestError{Path: path, Err: err})
+			continue
+		}
+		add(path, m, "./"+pluginsDir+"/"+e.Name())
+	}
+
+	sort.Slice(caps, func(i, j int) bool { return caps[i].Name < caps[j].Name })
+	external = caps
+	sort.SliceStable(cats, func(i, j int) bool { return cats[i].ID < cats[j].ID })
+	externalCategories = cats
+
+	// Requires and ConflictsWith may point at capabilities from other
+	// manifests, so the graph is checked once everything is merged. A
+	// manifest with a bad reference or on a requires cycle is dropped,
+	// which may break references to it in turn.
+	for {
+		graphErrs := CheckGraph(All())
+		if len(graphErrs) == 0 {
+			break
+		}
+		drop := make(map[string]bool)
+		for _, ge := range graphErrs {
+			path, ok := seen[ge.Name]
+			if !ok {
+				path = "registry"
+			}
+			errs = append(errs, ManifestError{Path: path, Err: ge})
+			drop[ge.Name] = true
+		}
+		kept := external[:0]
+		for _, c := range external {
+			if !drop[c.Name] {
+				kept = append(kept, c)
+			}
+		}
+		if len(kept) == len(external) {
+			break // only built-ins are involved; nothing left to drop
+		}
+		external = kept
+	}
+	return errs
+}
+
+// readFrontMatter extracts the "# --- kuro-sense" block from a script's
+// leading comments, with the comment markers stripped.
+func readFrontMatter(path string) ([]byte, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	var body bytes.Buffer
+	inBlock := false
+	sc := bufio.NewScanner(f)
+	for n := 0; sc.Scan() && n < frontMatterLines; n++ {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "#") {
+			if inBlock {
+				return nil, false, fmt.Errorf("unterminated front-matter block")
+			}
+			continue
+		}
+		text := strings.TrimPrefix(line, "#")
+		if !inBlock {
+			if strings.TrimSpace(text) == frontMatterStart {
+				inBlock = true
+			}
+			continue
+		}
+		if strings.TrimSpace(text) == frontMatterEnd {
+			return body.Bytes(), true, nil
+		}
+		body.WriteString(strings.TrimPrefix(text, " "))
+		body.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return nil, false, err
+	}
+	if inBlock {
+		return nil, false, fmt.Errorf("unterminated front-matter block")
+	}
+	return nil, false, nil
+}
+
+func parseManifest(data []byte) (*manifest, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var m manifest
+	if err := dec.Decode(&m); err != nil {
+		// Flatten multi-line unmarshal errors so each file reports on one line.
+		if te, ok := err.(*yaml.TypeError); ok {
+			return nil, fmt.Errorf("parse manifest: %s", strings.Join(te.Errors, "; "))
+		}
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	return &m, nil
+}
+
+func (m *manifest) toCapability(defaultScript string) (Capability, error) {
+	if m.Name == "" {
+		return Capability{}, fmt.Errorf("name is required")
+	}
+	if !nameRe.MatchString(m.Name) {
+		return Capability{}, fmt.Errorf("invalid name %q (use lowercase letters, digits and dashes)", m.Name)
+	}
+	if m.Timeout < 0 {
+		return Capability{}, fmt.Errorf("timeout must not be negative")
+	}
+
+	cat := Category(m.Category.ID)
+	if cat == "" && !m.Category.described {
+		cat = CategoryHeartbeat
+	}
+	// Any category is accepted; ones described neither in the category
+	// table nor by a manifest are listed under Other.
+	if !nameRe.MatchString(string(cat)) {
+		return Capability{}, fmt.Errorf("invalid category %q (use lowercase letters, digits and dashes)", cat)
+	}
+
+	script := m.Script
+	if script == "" {
+		script = defaultScript
+	}
+
+	cap := Capability{
+		Name:          m.Name,
+		Script:        script,
+		Description:   m.Description,
+		Category:      cat,
+		Platform:      Platform{OS: m.Platform.OS, Arch: m.Platform.Arch},
+		Timeout:       m.Timeout,
+		DefaultOn:     m.DefaultOn,
+		Tags:          m.Tags,
+		Requires:      m.Requires,
+		ConflictsWith: m.Conflicts,
+	}
+
+	for i, d := range m.Dependencies {
+		if d.Name == "" {
+			return Capability{}, fmt.Errorf("dependencies[%d]: name is required", i)
+		}
+		// Kinds are open-ended: one without a checker is reported as
+		// "unknown" at detection time rather than rejected here.
+		kind := DependencyKind(d.Kind)
+		if kind == "" {
+			return Capability{}, fmt.Errorf("dependency %s: kind is required", d.Name)
+		}
+		check := d.Check
+		if check == "" {
+			check = d.Name
+		}
+		if d.Version != "" {
+			if _, err := ParseVersionConstraint(d.Version); err != nil {
+				return Capability{}, fmt.Errorf("dependency %s: %w", d.Name, err)
+			}
+		}
+		var hints []InstallHint
+		for _, in := range d.Install {
+			hint, err := in.toHint()
+			if err != nil {
+				return Capability{}, fmt.Errorf("dependency %s: %w", d.Name, err)
+			}
+			hints = append(hints, hint)
+		}
+		var format SecretFormat
+		if d.Format != nil {
+			if kind != KindEnvVar {
+				return Capability{}, fmt.Errorf("dependency %s: format only applies to envvar dependencies", d.Name)
+			}
+			f, err := d.Format.toFormat()
+			if err != nil {
+				return Capability{}, fmt.Errorf("dependency %s: %w", d.Name, err)
+			}
+			format = f
+		}
+		cap.Dependencies = append(cap.Dependencies, Dependency{
+			Name:     d.Name,
+			Kind:     kind,
+			Check:    check,
+			Version:  d.Version,
+			Required: d.Required,
+			Install:  hints,
+			Format:   format,
+		})
+	}
+
+	return cap, nil
+}
+
+func (f manifestFormat) toFormat() (SecretFormat, error) {
+	if f.MinLength < 0 || f.MaxLength < 0 || (f.MaxLength > 0 && f.MinLength > f.MaxLength) {
+		return SecretFormat{}, fmt.Errorf("format: bad length range %d-%d", f.MinLength, f.MaxLength)
+	}
+	if f.Pattern != "" {
+		if _, err := regexp.Compile(f.Pattern); err != nil {
+			return SecretFormat{}, fmt.Errorf("format: bad pattern: %w", err)
+		}
+	}
+	return SecretFormat{
+		Prefix:  f.Prefix,
+		MinLen:  f.MinLength,
+		MaxLen:  f.MaxLength,
+		Pattern: f.Pattern,
+		Hint:    f.Hint,
+	}, nil
+}
+
+var sha256Re = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func (in manifestInstall) toHint() (InstallHint, error) {
+	method := InstallMethod(in.Method)
+	if method == "" && in.Command != "" {
+		method = InstallManual
+	}
+	if !knownInstallMethod(method) {
+		return InstallHint{}, fmt.Errorf("unknown install method %q", in.Method)
+	}
+	switch method {
+	case InstallManual:
+		if in.Command == "" {
+			return InstallHint{}, fmt.Errorf("manual install needs a command")
+		}
+	case InstallCurl:
+		if !strings.HasPrefix(in.Package, "https://") {
+			return InstallHint{}, fmt.Errorf("curl install needs an https:// URL as package")
+		}
+		if !sha256Re.MatchString(strings.ToLower(in.SHA256)) {
+			return InstallHint{}, fmt.Errorf("curl install needs the sha256 of the download")
+		}
+	default:
+		if in.Package == "" {
+			return InstallHint{}, fmt.Errorf("%s install needs a package", method)
+		}
+	}
+	return InstallHint{
+		Method:   method,
+		Package:  in.Package,
+		Command:  in.Command,
+		SHA256:   strings.ToLower(in.SHA256),
+		Platform: Platform{OS: in.OS, Arch: in.Arch},
+	}, nil
+}
+
+func knownInstallMethod(m InstallMethod) bool {
+	switch m {
+	case InstallBrew, InstallApt, InstallDnf, InstallPacman, InstallApk, InstallCurl,
+		InstallPip, InstallNpm, InstallGo, InstallDocker, InstallManual:
+		return true
+	}
+	return false
+}
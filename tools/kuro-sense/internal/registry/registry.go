@@ -1,16 +1,41 @@
 package registry
 
-// All returns the complete list of known capabilities (27 plugins).
+import "sort"
+
+// All returns the complete list of known capabilities: the built-in
+// definitions merged with manifests discovered by LoadManifests.
 func All() []Capability {
+	caps := builtin()
+	if len(external) == 0 {
+		return caps
+	}
+
+	index := make(map[string]int, len(caps))
+	for i, c := range caps {
+		index[c.Name] = i
+	}
+	for _, c := range external {
+		if i, ok := index[c.Name]; ok {
+			caps[i] = c // manifest overrides the built-in definition
+			continue
+		}
+		caps = append(caps, c)
+	}
+	return caps
+}
+
+// builtin returns the capabilities compiled into kuro-sense (27 plugins).
+func builtin() []Capability {
 	return []Capability{
 		// ── workspace ──
 		{
 			Name: "state-changes", Script: "./plugins/state-watcher.sh",
 			Description: "Workspace state changes (files, processes, git)",
 			Category: CategoryWorkspace, DefaultOn: true,
+			Tags: []string{TagGit},
 			Dependencies: []Dependency{
-				{Name: "docker", Kind: KindBinary, Check: "docker", Required: false, Install: InstallHint{Method: InstallBrew, Package: "docker"}},
-				{Name: "git", Kind: KindBinary, Check: "git", Required: false, Install: InstallHint{Method: InstallBrew, Package: "git"}},
+				{Name: "docker", Kind: KindBinary, Check: "docker", Required: false, Install: systemPackages("docker", "docker.io", "moby-engine", "docker", "docker")},
+				{Name: "git", Kind: KindBinary, Check: "git", Required: false, Install: samePackage("git")},
 				{Name: "lsof", Kind: KindBinary, Check: "lsof", Required: false},
 			},
 		},
@@ -19,13 +44,14 @@ func All() []Capability {
 			Description: "Task tracking from HEARTBEAT.md",
 			Category: CategoryWorkspace, DefaultOn: true,
 			Dependencies: []Dependency{
-				{Name: "git", Kind: KindBinary, Check: "git", Required: false, Install: InstallHint{Method: InstallBrew, Package: "git"}},
+				{Name: "git", Kind: KindBinary, Check: "git", Required: false, Install: samePackage("git")},
 			},
 		},
 		{
 			Name: "focus-context", Script: "./plugins/focus-context.sh",
 			Description: "Current app focus context (macOS)",
 			Category: CategoryWorkspace, DefaultOn: true,
+			Tags: []string{TagMacOS, TagPrivacySensitive},
 			Platform: Platform{OS: []string{"darwin"}},
 			Dependencies: []Dependency{
 				{Name: "osascript", Kind: KindBinary, Check: "osascript", Required: true},
@@ -35,28 +61,33 @@ func All() []Capability {
 			Name: "mobile", Script: "./plugins/mobile-perception.sh",
 			Description: "Mobile sensor data (GPS, accelerometer)",
 			Category: CategoryWorkspace, DefaultOn: true,
+			Tags: []string{TagPrivacySensitive},
 			Dependencies: []Dependency{
-				{Name: "jq", Kind: KindBinary, Check: "jq", Required: true, Install: InstallHint{Method: InstallBrew, Package: "jq"}},
+				{Name: "jq", Kind: KindBinary, Check: "jq", Required: true, Install: samePackage("jq")},
 				{Name: "curl", Kind: KindBinary, Check: "curl", Required: false},
-				{Name: "python3", Kind: KindBinary, Check: "python3", Required: false, Install: InstallHint{Method: InstallBrew, Package: "python@3.11"}},
+				{Name: "python3", Kind: KindBinary, Check: "python3", Version: ">=3.10", Required: false, Install: systemPackages("python@3.11", "python3", "python3", "python", "python3")},
 			},
 		},
 		{
 			Name: "claude-code-inbox", Script: "./plugins/claude-code-inbox.sh",
 			Description: "Claude Code message inbox",
 			Category: CategoryWorkspace, DefaultOn: true,
+			Tags: []string{TagInbox},
 		},
 		{
 			Name: "chat-room-inbox", Script: "./plugins/chat-room-inbox.sh",
 			Description: "Chat room message inbox",
 			Category: CategoryWorkspace, DefaultOn: true,
+			Tags: []string{TagInbox},
 		},
 		{
 			Name: "git-detail", Script: "./plugins/git-status.sh",
 			Description: "Detailed git status and recent commits",
 			Category: CategoryWorkspace, DefaultOn: false,
+			Tags: []string{TagGit},
+			ConflictsWith: []string{"state-changes"}, // state-changes already reports git status
 			Dependencies: []Dependency{
-				{Name: "git", Kind: KindBinary, Check: "git", Required: true, Install: InstallHint{Method: InstallBrew, Package: "git"}},
+				{Name: "git", Kind: KindBinary, Check: "git", Required: true, Install: samePackage("git")},
 			},
 		},
 
@@ -65,19 +96,21 @@ func All() []Capability {
 			Name: "chrome", Script: "./plugins/chrome-status.sh",
 			Description: "Chrome browser tab and page status",
 			Category: CategoryChrome, DefaultOn: true,
+			Tags: []string{TagPrivacySensitive},
 			Dependencies: []Dependency{
 				{Name: "curl", Kind: KindBinary, Check: "curl", Required: true},
-				{Name: "python3", Kind: KindBinary, Check: "python3", Required: true, Install: InstallHint{Method: InstallBrew, Package: "python@3.11"}},
+				{Name: "python3", Kind: KindBinary, Check: "python3", Required: true, Install: systemPackages("python@3.11", "python3", "python3", "python", "python3")},
 			},
 		},
 		{
 			Name: "web", Script: "./plugins/web-fetch.sh",
 			Description: "Web page fetching via CDP + curl + Jina + Grok",
 			Category: CategoryChrome, DefaultOn: true, Timeout: 15000,
+			Tags: []string{TagNetwork, TagLLMCost},
 			Dependencies: []Dependency{
 				{Name: "curl", Kind: KindBinary, Check: "curl", Required: true},
 				{Name: "node", Kind: KindBinary, Check: "node", Required: false},
-				{Name: "XAI_API_KEY", Kind: KindEnvVar, Check: "XAI_API_KEY", Required: false},
+				{Name: "XAI_API_KEY", Kind: KindEnvVar, Check: "XAI_API_KEY", Required: false, Format: xaiKeyFormat},
 				{Name: "internet", Kind: KindNetwork, Check: "internet", Required: false},
 			},
 		},
@@ -85,9 +118,10 @@ func All() []Capability {
 			Name: "screen-vision", Script: "./plugins/screen-vision.sh",
 			Description: "Screen OCR via ocrmac",
 			Category: CategoryChrome, DefaultOn: false,
+			Tags: []string{TagMacOS, TagPrivacySensitive},
 			Dependencies: []Dependency{
 				{Name: "curl", Kind: KindBinary, Check: "curl", Required: true},
-				{Name: "ocrmac", Kind: KindPython, Check: "ocrmac", Required: true, Install: InstallHint{Method: InstallPip, Package: "ocrmac"}},
+				{Name: "ocrmac", Kind: KindPython, Check: "ocrmac", Required: true, Install: []InstallHint{{Method: InstallPip, Package: "ocrmac", Platform: Platform{OS: []string{"darwin"}}}}},
 				{Name: "camera", Kind: KindHardware, Check: "camera", Required: false},
 				{Name: "display", Kind: KindHardware, Check: "display", Required: true},
 			},
@@ -98,8 +132,9 @@ func All() []Capability {
 			Name: "telegram-inbox", Script: "./plugins/telegram-inbox.sh",
 			Description: "Telegram message inbox",
 			Category: CategoryTelegram, DefaultOn: true,
+			Tags: []string{TagInbox, TagNetwork},
 			Dependencies: []Dependency{
-				{Name: "TELEGRAM_BOT_TOKEN", Kind: KindEnvVar, Check: "TELEGRAM_BOT_TOKEN", Required: true},
+				{Name: "TELEGRAM_BOT_TOKEN", Kind: KindEnvVar, Check: "TELEGRAM_BOT_TOKEN", Required: true, Format: telegramTokenFormat},
 				{Name: "Telegram API", Kind: KindNetwork, Check: "api.telegram.org:443", Required: false},
 			},
 		},
@@ -109,16 +144,19 @@ func All() []Capability {
 			Name: "docker", Script: "./plugins/docker-status.sh",
 			Description: "Docker container status",
 			Category: CategoryHeartbeat, DefaultOn: false,
+			Tags: []string{TagDocker},
 			Dependencies: []Dependency{
-				{Name: "docker", Kind: KindBinary, Check: "docker", Required: true, Install: InstallHint{Method: InstallBrew, Package: "docker"}},
+				{Name: "docker", Kind: KindBinary, Check: "docker", Required: true, Install: systemPackages("docker", "docker.io", "moby-engine", "docker", "docker")},
 			},
 		},
 		{
 			Name: "docker-services", Script: "./plugins/docker-services.sh",
 			Description: "Docker service health checks",
 			Category: CategoryHeartbeat, DefaultOn: true,
+			Tags: []string{TagDocker},
+			Requires: []string{"docker"},
 			Dependencies: []Dependency{
-				{Name: "docker", Kind: KindBinary, Check: "docker", Required: true, Install: InstallHint{Method: InstallBrew, Package: "docker"}},
+				{Name: "docker", Kind: KindBinary, Check: "docker", Required: true, Install: systemPackages("docker", "docker.io", "moby-engine", "docker", "docker")},
 				{Name: "curl", Kind: KindBinary, Check: "curl", Required: false},
 			},
 		},
@@ -126,9 +164,10 @@ func All() []Capability {
 			Name: "github-issues", Script: "./plugins/github-issues.sh",
 			Description: "GitHub issues tracking",
 			Category: CategoryHeartbeat, DefaultOn: true,
+			Tags: []string{TagGitHub, TagNetwork},
 			Dependencies: []Dependency{
-				{Name: "gh", Kind: KindBinary, Check: "gh", Required: true, Install: InstallHint{Method: InstallBrew, Package: "gh"}},
-				{Name: "jq", Kind: KindBinary, Check: "jq", Required: true, Install: InstallHint{Method: InstallBrew, Package: "jq"}},
+				{Name: "gh", Kind: KindBinary, Check: "gh", Required: true, Install: systemPackages("gh", "gh", "gh", "github-cli", "github-cli")},
+				{Name: "jq", Kind: KindBinary, Check: "jq", Required: true, Install: samePackage("jq")},
 				{Name: "GitHub API", Kind: KindNetwork, Check: "api.github.com:443", Required: false},
 			},
 		},
@@ -136,9 +175,11 @@ func All() []Capability {
 			Name: "github-prs", Script: "./plugins/github-prs.sh",
 			Description: "GitHub pull requests tracking",
 			Category: CategoryHeartbeat, DefaultOn: true,
+			Tags: []string{TagGitHub, TagNetwork},
+			Requires: []string{"github-issues"},
 			Dependencies: []Dependency{
-				{Name: "gh", Kind: KindBinary, Check: "gh", Required: true, Install: InstallHint{Method: InstallBrew, Package: "gh"}},
-				{Name: "jq", Kind: KindBinary, Check: "jq", Required: true, Install: InstallHint{Method: InstallBrew, Package: "jq"}},
+				{Name: "gh", Kind: KindBinary, Check: "gh", Required: true, Install: systemPackages("gh", "gh", "gh", "github-cli", "github-cli")},
+				{Name: "jq", Kind: KindBinary, Check: "jq", Required: true, Install: samePackage("jq")},
 				{Name: "GitHub API", Kind: KindNetwork, Check: "api.github.com:443", Required: false},
 			},
 		},
@@ -146,10 +187,11 @@ func All() []Capability {
 			Name: "x-feed", Script: "./plugins/x-perception.sh",
 			Description: "X/Twitter feed via Grok API",
 			Category: CategoryHeartbeat, DefaultOn: true, Timeout: 35000,
+			Tags: []string{TagNetwork, TagLLMCost},
 			Dependencies: []Dependency{
 				{Name: "curl", Kind: KindBinary, Check: "curl", Required: true},
-				{Name: "jq", Kind: KindBinary, Check: "jq", Required: true, Install: InstallHint{Method: InstallBrew, Package: "jq"}},
-				{Name: "XAI_API_KEY", Kind: KindEnvVar, Check: "XAI_API_KEY", Required: true},
+				{Name: "jq", Kind: KindBinary, Check: "jq", Required: true, Install: samePackage("jq")},
+				{Name: "XAI_API_KEY", Kind: KindEnvVar, Check: "XAI_API_KEY", Required: true, Format: xaiKeyFormat},
 				{Name: "xAI API", Kind: KindNetwork, Check: "api.x.ai:443", Required: false},
 			},
 		},
@@ -162,10 +204,11 @@ func All() []Capability {
 			Name: "self-healing", Script: "./plugins/self-healing.sh",
 			Description: "Auto-detect and repair system issues",
 			Category: CategoryHeartbeat, DefaultOn: true,
+			Tags: []string{TagSystem},
 			Dependencies: []Dependency{
 				{Name: "curl", Kind: KindBinary, Check: "curl", Required: false},
-				{Name: "docker", Kind: KindBinary, Check: "docker", Required: false, Install: InstallHint{Method: InstallBrew, Package: "docker"}},
-				{Name: "git", Kind: KindBinary, Check: "git", Required: false, Install: InstallHint{Method: InstallBrew, Package: "git"}},
+				{Name: "docker", Kind: KindBinary, Check: "docker", Required: false, Install: systemPackages("docker", "docker.io", "moby-engine", "docker", "docker")},
+				{Name: "git", Kind: KindBinary, Check: "git", Required: false, Install: samePackage("git")},
 			},
 		},
 		{
@@ -182,6 +225,7 @@ func All() []Capability {
 			Name: "website", Script: "./plugins/website-monitor.sh",
 			Description: "Website monitoring and health check",
 			Category: CategoryHeartbeat, DefaultOn: true, Timeout: 15000,
+			Tags: []string{TagNetwork},
 			Dependencies: []Dependency{
 				{Name: "curl", Kind: KindBinary, Check: "curl", Required: true},
 				{Name: "internet", Kind: KindNetwork, Check: "internet", Required: false},
@@ -193,11 +237,13 @@ func All() []Capability {
 			Name: "disk", Script: "./plugins/disk-usage.sh",
 			Description: "Disk usage monitoring",
 			Category: CategoryHeartbeat, DefaultOn: false,
+			Tags: []string{TagSystem},
 		},
 		{
 			Name: "brew", Script: "./plugins/homebrew-outdated.sh",
 			Description: "Homebrew outdated packages",
 			Category: CategoryHeartbeat, DefaultOn: false, Timeout: 10000,
+			Tags: []string{TagMacOS, TagSystem},
 			Platform: Platform{OS: []string{"darwin"}},
 			Dependencies: []Dependency{
 				{Name: "brew", Kind: KindBinary, Check: "brew", Required: true},
@@ -207,6 +253,7 @@ func All() []Capability {
 			Name: "ports", Script: "./plugins/port-check.sh",
 			Description: "Network port status check",
 			Category: CategoryHeartbeat, DefaultOn: false,
+			Tags: []string{TagSystem},
 			Dependencies: []Dependency{
 				{Name: "lsof", Kind: KindBinary, Check: "lsof", Required: false},
 			},
@@ -220,8 +267,10 @@ func All() []Capability {
 			Name: "lighthouse-audit", Script: "./plugins/lighthouse-audit.sh",
 			Description: "Lighthouse web audit",
 			Category: CategoryHeartbeat, DefaultOn: false,
+			Tags: []string{TagNetwork},
 			Dependencies: []Dependency{
-				{Name: "lighthouse", Kind: KindBinary, Check: "lighthouse", Required: true, Install: InstallHint{Method: InstallManual, Command: "npm install -g lighthouse"}},
+				{Name: "node", Kind: KindBinary, Check: "node", Version: ">=18", Required: true, Install: systemPackages("node", "nodejs", "nodejs", "nodejs", "nodejs")},
+				{Name: "lighthouse", Kind: KindBinary, Check: "lighthouse", Required: true, Install: []InstallHint{{Method: InstallNpm, Package: "lighthouse"}}},
 			},
 		},
 		{
@@ -252,3 +301,46 @@ func ByCategory(cat Category) []Capability {
 	}
 	return result
 }
+
+// HasAnyTag reports whether c carries at least one of tags.
+func (c Capability) HasAnyTag(tags []string) bool {
+	for _, t := range tags {
+		for _, ct := range c.Tags {
+			if ct == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FilterByTags returns the capabilities carrying at least one of tags.
+// No tags means no filtering.
+func FilterByTags(caps []Capability, tags []string) []Capability {
+	if len(tags) == 0 {
+		return caps
+	}
+	var result []Capability
+	for _, c := range caps {
+		if c.HasAnyTag(tags) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// AllTags returns every tag used by caps, sorted.
+func AllTags(caps []Capability) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, c := range caps {
+		for _, t := range c.Tags {
+			if !seen[t] {
+				seen[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
@@ -0,0 +1,122 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// SecretFormat describes what a valid value of an environment variable
+// dependency looks like. The zero value accepts any non-empty value.
+type SecretFormat struct {
+	Prefix  string // value must start with this
+	MinLen  int    // minimum length in characters; 0 = no minimum
+	MaxLen  int    // maximum length in characters; 0 = no maximum
+	Pattern string // regular expression the whole value must match
+	Hint    string // where the value comes from, shown when it is asked for
+}
+
+// IsZero reports whether f places no constraint on the value.
+func (f SecretFormat) IsZero() bool {
+	return f == SecretFormat{}
+}
+
+// Validate checks value against f. Errors never include the value.
+func (f SecretFormat) Validate(value string) error {
+	if value == "" {
+		return fmt.Errorf("value is empty")
+	}
+	if f.Prefix != "" && !strings.HasPrefix(value, f.Prefix) {
+		return fmt.Errorf("value should start with %q", f.Prefix)
+	}
+	n := utf8.RuneCountInString(value)
+	if f.MinLen > 0 && n < f.MinLen {
+		return fmt.Errorf("value is %d characters, expected at least %d", n, f.MinLen)
+	}
+	if f.MaxLen > 0 && n > f.MaxLen {
+		return fmt.Errorf("value is %d characters, expected at most %d", n, f.MaxLen)
+	}
+	if f.Pattern != "" {
+		re, err := regexp.Compile("^(?:" + f.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("bad format pattern: %w", err)
+		}
+		if !re.MatchString(value) {
+			if f.Hint != "" {
+				return fmt.Errorf("value does not look right (%s)", f.Hint)
+			}
+			return fmt.Errorf("value does not match %s", f.Pattern)
+		}
+	}
+	return nil
+}
+
+// Describe summarizes f for display, e.g. `starts with "xai-", 20+ chars`.
+func (f SecretFormat) Describe() string {
+	var parts []string
+	if f.Prefix != "" {
+		parts = append(parts, fmt.Sprintf("starts with %q", f.Prefix))
+	}
+	switch {
+	case f.MinLen > 0 && f.MaxLen > 0 && f.MinLen == f.MaxLen:
+		parts = append(parts, fmt.Sprintf("%d chars", f.MinLen))
+	case f.MinLen > 0 && f.MaxLen > 0:
+		parts = append(parts, fmt.Sprintf("%d-%d chars", f.MinLen, f.MaxLen))
+	case f.MinLen > 0:
+		parts = append(parts, fmt.Sprintf("%d+ chars", f.MinLen))
+	case f.MaxLen > 0:
+		parts = append(parts, fmt.Sprintf("up to %d chars", f.MaxLen))
+	}
+	if f.Hint != "" {
+		parts = append(parts, f.Hint)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// EnvVar is an environment variable some capabilities depend on.
+type EnvVar struct {
+	Name         string
+	Format       SecretFormat
+	Required     bool     // required by at least one capability
+	Capabilities []string // capabilities that use it, in registry order
+}
+
+// EnvVars collects the environment variable dependencies of caps, once per
+// variable. The first dependency with a format supplies it.
+func EnvVars(caps []Capability) []EnvVar {
+	index := make(map[string]int)
+	var out []EnvVar
+	for _, c := range caps {
+		for _, d := range c.Dependencies {
+			if d.Kind != KindEnvVar {
+				continue
+			}
+			i, ok := index[d.Check]
+			if !ok {
+				i = len(out)
+				index[d.Check] = i
+				out = append(out, EnvVar{Name: d.Check})
+			}
+			v := &out[i]
+			if v.Format.IsZero() {
+				v.Format = d.Format
+			}
+			v.Required = v.Required || d.Required
+			v.Capabilities = append(v.Capabilities, c.Name)
+		}
+	}
+	return out
+}
+
+// Formats of the credentials built-in capabilities use.
+var (
+	xaiKeyFormat = SecretFormat{
+		Prefix: "xai-", MinLen: 20,
+		Hint: "API key from console.x.ai",
+	}
+	telegramTokenFormat = SecretFormat{
+		Pattern: `[0-9]+:[A-Za-z0-9_-]{30,}`,
+		Hint:    "<bot id>:<secret> from @BotFather",
+	}
+)
@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var versionRe = regexp.MustCompile(`\d+(\.\d+)*`)
+
+// VersionConstraint is a parsed Dependency.Version such as ">=3.10" or
+// ">=18, <23". All comparisons must hold.
+type VersionConstraint []versionComparison
+
+type versionComparison struct {
+	op      string // ">=", ">", "<=", "<", "="
+	version []int
+}
+
+// ParseVersionConstraint parses a comma-separated list of comparisons.
+// A bare version ("3.10") means ">=".
+func ParseVersionConstraint(s string) (VersionConstraint, error) {
+	var c VersionConstraint
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		op := ">="
+		for _, candidate := range []string{">=", "<=", "==", ">", "<", "="} {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				part = strings.TrimSpace(part[len(candidate):])
+				break
+			}
+		}
+		if op == "==" {
+			op = "="
+		}
+		raw := strings.TrimPrefix(part, "v")
+		v, ok := parseVersion(raw)
+		if !ok || ExtractVersion(raw) != raw {
+			return nil, fmt.Errorf("invalid version %q in constraint %q", part, s)
+		}
+		c = append(c, versionComparison{op: op, version: v})
+	}
+	if len(c) == 0 {
+		return nil, fmt.Errorf("empty version constraint")
+	}
+	return c, nil
+}
+
+// Allows reports whether version satisfies every comparison. Versions are
+// compared numerically per component; missing components count as zero.
+func (c VersionConstraint) Allows(version string) bool {
+	v, ok := parseVersion(version)
+	if !ok {
+		return false
+	}
+	for _, cmp := range c {
+		d := compareVersions(v, cmp.version)
+		var pass bool
+		switch cmp.op {
+		case ">=":
+			pass = d >= 0
+		case ">":
+			pass = d > 0
+		case "<=":
+			pass = d <= 0
+		case "<":
+			pass = d < 0
+		case "=":
+			pass = d == 0
+		}
+		if !pass {
+			return false
+		}
+	}
+	return true
+}
+
+// ExtractVersion returns the first dotted version number in s, e.g.
+// "Python 3.11.4" → "3.11.4", "v20.11.0" → "20.11.0".
+func ExtractVersion(s string) string {
+	return versionRe.FindString(s)
+}
+
+func parseVersion(s string) ([]int, bool) {
+	s = ExtractVersion(s)
+	if s == "" {
+		return nil, false
+	}
+	parts := strings.Split(s, ".")
+	v := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
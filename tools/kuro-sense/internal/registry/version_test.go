@@ -0,0 +1,71 @@
+package registry
+
+import "testing"
+
+func TestParseVersionConstraint(t *testing.T) {
+	for _, tt := range []struct {
+		constraint string
+		allows     []string
+		rejects    []string
+	}{
+		{">=3.10", []string{"3.10", "3.10.0", "3.11.4", "4"}, []string{"3.9", "3.9.18", "2"}},
+		{"3.10", []string{"3.10", "3.12"}, []string{"3.9"}},
+		{">=18, <23", []string{"18.0.0", "v20.11.0", "22.99"}, []string{"17.9", "23", "23.0.1"}},
+		{">1.2", []string{"1.2.1", "1.3"}, []string{"1.2", "1.2.0", "1.1"}},
+		{"<=2", []string{"2", "2.0.0", "1.9"}, []string{"2.0.1"}},
+		{"==1.4", []string{"1.4", "1.4.0"}, []string{"1.4.1", "1.3"}},
+		{"=v2.1", []string{"2.1"}, []string{"2.2"}},
+		{" >= 1 , , < 2 ", []string{"1.5"}, []string{"2.0"}},
+	} {
+		c, err := ParseVersionConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParseVersionConstraint(%q): %v", tt.constraint, err)
+			continue
+		}
+		for _, v := range tt.allows {
+			if !c.Allows(v) {
+				t.Errorf("%q does not allow %q", tt.constraint, v)
+			}
+		}
+		for _, v := range tt.rejects {
+			if c.Allows(v) {
+				t.Errorf("%q allows %q", tt.constraint, v)
+			}
+		}
+	}
+}
+
+func TestParseVersionConstraintErrors(t *testing.T) {
+	for _, s := range []string{"", " , ", ">=", ">=abc", ">=3.x", "~1.2", ">=1.2-beta", ">= 1 2"} {
+		if c, err := ParseVersionConstraint(s); err == nil {
+			t.Errorf("ParseVersionConstraint(%q) = %v, want an error", s, c)
+		}
+	}
+}
+
+func TestAllowsUnparsableVersion(t *testing.T) {
+	c, err := ParseVersionConstraint(">=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"", "unknown", "vX"} {
+		if c.Allows(v) {
+			t.Errorf("Allows(%q) = true for a version with no number", v)
+		}
+	}
+}
+
+func TestExtractVersion(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"Python 3.11.4", "3.11.4"},
+		{"v20.11.0", "20.11.0"},
+		{"git version 2.39.3 (Apple Git-145)", "2.39.3"},
+		{"jq-1.7.1", "1.7.1"},
+		{"ffmpeg version n6.0", "6.0"},
+		{"no digits", ""},
+	} {
+		if got := ExtractVersion(tt.in); got != tt.want {
+			t.Errorf("ExtractVersion(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
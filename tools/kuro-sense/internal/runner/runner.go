@@ -0,0 +1,194 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/secrets"
+)
+
+// Defaults mirror the mini-agent perception executor.
+const (
+	DefaultTimeout   = 10 * time.Second
+	DefaultOutputCap = 4000    // chars per plugin before truncation
+	maxBuffer        = 1 << 20 // stdout limit before the agent kills the script
+	stderrPreview    = 2000
+)
+
+// Spec describes how to run one plugin.
+type Spec struct {
+	Name      string
+	Script    string        // relative to the agent dir, or absolute
+	Timeout   time.Duration // 0 = DefaultTimeout
+	OutputCap int           // 0 = DefaultOutputCap
+}
+
+// Result is the outcome of one smoke run.
+type Result struct {
+	Name       string `json:"name"`
+	Script     string `json:"script"`
+	ExitCode   int    `json:"exitCode"`
+	TimedOut   bool   `json:"timedOut"`
+	DurationMs int64  `json:"durationMs"`
+	TimeoutMs  int64  `json:"timeoutMs"`
+	OutputLen  int    `json:"outputLen"` // trimmed stdout, in chars
+	OutputCap  int    `json:"outputCap"`
+	Truncated  bool   `json:"truncated"` // output exceeds the cap
+	Wrapped    bool   `json:"wrapped"`   // agent would inject <name>...</name>
+	Stdout     string `json:"-"`
+	Stderr     string `json:"stderr,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OK reports whether the plugin ran cleanly.
+func (r Result) OK() bool {
+	return r.Error == "" && r.ExitCode == 0 && !r.TimedOut
+}
+
+// Run executes a plugin the way the agent does: the script itself (not via
+// a shell), working directory agentDir, the given environment, and a hard
+// timeout. Like the agent, a failed run produces no wrapped output.
+func Run(ctx context.Context, agentDir string, spec Spec, env []string) Result {
+	timeout := spec.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	outputCap := spec.OutputCap
+	if outputCap <= 0 {
+		outputCap = DefaultOutputCap
+	}
+	res := Result{
+		Name:      spec.Name,
+		Script:    spec.Script,
+		TimeoutMs: timeout.Milliseconds(),
+		OutputCap: outputCap,
+	}
+
+	path := spec.Script
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(agentDir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		res.ExitCode = -1
+		res.Error = fmt.Sprintf("script not found: %s", path)
+		return res
+	}
+	abs, _ := filepath.Abs(path)
+
+	rctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	outw := &limitedWriter{buf: &stdout, max: maxBuffer}
+	cmd := exec.CommandContext(rctx, abs)
+	cmd.Dir = agentDir
+	cmd.Env = env
+	cmd.Stdout = outw
+	cmd.Stderr = &limitedWriter{buf: &stderr, max: maxBuffer}
+	cmd.WaitDelay = time.Second // don't hang on children holding the pipes
+
+	start := time.Now()
+	err := cmd.Run()
+	res.DurationMs = time.Since(start).Milliseconds()
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		switch {
+		case errors.Is(rctx.Err(), context.DeadlineExceeded):
+			res.TimedOut = true
+			res.ExitCode = -1
+			res.Error = fmt.Sprintf("killed after %s", timeout)
+		case errors.As(err, &exitErr):
+			res.ExitCode = exitErr.ExitCode()
+		default:
+			res.ExitCode = -1
+			res.Error = err.Error()
+		}
+	}
+
+	if outw.overflow && res.Error == "" {
+		res.Error = "stdout exceeded 1 MB; the agent would drop this output"
+	}
+
+	out := strings.TrimSpace(stdout.String())
+	res.Stdout = out
+	res.OutputLen = utf8.RuneCountInString(out)
+	res.Truncated = res.OutputLen > outputCap
+	res.Wrapped = res.OK() && out != ""
+	res.Stderr = preview(strings.TrimSpace(stderr.String()), stderrPreview)
+	return res
+}
+
+// RunAll runs every spec in parallel and returns results in spec order.
+func RunAll(ctx context.Context, agentDir string, specs []Spec, env []string) []Result {
+	results := make([]Result, len(specs))
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = Run(ctx, agentDir, spec, env)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// AgentEnv returns the environment the agent passes to plugins: the current
+// process environment plus the secrets file, without overriding variables
+// that are already set (the same rule as Node's process.loadEnvFile).
+func AgentEnv(secretsFile string) []string {
+	env := os.Environ()
+	f, err := secrets.Load(secretsFile)
+	if err != nil {
+		return env
+	}
+	for key, value := range f.Values() {
+		if _, set := os.LookupEnv(key); set {
+			continue
+		}
+		env = append(env, key+"="+value)
+	}
+	return env
+}
+
+// preview cuts s to at most n bytes, backing off to a rune boundary so
+// multi-byte characters in stderr are never split.
+func preview(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
+
+// limitedWriter keeps at most max bytes and discards the rest.
+type limitedWriter struct {
+	buf      *bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	room := w.max - w.buf.Len()
+	if len(p) > room {
+		w.overflow = true
+		if room > 0 {
+			w.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	w.buf.Write(p)
+	return len(p), nil
+}
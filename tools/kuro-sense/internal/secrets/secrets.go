@@ -0,0 +1,202 @@
+// Package secrets reads and writes the dotenv file that holds an agent's
+// credentials. The agent loads agentDir/.env at startup without overriding
+// variables already set in its environment; this package follows the same
+// rules so detection sees what the agent will see.
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/fsutil"
+)
+
+// DefaultFile is the secrets file the agent loads, relative to its
+// directory.
+const DefaultFile = ".env"
+
+// FileEnv overrides the secrets file location, like --secrets-file.
+const FileEnv = "KURO_SENSE_SECRETS_FILE"
+
+// Perm is the mode secrets files are written with.
+const Perm os.FileMode = 0o600
+
+// Path returns the secrets file for agentDir: file when set, else
+// $KURO_SENSE_SECRETS_FILE, else agentDir/.env.
+func Path(agentDir, file string) string {
+	if file != "" {
+		return file
+	}
+	if env := os.Getenv(FileEnv); env != "" {
+		return env
+	}
+	return filepath.Join(agentDir, DefaultFile)
+}
+
+var keyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidKey reports whether key can be written as a variable name.
+func ValidKey(key string) bool {
+	return keyRe.MatchString(key)
+}
+
+// File is a parsed secrets file. Comments, blank lines and the order of
+// entries are kept when it is saved.
+type File struct {
+	Path  string
+	lines []string
+}
+
+// Load reads the secrets file at path. A missing file loads as empty.
+func Load(path string) (*File, error) {
+	f := &File{Path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	if text != "" {
+		f.lines = strings.Split(text, "\n")
+	}
+	return f, nil
+}
+
+// Get returns the value of key. Like the agent, the last assignment wins.
+func (f *File) Get(key string) (string, bool) {
+	var value string
+	found := false
+	for _, line := range f.lines {
+		if k, v, ok := ParseLine(line); ok && k == key {
+			value, found = v, true
+		}
+	}
+	return value, found
+}
+
+// Values returns every variable in the file.
+func (f *File) Values() map[string]string {
+	out := make(map[string]string)
+	for _, line := range f.lines {
+		if k, v, ok := ParseLine(line); ok {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// Set assigns key, replacing the first existing assignment and dropping
+// any later ones, or appending a new line.
+func (f *File) Set(key, value string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("invalid variable name %q", key)
+	}
+	line, err := formatLine(key, value)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	kept := f.lines[:0]
+	for _, l := range f.lines {
+		if k, _, ok := ParseLine(l); ok && k == key {
+			if replaced {
+				continue
+			}
+			l, replaced = line, true
+		}
+		kept = append(kept, l)
+	}
+	f.lines = kept
+	if !replaced {
+		f.lines = append(f.lines, line)
+	}
+	return nil
+}
+
+// Save writes the file atomically with mode 0600, tightening the mode of
+// an existing file.
+func (f *File) Save() error {
+	var buf bytes.Buffer
+	for _, l := range f.lines {
+		buf.WriteString(l)
+		buf.WriteByte('\n')
+	}
+	if err := fsutil.WriteFileAtomic(f.Path, buf.Bytes(), Perm); err != nil {
+		return err
+	}
+	return os.Chmod(f.Path, Perm)
+}
+
+// Insecure reports the mode of the file at path when group or others can
+// read or write it.
+func Insecure(path string) (os.FileMode, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	mode := info.Mode().Perm()
+	return mode, mode&0o077 != 0
+}
+
+// Lookup returns the value of key in the file at path, or "" when the file
+// or the key is missing.
+func Lookup(path, key string) string {
+	f, err := Load(path)
+	if err != nil {
+		return ""
+	}
+	v, _ := f.Get(key)
+	return v
+}
+
+// ParseLine parses one KEY=value line of a dotenv file. As in Node's
+// process.loadEnvFile, \n in a double-quoted value is a newline.
+func ParseLine(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	line = strings.TrimPrefix(line, "export ")
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		if value[0] == '"' {
+			value = strings.ReplaceAll(value, `\n`, "\n")
+		}
+		value = value[1 : len(value)-1]
+	} else if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return key, value, key != ""
+}
+
+var plainRe = regexp.MustCompile(`^[A-Za-z0-9_./:+@%,=-]*$`)
+
+// formatLine renders key=value so that ParseLine reads value back.
+func formatLine(key, value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("%s: value must be a single line", key)
+	}
+	switch {
+	case plainRe.MatchString(value):
+		return key + "=" + value, nil
+	case !strings.Contains(value, "'"):
+		return key + "='" + value + "'", nil
+	case strings.Contains(value, `\n`):
+		// Double quotes would turn \n into a newline.
+		return "", fmt.Errorf(`%s: value cannot contain both ' and \n`, key)
+	case !strings.Contains(value, `"`):
+		return key + `="` + value + `"`, nil
+	}
+	return "", fmt.Errorf("%s: value cannot contain both ' and \"", key)
+}
@@ -0,0 +1,163 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetSaveGet(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		value   string
+		line    string // the line Save writes
+		wantErr string
+	}{
+		{name: "plain", value: "xai-123_abc", line: "KEY=xai-123_abc"},
+		{name: "empty", value: "", line: "KEY="},
+		{name: "url", value: "https://example.com/a?b=c", line: "KEY='https://example.com/a?b=c'"},
+		{name: "spaces", value: "two words", line: "KEY='two words'"},
+		{name: "comment marker", value: "a #b", line: "KEY='a #b'"},
+		{name: "hash", value: "a#b", line: "KEY='a#b'"},
+		{name: "double quote", value: `say "hi"`, line: `KEY='say "hi"'`},
+		{name: "backslash n in single quotes", value: `C:\new`, line: `KEY='C:\new'`},
+		{name: "single quote", value: "it's", line: `KEY="it's"`},
+		{name: "single quote and backslash n", value: `it's C:\new`, wantErr: `both ' and \n`},
+		{name: "both quotes", value: `it's "x"`, wantErr: `both ' and "`},
+		{name: "newline", value: "a\nb", wantErr: "single line"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".env")
+			f, _ := Load(path)
+			err := f.Set("KEY", tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Set(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Set(%q): %v", tt.value, err)
+			}
+			if err := f.Save(); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(data); got != tt.line+"\n" {
+				t.Errorf("saved %q, want %q", got, tt.line+"\n")
+			}
+			if got := Lookup(path, "KEY"); got != tt.value {
+				t.Errorf("Lookup = %q, want %q", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	for _, tt := range []struct {
+		line       string
+		key, value string
+		ok         bool
+	}{
+		{line: "KEY=value", key: "KEY", value: "value", ok: true},
+		{line: "  KEY = value  ", key: "KEY", value: "value", ok: true},
+		{line: "export KEY=value", key: "KEY", value: "value", ok: true},
+		{line: "KEY=value # comment", key: "KEY", value: "value", ok: true},
+		{line: "KEY=value#not-a-comment", key: "KEY", value: "value#not-a-comment", ok: true},
+		{line: "KEY='a # b'", key: "KEY", value: "a # b", ok: true},
+		{line: `KEY="a # b"`, key: "KEY", value: "a # b", ok: true},
+		{line: `KEY="a\nb"`, key: "KEY", value: "a\nb", ok: true},
+		{line: `KEY='a\nb'`, key: "KEY", value: `a\nb`, ok: true},
+		{line: "KEY=a=b", key: "KEY", value: "a=b", ok: true},
+		{line: "# KEY=value"},
+		{line: ""},
+		{line: "no equals sign"},
+		{line: "=value"},
+	} {
+		key, value, ok := ParseLine(tt.line)
+		if !tt.ok && !ok {
+			continue
+		}
+		if key != tt.key || value != tt.value || ok != tt.ok {
+			t.Errorf("ParseLine(%q) = %q, %q, %v; want %q, %q, %v", tt.line, key, value, ok, tt.key, tt.value, tt.ok)
+		}
+	}
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	src := "# tokens\nKEY=first\nOTHER=x\nKEY=second\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := f.Get("KEY"); v != "second" {
+		t.Errorf("Get = %q, want the last assignment", v)
+	}
+	if v := f.Values()["KEY"]; v != "second" {
+		t.Errorf("Values[KEY] = %q, want the last assignment", v)
+	}
+
+	if err := f.Set("KEY", "third"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("NEW", "y"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# tokens\nKEY=third\nOTHER=x\nNEW=y\n"; string(data) != want {
+		t.Errorf("saved %q, want %q", data, want)
+	}
+}
+
+func TestSaveTightensMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("KEY=value\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if mode, insecure := Insecure(path); !insecure {
+		t.Fatalf("Insecure = %v, %v before Save", mode, insecure)
+	}
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("KEY", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != Perm {
+		t.Errorf("mode = %v, want %v", mode, Perm)
+	}
+	if _, insecure := Insecure(path); insecure {
+		t.Error("Insecure after Save")
+	}
+}
+
+func TestSetRejectsBadKeys(t *testing.T) {
+	f := &File{}
+	for _, key := range []string{"", "1KEY", "KEY-NAME", "A B", "KEY="} {
+		if err := f.Set(key, "v"); err == nil {
+			t.Errorf("Set(%q) accepted an invalid name", key)
+		}
+	}
+}
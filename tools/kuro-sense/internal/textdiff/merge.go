@@ -0,0 +1,97 @@
+package textdiff
+
+import "strings"
+
+// Merge3 merges the changes local and remote each made to base, line by
+// line. Where both changed the same lines differently the result holds
+// both versions between conflict markers labelled localName and
+// remoteName, and conflicts counts those places.
+func Merge3(base, local, remote []byte, localName, remoteName string) (merged []byte, conflicts int) {
+	bl := splitLines(string(base))
+	ll := splitLines(string(local))
+	rl := splitLines(string(remote))
+	ml := matches(bl, ll)
+	mr := matches(bl, rl)
+
+	var sb strings.Builder
+	i, a, b := 0, 0, 0
+	for i < len(bl) || a < len(ll) || b < len(rl) {
+		// A base line both sides kept is stable: copy it.
+		if i < len(bl) && ml[i] == a && mr[i] == b {
+			sb.WriteString(bl[i])
+			i, a, b = i+1, a+1, b+1
+			continue
+		}
+		// Otherwise the chunk runs to the next stable line.
+		k := i
+		for k < len(bl) && (ml[k] < 0 || mr[k] < 0) {
+			k++
+		}
+		ak, bk := len(ll), len(rl)
+		if k < len(bl) {
+			ak, bk = ml[k], mr[k]
+		}
+		baseChunk, localChunk, remoteChunk := bl[i:k], ll[a:ak], rl[b:bk]
+		switch {
+		case equalLines(localChunk, baseChunk):
+			writeLines(&sb, remoteChunk, false)
+		case equalLines(remoteChunk, baseChunk), equalLines(localChunk, remoteChunk):
+			writeLines(&sb, localChunk, false)
+		default:
+			conflicts++
+			sb.WriteString("<<<<<<< " + localName + "\n")
+			writeLines(&sb, localChunk, true)
+			sb.WriteString("||||||| base\n")
+			writeLines(&sb, baseChunk, true)
+			sb.WriteString("=======\n")
+			writeLines(&sb, remoteChunk, true)
+			sb.WriteString(">>>>>>> " + remoteName + "\n")
+		}
+		i, a, b = k, ak, bk
+	}
+	return []byte(sb.String()), conflicts
+}
+
+// matches maps each line of a to the line of b it is kept as, or -1 if
+// the edit script from a to b removes it.
+func matches(a, b []string) []int {
+	m := make([]int, len(a))
+	ai, bi := 0, 0
+	for _, o := range diffLines(a, b) {
+		switch o.kind {
+		case ' ':
+			m[ai] = bi
+			ai++
+			bi++
+		case '-':
+			m[ai] = -1
+			ai++
+		case '+':
+			bi++
+		}
+	}
+	return m
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeLines writes lines; inside conflict markers a missing final
+// newline is added so the marker starts its own line.
+func writeLines(sb *strings.Builder, lines []string, terminate bool) {
+	for _, l := range lines {
+		sb.WriteString(l)
+	}
+	if terminate && len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		sb.WriteByte('\n')
+	}
+}
@@ -0,0 +1,149 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	const base = "one\ntwo\nthree\nfour\nfive\n"
+	for _, tt := range []struct {
+		name          string
+		local, remote string
+		want          string
+		conflicts     int
+	}{
+		{
+			name:  "no changes",
+			local: base, remote: base,
+			want: base,
+		},
+		{
+			name:  "only local",
+			local: "ONE\ntwo\nthree\nfour\nfive\n", remote: base,
+			want: "ONE\ntwo\nthree\nfour\nfive\n",
+		},
+		{
+			name:  "only remote",
+			local: base, remote: "one\ntwo\nthree\nfour\nFIVE\n",
+			want: "one\ntwo\nthree\nfour\nFIVE\n",
+		},
+		{
+			name:  "separate hunks",
+			local: "ONE\ntwo\nthree\nfour\nfive\n", remote: "one\ntwo\nthree\nfour\nFIVE\n",
+			want: "ONE\ntwo\nthree\nfour\nFIVE\n",
+		},
+		{
+			name:  "insert and delete",
+			local: "zero\none\ntwo\nthree\nfour\nfive\n", remote: "one\ntwo\nfour\nfive\n",
+			want: "zero\none\ntwo\nfour\nfive\n",
+		},
+		{
+			name:  "same change on both sides",
+			local: "one\nTWO\nthree\nfour\nfive\n", remote: "one\nTWO\nthree\nfour\nfive\n",
+			want: "one\nTWO\nthree\nfour\nfive\n",
+		},
+		{
+			name:  "conflict",
+			local: "one\nTWO\nthree\nfour\nfive\n", remote: "one\n2\nthree\nfour\nfive\n",
+			want:      "one\n<<<<<<< local\nTWO\n||||||| base\ntwo\n=======\n2\n>>>>>>> archive\nthree\nfour\nfive\n",
+			conflicts: 1,
+		},
+		{
+			name:  "adjacent edits conflict together",
+			local: "one\nTWO\nthree\nfour\nfive\n", remote: "one\ntwo\nTHREE\nfour\nfive\n",
+			want:      "one\n<<<<<<< local\nTWO\nthree\n||||||| base\ntwo\nthree\n=======\ntwo\nTHREE\n>>>>>>> archive\nfour\nfive\n",
+			conflicts: 1,
+		},
+		{
+			name:  "two conflicts and a clean hunk",
+			local: "1\ntwo\nthree\nfour\n5\n", remote: "uno\ntwo\nTHREE\nfour\nfünf\n",
+			want: "<<<<<<< local\n1\n||||||| base\none\n=======\nuno\n>>>>>>> archive\ntwo\nTHREE\nfour\n" +
+				"<<<<<<< local\n5\n||||||| base\nfive\n=======\nfünf\n>>>>>>> archive\n",
+			conflicts: 2,
+		},
+		{
+			name:  "conflict without final newline",
+			local: base + "six", remote: base + "6",
+			want:      base + "<<<<<<< local\nsix\n||||||| base\n=======\n6\n>>>>>>> archive\n",
+			conflicts: 1,
+		},
+		{
+			name:  "local deleted everything",
+			local: "", remote: base,
+			want: "",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := Merge3([]byte(base), []byte(tt.local), []byte(tt.remote), "local", "archive")
+			if string(got) != tt.want {
+				t.Errorf("merged:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if conflicts != tt.conflicts {
+				t.Errorf("conflicts = %d, want %d", conflicts, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestDiffLinesIsMinimal(t *testing.T) {
+	for _, tt := range []struct {
+		a, b  string
+		edits int
+	}{
+		{"", "", 0},
+		{"a\n", "", 1},
+		{"", "a\nb\n", 2},
+		{"a\nb\nc\n", "a\nc\n", 1},
+		{"a\nb\nc\na\nb\nb\na\n", "c\nb\na\nb\na\nc\n", 5},
+		{"x\n", "y\n", 2},
+		{"a\nb\nc\nd\n", "d\nc\nb\na\n", 6},
+	} {
+		a, b := splitLines(tt.a), splitLines(tt.b)
+		ops := diffLines(a, b)
+		var gotA, gotB strings.Builder
+		edits := 0
+		for _, o := range ops {
+			if o.kind != '+' {
+				gotA.WriteString(o.text)
+			}
+			if o.kind != '-' {
+				gotB.WriteString(o.text)
+			}
+			if o.kind != ' ' {
+				edits++
+			}
+		}
+		if gotA.String() != tt.a || gotB.String() != tt.b {
+			t.Errorf("diff %q → %q does not reproduce its inputs: %v", tt.a, tt.b, ops)
+		}
+		if edits != tt.edits {
+			t.Errorf("diff %q → %q: %d edits, want %d", tt.a, tt.b, edits, tt.edits)
+		}
+	}
+}
+
+func TestDiffLinesLargeInput(t *testing.T) {
+	// Completely different texts are the worst case; an n·m table here
+	// would need gigabytes.
+	var a, b strings.Builder
+	for i := range 20000 {
+		a.WriteString("old line " + strings.Repeat("a", i%7) + "\n")
+		b.WriteString("new line " + strings.Repeat("b", i%5) + "\n")
+	}
+	ops := diffLines(splitLines(a.String()), splitLines(b.String()))
+	if len(ops) != 40000 {
+		t.Errorf("%d ops, want 40000", len(ops))
+	}
+}
+
+func TestUnified(t *testing.T) {
+	got := Unified("a", "b", []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n"), []byte("1\n2\n3\n4\nfive\n6\n7\n8\n9\n"), DefaultContext)
+	want := "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n"
+	if got != want {
+		t.Errorf("Unified:\n%s\nwant:\n%s", got, want)
+	}
+	if got := Unified("a", "b", []byte("same\n"), []byte("same\n"), DefaultContext); got != "" {
+		t.Errorf("Unified of equal texts = %q", got)
+	}
+}
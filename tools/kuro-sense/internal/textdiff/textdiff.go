@@ -0,0 +1,280 @@
+// Package textdiff renders line-based unified diffs and merges three
+// versions of a text.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines shown around a change.
+const DefaultContext = 3
+
+type op struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// Unified returns a unified diff turning a into b, or "" if they are equal.
+func Unified(aName, bName string, a, b []byte, context int) string {
+	if string(a) == string(b) {
+		return ""
+	}
+	al, bl := splitLines(string(a)), splitLines(string(b))
+	ops := diffLines(al, bl)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+	for _, h := range hunks(ops, context) {
+		writeHunk(&sb, ops, h)
+	}
+	return sb.String()
+}
+
+// splitLines splits s into lines, keeping the newline on each line so a
+// missing final newline shows up as a change.
+func splitLines(s string) []string {
+	var lines []string
+	for s != "" {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script with Myers' O(ND) algorithm in
+// its linear-space form: memory stays proportional to the input however
+// different the two texts are.
+func diffLines(a, b []string) []op {
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		out := make([]int, len(lines))
+		for i, l := range lines {
+			id, ok := ids[l]
+			if !ok {
+				id = len(ids)
+				ids[l] = id
+			}
+			out[i] = id
+		}
+		return out
+	}
+	d := &differ{a: intern(a), b: intern(b), removed: make([]bool, len(a)), added: make([]bool, len(b))}
+	d.compare(0, len(a), 0, len(b))
+
+	ops := make([]op, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && d.removed[i]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		case j < len(b) && d.added[j]:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		default:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		}
+	}
+	return ops
+}
+
+// differ marks the lines of a removed and of b added by the edit script.
+type differ struct {
+	a, b           []int // lines as ids, equal lines sharing one
+	removed, added []bool
+}
+
+// compare diffs a[a0:a1] against b[b0:b1].
+func (d *differ) compare(a0, a1, b0, b1 int) {
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		a0++
+		b0++
+	}
+	for a0 < a1 && b0 < b1 && d.a[a1-1] == d.b[b1-1] {
+		a1--
+		b1--
+	}
+	switch {
+	case a0 == a1:
+		for j := b0; j < b1; j++ {
+			d.added[j] = true
+		}
+	case b0 == b1:
+		for i := a0; i < a1; i++ {
+			d.removed[i] = true
+		}
+	default:
+		if x, y, ok := d.split(a0, a1, b0, b1); ok {
+			d.compare(a0, x, b0, y)
+			d.compare(x, a1, y, b1)
+			return
+		}
+		for i := a0; i < a1; i++ {
+			d.removed[i] = true
+		}
+		for j := b0; j < b1; j++ {
+			d.added[j] = true
+		}
+	}
+}
+
+// split finds the middle snake of a[a0:a1] and b[b0:b1] by running the
+// search forward from the start and backward from the end until the two
+// meet, and returns the point where they do: an optimal script passes
+// through it, so each half can be diffed on its own. Both ranges are
+// non-empty and differ in their first and last lines. ok is false when
+// the ranges share no line worth keeping: replacing all of a is optimal.
+func (d *differ) split(a0, a1, b0, b1 int) (int, int, bool) {
+	n, m := a1-a0, b1-b0
+	maxD := (n + m + 1) / 2
+	off := maxD
+	// fwd[off+k] is the furthest x reached on diagonal k = x-y going
+	// forward; bwd likewise counts from the end going backward.
+	fwd := make([]int, 2*maxD+2)
+	bwd := make([]int, 2*maxD+2)
+	for i := range fwd {
+		fwd[i], bwd[i] = -1, -1
+	}
+	fwd[off+1], bwd[off+1] = 0, 0
+	delta := n - m
+	odd := delta%2 != 0
+	// Diagonals that ran off the edge are trimmed from later rounds.
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+	for step := 0; step < maxD; step++ {
+		for k := -step + fStart; k <= step-fEnd; k += 2 {
+			var x int
+			if k == -step || (k != step && fwd[off+k-1] < fwd[off+k+1]) {
+				x = fwd[off+k+1]
+			} else {
+				x = fwd[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[a0+x] == d.b[b0+y] {
+				x++
+				y++
+			}
+			fwd[off+k] = x
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				if bk := off + delta - k; bk >= 0 && bk < len(bwd) && bwd[bk] != -1 && x >= n-bwd[bk] {
+					return a0 + x, b0 + y, true
+				}
+			}
+		}
+		for k := -step + bStart; k <= step-bEnd; k += 2 {
+			var x int
+			if k == -step || (k != step && bwd[off+k-1] < bwd[off+k+1]) {
+				x = bwd[off+k+1]
+			} else {
+				x = bwd[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[a1-1-x] == d.b[b1-1-y] {
+				x++
+				y++
+			}
+			bwd[off+k] = x
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !odd:
+				if fk := off + delta - k; fk >= 0 && fk < len(fwd) && fwd[fk] != -1 {
+					fx := fwd[fk]
+					if fx >= n-x {
+						return a0 + fx, b0 + fx - (fk - off), true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// hunk is a half-open range of ops.
+type hunk struct{ start, end int }
+
+// hunks groups changed ops with up to context unchanged lines around them,
+// merging groups whose context would touch.
+func hunks(ops []op, context int) []hunk {
+	var out []hunk
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == ' ' {
+			continue
+		}
+		start := max(i-context, 0)
+		end := i + 1
+		// Extend while the next change is within 2*context lines.
+		for k := end; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				end = k + 1
+			} else if k-end >= 2*context {
+				break
+			}
+		}
+		end = min(end+context, len(ops))
+		if n := len(out); n > 0 && start <= out[n-1].end {
+			out[n-1].end = end
+		} else {
+			out = append(out, hunk{start, end})
+		}
+		i = end - 1
+	}
+	return out
+}
+
+func writeHunk(sb *strings.Builder, ops []op, h hunk) {
+	// Line numbers (1-based) where the hunk starts in a and b.
+	aLine, bLine := 1, 1
+	for _, o := range ops[:h.start] {
+		if o.kind != '+' {
+			aLine++
+		}
+		if o.kind != '-' {
+			bLine++
+		}
+	}
+	var aCount, bCount int
+	for _, o := range ops[h.start:h.end] {
+		if o.kind != '+' {
+			aCount++
+		}
+		if o.kind != '-' {
+			bCount++
+		}
+	}
+	if aCount == 0 {
+		aLine--
+	}
+	if bCount == 0 {
+		bLine--
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", span(aLine, aCount), span(bLine, bCount))
+	for _, o := range ops[h.start:h.end] {
+		sb.WriteByte(o.kind)
+		sb.WriteString(o.text)
+		if !strings.HasSuffix(o.text, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func span(line, count int) string {
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
@@ -1,6 +1,9 @@
 package tui
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/charmbracelet/bubbletea"
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/detect"
@@ -19,6 +22,7 @@ const (
 type model struct {
 	phase    phase
 	agentDir string
+	agent    string // compose agent selector (see compose.SelectAgents)
 
 	// Detection
 	results detect.Results
@@ -26,6 +30,9 @@ type model struct {
 	// Selection
 	cursor   int
 	selected map[string]bool // plugin name → enabled
+	tags     []string        // tags in use, for the filter
+	tagIdx   int             // index into tags; -1 = no filter
+	notice   string          // result of the last toggle, such as a refused conflict
 
 	// Install
 	installQueue []string
@@ -42,19 +49,23 @@ type model struct {
 	currentEnabled map[string]bool
 }
 
-// Run starts the TUI interactive mode.
-func Run(agentDir string) error {
-	caps := registry.All()
-	results := detect.RunAll(caps)
-
+// Run starts the TUI interactive mode, configuring the given agent.
+func Run(agentDir, agent string) error {
 	// Load current compose state
 	currentEnabled := make(map[string]bool)
 	if cf, err := compose.Load(agentDir); err == nil {
-		for _, name := range compose.GetEnabledPluginNames(cf) {
+		names, err := compose.GetEnabledPluginNames(cf, agent)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
 			currentEnabled[name] = true
 		}
 	}
 
+	caps := registry.All()
+	results := detect.RunAll(caps)
+
 	// Pre-select: currently enabled + available defaults
 	selected := make(map[string]bool)
 	for name := range currentEnabled {
@@ -71,7 +82,10 @@ func Run(agentDir string) error {
 	m := model{
 		phase:          phaseDetect,
 		agentDir:       agentDir,
+		agent:          agent,
 		results:        results,
+		tags:           registry.AllTags(caps),
+		tagIdx:         -1,
 		selected:       selected,
 		currentEnabled: currentEnabled,
 	}
@@ -115,15 +129,50 @@ func (m model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case "down", "j":
-		if m.phase == phaseSelect && m.cursor < len(m.results.Capabilities)-1 {
+		if m.phase == phaseSelect && m.cursor < len(m.visible())-1 {
 			m.cursor++
 		}
 		return m, nil
 
 	case " ":
 		if m.phase == phaseSelect {
-			name := m.results.Capabilities[m.cursor].Capability.Name
-			m.selected[name] = !m.selected[name]
+			if vis := m.visible(); m.cursor < len(vis) {
+				name := m.results.Capabilities[vis[m.cursor]].Capability.Name
+				m.notice = m.setSelected(name, !m.selected[name])
+			}
+		}
+		return m, nil
+
+	case "t":
+		// Cycle the tag filter: all → each tag → all.
+		if m.phase == phaseSelect && len(m.tags) > 0 {
+			m.tagIdx++
+			if m.tagIdx >= len(m.tags) {
+				m.tagIdx = -1
+			}
+			m.cursor = 0
+		}
+		return m, nil
+
+	case "a":
+		// Toggle every visible plugin together: select all, or clear
+		// them if all are already selected.
+		if m.phase == phaseSelect {
+			vis := m.visible()
+			all := true
+			for _, i := range vis {
+				if !m.selected[m.results.Capabilities[i].Capability.Name] {
+					all = false
+					break
+				}
+			}
+			var notices []string
+			for _, i := range vis {
+				if n := m.setSelected(m.results.Capabilities[i].Capability.Name, !all); n != "" {
+					notices = append(notices, n)
+				}
+			}
+			m.notice = strings.Join(notices, "; ")
 		}
 		return m, nil
 	}
@@ -131,6 +180,88 @@ func (m model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// tagFilter returns the active tag filter, or "" for none.
+func (m model) tagFilter() string {
+	if m.tagIdx < 0 || m.tagIdx >= len(m.tags) {
+		return ""
+	}
+	return m.tags[m.tagIdx]
+}
+
+// visible returns indexes into m.results.Capabilities in display order:
+// grouped by category and limited to the tag filter.
+func (m model) visible() []int {
+	var idx []int
+	for _, g := range m.groups() {
+		idx = append(idx, g.Items...)
+	}
+	return idx
+}
+
+// groups returns the visible capabilities, as indexes into
+// m.results.Capabilities, grouped by category.
+func (m model) groups() []registry.Group[int] {
+	var idx []int
+	tag := m.tagFilter()
+	for i, r := range m.results.Capabilities {
+		if tag == "" || r.Capability.HasAnyTag([]string{tag}) {
+			idx = append(idx, i)
+		}
+	}
+	return registry.GroupByCategory(idx, func(i int) registry.Category {
+		return m.results.Capabilities[i].Capability.Category
+	})
+}
+
+// setSelected selects or deselects a plugin while keeping the selection
+// consistent with the capability graph: selecting pulls in what the plugin
+// requires and is refused if that would select conflicting plugins;
+// deselecting also deselects the plugins that require it. It returns a
+// note for the user, or "".
+func (m model) setSelected(name string, on bool) string {
+	caps := registry.All()
+	if !on {
+		dropped := []string{}
+		var drop func(string)
+		drop = func(n string) {
+			m.selected[n] = false
+			for _, d := range registry.Dependents(caps, n) {
+				if m.selected[d] {
+					dropped = append(dropped, d)
+					drop(d)
+				}
+			}
+		}
+		drop(name)
+		if len(dropped) > 0 {
+			return fmt.Sprintf("also deselected %s (requires %s)", strings.Join(dropped, ", "), name)
+		}
+		return ""
+	}
+
+	var current []string
+	for n, sel := range m.selected {
+		if sel {
+			current = append(current, n)
+		}
+	}
+	res, err := registry.Resolve(caps, current, []string{name}, nil)
+	if err != nil {
+		return strings.ReplaceAll(err.Error(), "\n", "; ")
+	}
+	for _, n := range res.Enable {
+		m.selected[n] = true
+	}
+	if len(res.Pulled) > 0 {
+		var pulled []string
+		for _, p := range res.Pulled {
+			pulled = append(pulled, p.String())
+		}
+		return "also selected " + strings.Join(pulled, ", ")
+	}
+	return ""
+}
+
 func (m model) handleEnter() (tea.Model, tea.Cmd) {
 	switch m.phase {
 	case phaseDetect:
@@ -179,7 +310,14 @@ func (m model) handleEnter() (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		}
 		// Do the apply
-		err := compose.ApplyChanges(m.agentDir, m.toEnable, m.toDisable)
+		ch, _, err := compose.Resolve(m.agentDir, compose.Changes{
+			Agent:   m.agent,
+			Enable:  m.toEnable,
+			Disable: m.toDisable,
+		})
+		if err == nil {
+			err = compose.ApplyChanges(m.agentDir, ch)
+		}
 		m.applyErr = err
 		m.applied = true
 		return m, nil
@@ -10,6 +10,10 @@ func renderApplyView(m model) string {
 
 	b.WriteString(titleStyle.Render("Apply Configuration"))
 	b.WriteString("\n\n")
+	if m.agent != "" {
+		b.WriteString(dimStyle.Render(fmt.Sprintf("  Agent: %s", m.agent)))
+		b.WriteString("\n\n")
+	}
 
 	if m.applyErr != nil {
 		b.WriteString(errorStyle.Render(fmt.Sprintf("  Error: %s", m.applyErr)))
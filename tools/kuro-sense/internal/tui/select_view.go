@@ -12,32 +12,28 @@ func renderSelectView(m model) string {
 
 	b.WriteString(titleStyle.Render("Select Perception Plugins"))
 	b.WriteString("\n")
-	b.WriteString(subtitleStyle.Render("  space=toggle  ↑↓=navigate  enter=apply  q=quit"))
-	b.WriteString("\n\n")
-
-	categories := []registry.Category{
-		registry.CategoryWorkspace,
-		registry.CategoryChrome,
-		registry.CategoryTelegram,
-		registry.CategoryHeartbeat,
+	b.WriteString(subtitleStyle.Render("  space=toggle  a=toggle shown  t=filter by tag  ↑↓=navigate  enter=apply  q=quit"))
+	b.WriteString("\n")
+	if tag := m.tagFilter(); tag != "" {
+		b.WriteString(dimStyle.Render(fmt.Sprintf("  tag: %s", tag)))
+		b.WriteString("\n")
 	}
-	categoryNames := map[registry.Category]string{
-		registry.CategoryWorkspace: "Workspace",
-		registry.CategoryChrome:    "Chrome",
-		registry.CategoryTelegram:  "Telegram",
-		registry.CategoryHeartbeat: "Heartbeat",
+	if m.notice != "" {
+		b.WriteString(statusDegraded.Render("  " + m.notice))
+		b.WriteString("\n")
 	}
+	b.WriteString("\n")
 
 	idx := 0
-	for _, cat := range categories {
-		b.WriteString(dimStyle.Render(fmt.Sprintf("  ── %s ──", categoryNames[cat])))
+	for gi, g := range m.groups() {
+		if gi > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(dimStyle.Render(fmt.Sprintf("  ── %s ──", g.Category.Name)))
 		b.WriteString("\n")
 
-		for _, r := range m.results.Capabilities {
-			if r.Capability.Category != cat {
-				continue
-			}
-
+		for _, i := range g.Items {
+			r := m.results.Capabilities[i]
 			cursor := "  "
 			if idx == m.cursor {
 				cursor = "> "
@@ -55,7 +51,7 @@ func renderSelectView(m model) string {
 			line := fmt.Sprintf("%s[%s] %s %-20s %s", cursor, checked, icon, name, desc)
 
 			if !r.Available {
-				missing := missingNames(r.MissingDeps)
+				missing := append(missingNames(r.MissingDeps), missingNames(r.OutdatedDeps)...)
 				line += dimStyle.Render(fmt.Sprintf(" (need: %s)", strings.Join(missing, ", ")))
 			}
 
@@ -67,8 +63,8 @@ func renderSelectView(m model) string {
 			b.WriteString("\n")
 			idx++
 		}
-		b.WriteString("\n")
 	}
+	b.WriteString("\n")
 
 	return b.String()
 }
@@ -2,34 +2,67 @@ package web
 
 import (
 	"encoding/json"
+	"mime"
 	"net/http"
+	"strings"
 
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/compose"
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/detect"
+	"github.com/miles990/mini-agent/tools/kuro-sense/internal/installer"
 	"github.com/miles990/mini-agent/tools/kuro-sense/internal/registry"
 )
 
 type handler struct {
 	agentDir string
+	agent    string // default agent selector, from --agent
 }
 
 func (h *handler) handleDetect(w http.ResponseWriter, r *http.Request) {
-	caps := registry.All()
+	caps := registry.FilterByTags(registry.All(), queryTags(r))
 	results := detect.RunAll(caps)
 	writeJSON(w, results)
 }
 
 func (h *handler) handleCapabilities(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, registry.All())
+	writeJSON(w, registry.FilterByTags(registry.All(), queryTags(r)))
+}
+
+func (h *handler) handleCategories(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, append(registry.Categories(), registry.LookupCategory(registry.CategoryOther)))
+}
+
+// queryTags reads ?tag=a,b (or repeated ?tag=) as a tag filter.
+func queryTags(r *http.Request) []string {
+	var tags []string
+	for _, v := range r.URL.Query()["tag"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+	}
+	return tags
+}
+
+func (h *handler) handleAgents(w http.ResponseWriter, r *http.Request) {
+	cf, err := compose.Load(h.agentDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"default": h.agent,
+		"agents":  compose.ListAgents(cf),
+	})
 }
 
 func (h *handler) handleApply(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+	if !requireJSONPost(w, r) {
 		return
 	}
 
 	var req struct {
+		Agent   string   `json:"agent"`
 		Enable  []string `json:"enable"`
 		Disable []string `json:"disable"`
 	}
@@ -37,18 +70,32 @@ func (h *handler) handleApply(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if req.Agent == "" {
+		req.Agent = h.agent
+	}
 
-	if err := compose.ApplyChanges(h.agentDir, req.Enable, req.Disable); err != nil {
+	ch, pulls, err := compose.Resolve(h.agentDir, compose.Changes{
+		Agent:   req.Agent,
+		Enable:  req.Enable,
+		Disable: req.Disable,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err := compose.ApplyChanges(h.agentDir, ch); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	writeJSON(w, map[string]bool{"ok": true})
+	writeJSON(w, map[string]interface{}{"ok": true, "pulled": pulls})
 }
 
+// handleInstall reports how to install a missing dependency. It never runs
+// the install itself: installs may need sudo or a prompt, which a request
+// handler has no terminal for, so the command is shown for the user to run.
 func (h *handler) handleInstall(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+	if !requireJSONPost(w, r) {
 		return
 	}
 
@@ -60,8 +107,47 @@ func (h *handler) handleInstall(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Placeholder: actual installation will be done via installer package
-	writeJSON(w, map[string]string{"status": "not_implemented", "name": req.Name})
+	var dep registry.Dependency
+	found := false
+	for _, c := range registry.All() {
+		for _, d := range c.Dependencies {
+			if d.Name == req.Name && !found {
+				dep, found = d, true
+			}
+		}
+	}
+	if !found {
+		http.Error(w, "unknown dependency: "+req.Name, http.StatusNotFound)
+		return
+	}
+
+	mgrs := installer.DetectManagers()
+	hint, err := installer.Choose(dep, mgrs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"name":    req.Name,
+		"method":  hint.Method,
+		"command": installer.Describe(dep, hint, mgrs),
+		"status":  "manual",
+	})
+}
+
+// requireJSONPost rejects anything but a JSON POST. A cross-site form can
+// only send form or text bodies, so this keeps other pages from driving
+// the API through the user's browser.
+func requireJSONPost(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return false
+	}
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
 }
 
 func writeJSON(w http.ResponseWriter, v interface{}) {
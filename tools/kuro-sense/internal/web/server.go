@@ -9,9 +9,11 @@ import (
 //go:embed assets/*
 var assets embed.FS
 
-// Serve starts the web UI server.
-func Serve(port int, agentDir string) error {
-	h := &handler{agentDir: agentDir}
+// Serve starts the web UI server on the loopback interface; the API edits
+// the agent's files and must not be reachable from the network. agent is
+// the default compose agent for /api/apply requests that do not name one.
+func Serve(port int, agentDir, agent string) error {
+	h := &handler{agentDir: agentDir, agent: agent}
 
 	mux := http.NewServeMux()
 
@@ -21,8 +23,10 @@ func Serve(port int, agentDir string) error {
 	// JSON API
 	mux.HandleFunc("/api/detect", h.handleDetect)
 	mux.HandleFunc("/api/capabilities", h.handleCapabilities)
+	mux.HandleFunc("/api/categories", h.handleCategories)
+	mux.HandleFunc("/api/agents", h.handleAgents)
 	mux.HandleFunc("/api/apply", h.handleApply)
 	mux.HandleFunc("/api/install", h.handleInstall)
 
-	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+	return http.ListenAndServe(fmt.Sprintf("127.0.0.1:%d", port), mux)
 }